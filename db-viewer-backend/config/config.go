@@ -0,0 +1,113 @@
+package config
+
+import (
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Storage modes understood by the database package
+const (
+	StorageMemory = "memory"
+	StorageFile   = "file"
+)
+
+// Query roles, from least to most privileged
+const (
+	RoleReader = "reader"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// Roles lists the query roles from least to most privileged
+var Roles = []string{RoleReader, RoleEditor, RoleAdmin}
+
+// Config holds the runtime settings of the backend
+type Config struct {
+	// Storage selects between a throwaway in-memory database and file-backed workspaces
+	Storage string
+	// DataDir is where workspace files live when Storage is "file"
+	DataDir string
+	// SessionTTL is how long an idle in-memory session is kept before eviction
+	SessionTTL time.Duration
+	// MaxSessions caps the in-memory sessions held at once
+	MaxSessions int
+	// QueryTimeout bounds a query sent to /query that does not ask for its own timeout
+	QueryTimeout time.Duration
+	// MaxQueryTimeout caps the timeout a query may ask for
+	MaxQueryTimeout time.Duration
+	// MaxQueryRows caps the rows /query returns in one response; streamed results are not capped
+	MaxQueryRows int
+	// QueryRole is the most privileged role /query runs as; clients may ask for a lesser one
+	QueryRole string
+	// QueryAllow replaces the statement kinds a role may run, keyed by role
+	QueryAllow map[string][]string
+}
+
+// Load reads the configuration from the environment, falling back to defaults
+func Load() Config {
+	cfg := Config{
+		Storage:     getEnv("DB_VIEWER_STORAGE", StorageMemory),
+		DataDir:     getEnv("DB_VIEWER_DATA_DIR", "data"),
+		SessionTTL:  getEnvDuration("DB_VIEWER_SESSION_TTL", 30*time.Minute),
+		MaxSessions: getEnvInt("DB_VIEWER_MAX_SESSIONS", 100),
+
+		QueryTimeout:    getEnvDuration("DB_VIEWER_QUERY_TIMEOUT", 30*time.Second),
+		MaxQueryTimeout: getEnvDuration("DB_VIEWER_MAX_QUERY_TIMEOUT", 5*time.Minute),
+		MaxQueryRows:    getEnvInt("DB_VIEWER_MAX_QUERY_ROWS", 10000),
+		QueryRole:       strings.ToLower(getEnv("DB_VIEWER_QUERY_ROLE", RoleAdmin)),
+		QueryAllow:      make(map[string][]string),
+	}
+
+	if cfg.Storage != StorageFile {
+		cfg.Storage = StorageMemory
+	}
+	if cfg.QueryTimeout > cfg.MaxQueryTimeout {
+		cfg.QueryTimeout = cfg.MaxQueryTimeout
+	}
+	// An unknown role fails closed
+	if !slices.Contains(Roles, cfg.QueryRole) {
+		cfg.QueryRole = RoleReader
+	}
+	for _, role := range Roles {
+		if kinds, ok := os.LookupEnv("DB_VIEWER_QUERY_ALLOW_" + strings.ToUpper(role)); ok {
+			cfg.QueryAllow[role] = splitList(kinds)
+		}
+	}
+
+	return cfg
+}
+
+func getEnv(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil && n > 0 {
+		return n
+	}
+	return fallback
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,239 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"db-viewer/models"
+)
+
+// Kinds of ConstraintViolation
+const (
+	ViolationPrimaryKey = "primary_key"
+	ViolationForeignKey = "foreign_key"
+)
+
+// maxViolationsReported caps the violations listed for one table
+const maxViolationsReported = 100
+
+var (
+	// ErrInvalidConstraint is returned for a constraint that cannot be declared
+	ErrInvalidConstraint = errors.New("invalid constraint")
+	// ErrDuplicateKeys is returned when rows share the values of a new primary key
+	ErrDuplicateKeys = errors.New("rows share primary key values")
+)
+
+// Queryer is what constraint checks need from a *sql.DB, *sql.Conn or *sql.Tx
+type Queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// GuessReferencedTable applies the naming convention for foreign keys: a
+// column <name>_id refers to the table <name> or <name>s. It returns "" when
+// no such table is among tables.
+func GuessReferencedTable(tables []string, column string) string {
+	if !strings.HasSuffix(strings.ToLower(column), "_id") {
+		return ""
+	}
+	base := column[:len(column)-len("_id")]
+	for _, candidate := range []string{base, base + "s"} {
+		for _, table := range tables {
+			if strings.EqualFold(table, candidate) {
+				return table
+			}
+		}
+	}
+	return ""
+}
+
+// TableNames lists the user tables of the database
+func TableNames(ctx context.Context, q Queryer) ([]string, error) {
+	rows, err := q.QueryContext(ctx, "SELECT name FROM main.sqlite_master WHERE type = 'table' AND "+userTables+" ORDER BY rowid")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// ReferenceTarget returns the column a foreign key into table should point
+// at: its single-column declared primary key. SQLite rejects foreign keys
+// whose parent column is not a primary key or unique.
+func ReferenceTarget(db *sql.DB, table string) (string, bool) {
+	key, err := TableKey(db, table)
+	if err != nil || key.RowID || len(key.Columns) != 1 {
+		return "", false
+	}
+	return key.Columns[0], true
+}
+
+// CheckForeignKeys runs PRAGMA foreign_key_check on table (or on every table
+// when table is "") and reports the offending rows with the values that
+// point nowhere.
+func CheckForeignKeys(ctx context.Context, q Queryer, table string) ([]models.ConstraintViolation, error) {
+	check := "PRAGMA main.foreign_key_check"
+	if table != "" {
+		check += "(" + QuoteIdent(table) + ")"
+	}
+	rows, err := q.QueryContext(ctx, check)
+	if err != nil {
+		return nil, err
+	}
+
+	type failure struct {
+		table  string
+		rowid  sql.NullInt64
+		parent string
+		fkid   int
+	}
+	var failures []failure
+	for rows.Next() {
+		var f failure
+		if err := rows.Scan(&f.table, &f.rowid, &f.parent, &f.fkid); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if len(failures) < maxViolationsReported {
+			failures = append(failures, f)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Look the offending values up once the pragma's rows are closed, as a
+	// single-connection pool cannot hold two result sets
+	violations := []models.ConstraintViolation{}
+	for _, f := range failures {
+		cols, err := foreignKeyColumns(ctx, q, f.table, f.fkid)
+		if err != nil {
+			return nil, err
+		}
+		v := models.ConstraintViolation{Table: f.table, Kind: ViolationForeignKey, RowID: f.rowid.Int64, Columns: cols, TargetTable: f.parent}
+		if f.rowid.Valid && len(cols) > 0 {
+			v.Values = make([]interface{}, len(cols))
+			ptrs := make([]interface{}, len(cols))
+			for i := range v.Values {
+				ptrs[i] = &v.Values[i]
+			}
+			query := fmt.Sprintf("SELECT %s FROM %s WHERE rowid = ?", quoteAll(cols), QuoteIdent(f.table))
+			if err := q.QueryRowContext(ctx, query, f.rowid.Int64).Scan(ptrs...); err != nil {
+				return nil, err
+			}
+			textValues(v.Values)
+		}
+		violations = append(violations, v)
+	}
+	return violations, nil
+}
+
+// foreignKeyColumns lists the child columns of foreign key fkid of table
+func foreignKeyColumns(ctx context.Context, q Queryer, table string, fkid int) ([]string, error) {
+	rows, err := q.QueryContext(ctx, `SELECT "from" FROM pragma_foreign_key_list(?) WHERE id = ? ORDER BY seq`, table, fkid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cols []string
+	for rows.Next() {
+		var col string
+		if err := rows.Scan(&col); err != nil {
+			return nil, err
+		}
+		cols = append(cols, col)
+	}
+	return cols, rows.Err()
+}
+
+// DuplicateKeys reports the values of columns shared by more than one row
+func DuplicateKeys(ctx context.Context, q Queryer, table string, columns []string) ([]models.ConstraintViolation, error) {
+	list := quoteAll(columns)
+	query := fmt.Sprintf("SELECT %s, COUNT(*) FROM %s GROUP BY %s HAVING COUNT(*) > 1 LIMIT %d", list, QuoteIdent(table), list, maxViolationsReported)
+
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	violations := []models.ConstraintViolation{}
+	for rows.Next() {
+		v := models.ConstraintViolation{Table: table, Kind: ViolationPrimaryKey, Columns: columns, Values: make([]interface{}, len(columns))}
+		ptrs := make([]interface{}, len(columns)+1)
+		for i := range v.Values {
+			ptrs[i] = &v.Values[i]
+		}
+		ptrs[len(columns)] = &v.Count
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		textValues(v.Values)
+		violations = append(violations, v)
+	}
+	return violations, rows.Err()
+}
+
+// textValues turns scanned []byte values into strings so they read as text in JSON
+func textValues(values []interface{}) {
+	for i, v := range values {
+		if b, ok := v.([]byte); ok {
+			values[i] = string(b)
+		}
+	}
+}
+
+// WithoutForeignKeys runs fn on a connection with foreign key enforcement
+// switched off, as dropping or rebuilding a table other tables refer to
+// needs. The pragma is a no-op inside a transaction, so fn begins its own.
+func WithoutForeignKeys(ctx context.Context, db *sql.DB, fn func(conn *sql.Conn) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), "PRAGMA foreign_keys = ON")
+	return fn(conn)
+}
+
+// ForeignKeys lists the foreign keys table declares, one entry per column
+// pair of a composite key. TargetColumn is empty for a key that refers to
+// the parent's primary key implicitly.
+func ForeignKeys(ctx context.Context, q Queryer, table string) ([]models.ForeignKey, error) {
+	rows, err := q.QueryContext(ctx, `SELECT "from", "table", "to" FROM pragma_foreign_key_list(?) ORDER BY id, seq`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fks := []models.ForeignKey{}
+	for rows.Next() {
+		var fk models.ForeignKey
+		var to sql.NullString
+		if err := rows.Scan(&fk.Column, &fk.TargetTable, &to); err != nil {
+			return nil, err
+		}
+		fk.TargetColumn = to.String
+		fks = append(fks, fk)
+	}
+	return fks, rows.Err()
+}
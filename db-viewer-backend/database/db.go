@@ -4,21 +4,79 @@ import (
 	"database/sql"
 	"log"
 
+	"db-viewer/config"
+
 	_ "modernc.org/sqlite" // Import driver here
 )
 
-// DB is a global variable accessible by other packages
-var DB *sql.DB
+// DefaultWorkspace is the workspace used when a request does not name one
+const DefaultWorkspace = "default"
+
+// Sessions hands every client its own in-memory database in "memory" storage mode
+var Sessions *SessionManager
+
+// Workspaces manages the file-backed databases used in "file" storage mode
+var Workspaces *WorkspaceManager
 
-// InitDB initializes the SQLite connection
-func InitDB() {
+// InitDB initializes the storage selected by the configuration
+func InitDB(cfg config.Config) {
 	var err error
-	DB, err = sql.Open("sqlite", ":memory:")
+
+	if cfg.Storage == config.StorageFile {
+		Workspaces, err = NewWorkspaceManager(cfg.DataDir)
+		if err != nil {
+			log.Fatal("Failed to open workspace directory:", err)
+		}
+		if err = Workspaces.Create(DefaultWorkspace); err != nil && err != ErrWorkspaceExists {
+			log.Fatal("Failed to create default workspace:", err)
+		}
+		return
+	}
+
+	Sessions = NewSessionManager(cfg.SessionTTL, cfg.MaxSessions)
+}
+
+// CloseDB releases every open connection
+func CloseDB() {
+	if Sessions != nil {
+		Sessions.Close()
+	}
+	if Workspaces != nil {
+		Workspaces.Close()
+	}
+}
+
+// SQLite only enforces foreign keys when asked to, once per connection
+const foreignKeysPragma = "_pragma=foreign_keys(1)"
+
+// openMemory opens a private in-memory SQLite database
+func openMemory() (*sql.DB, error) {
+	db, err := sql.Open("sqlite", ":memory:?"+foreignKeysPragma)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every new connection to ":memory:" is a brand new, empty database,
+	// so the pool must never hand out more than one.
+	db.SetMaxOpenConns(1)
+
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// openFile opens (and creates, if needed) a SQLite database stored at path
+func openFile(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&"+foreignKeysPragma)
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		return nil, err
 	}
 
-	if err = DB.Ping(); err != nil {
-		log.Fatal("Failed to ping database:", err)
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
 	}
+	return db, nil
 }
@@ -0,0 +1,268 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"db-viewer/models"
+)
+
+// Operations of a query plan step
+const (
+	PlanScan        = "scan"
+	PlanSearch      = "search"
+	PlanTempBTree   = "temp_btree"
+	PlanSubquery    = "subquery"
+	PlanCoroutine   = "coroutine"
+	PlanMaterialize = "materialize"
+	PlanCompound    = "compound"
+	PlanMultiIndex  = "multi_index"
+	PlanBloomFilter = "bloom_filter"
+	PlanConstantRow = "constant_row"
+	PlanOther       = "other"
+)
+
+// Kinds of plan warnings
+const (
+	WarnFullScan           = "full_scan"
+	WarnAutomaticIndex     = "automatic_index"
+	WarnTempBTree          = "temp_btree"
+	WarnCorrelatedSubquery = "correlated_subquery"
+)
+
+// accessRegex reads the SCAN and SEARCH steps, e.g. "SEARCH o USING
+// COVERING INDEX orders_status (status=?)". Older SQLite versions print
+// "SCAN TABLE orders AS o".
+var accessRegex = regexp.MustCompile(`^(SCAN|SEARCH) (?:TABLE )?(\S+)(?: AS (\S+))?(?: USING (INTEGER PRIMARY KEY|PRIMARY KEY|(AUTOMATIC )?(?:PARTIAL )?(COVERING )?INDEX(?: ([^\s(]\S*))?))?(?: \((.*)\))?`)
+
+// subqueryRegex reads "CORRELATED SCALAR SUBQUERY 1" and the like
+var subqueryRegex = regexp.MustCompile(`^(CORRELATED )?(?:SCALAR|LIST) SUBQUERY(?: (\d+))?`)
+
+// aliasStopWords may follow a table name without being its alias
+var aliasStopWords = map[string]bool{
+	"WHERE": true, "JOIN": true, "INNER": true, "LEFT": true, "RIGHT": true, "FULL": true, "CROSS": true,
+	"NATURAL": true, "OUTER": true, "ON": true, "USING": true, "ORDER": true, "GROUP": true, "HAVING": true,
+	"LIMIT": true, "OFFSET": true, "UNION": true, "INTERSECT": true, "EXCEPT": true, "WINDOW": true,
+	"SET": true, "VALUES": true, "SELECT": true, "DEFAULT": true, "RETURNING": true, "INDEXED": true,
+	"NOT": true, "AS": true, "WHEN": true, "THEN": true, "ELSE": true, "END": true, "AND": true, "OR": true,
+}
+
+// ExplainQueryPlan runs EXPLAIN QUERY PLAN on stmt, which is not executed,
+// and returns its steps as a tree along with warnings about steps that tend
+// to be slow: full table scans, automatic indexes, temporary B-trees for
+// sorting and grouping, and correlated subqueries. stmt must be a single
+// statement; the driver would run anything after it in full.
+func ExplainQueryPlan(ctx context.Context, q Queryer, stmt string, args ...interface{}) (models.QueryPlan, error) {
+	plan := models.QueryPlan{Plan: []models.PlanNode{}, Warnings: []models.PlanWarning{}}
+
+	stmt, err := SingleStatement(stmt)
+	if err != nil {
+		return plan, err
+	}
+
+	names, err := TableNames(ctx, q)
+	if err != nil {
+		return plan, err
+	}
+	tables := make(map[string]string, len(names))
+	for _, name := range names {
+		tables[strings.ToLower(name)] = name
+	}
+	aliases := tableAliases(tokenize(stmt), tables)
+
+	rows, err := q.QueryContext(ctx, "EXPLAIN QUERY PLAN "+stmt, args...)
+	if err != nil {
+		return plan, err
+	}
+	defer rows.Close()
+
+	type entry struct {
+		node     models.PlanNode
+		children []*entry
+	}
+	var roots []*entry
+	byID := make(map[int]*entry)
+
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			return plan, err
+		}
+
+		e := &entry{node: planNode(id, detail, tables, aliases)}
+		byID[id] = e
+		if p, ok := byID[parent]; ok {
+			p.children = append(p.children, e)
+		} else {
+			roots = append(roots, e)
+		}
+		if w, ok := planWarning(e.node); ok {
+			plan.Warnings = append(plan.Warnings, w)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return plan, err
+	}
+
+	var build func(e *entry) models.PlanNode
+	build = func(e *entry) models.PlanNode {
+		node := e.node
+		node.Children = make([]models.PlanNode, len(e.children))
+		for i, child := range e.children {
+			node.Children[i] = build(child)
+		}
+		return node
+	}
+	for _, root := range roots {
+		plan.Plan = append(plan.Plan, build(root))
+	}
+	return plan, nil
+}
+
+// planNode parses the detail text of one step
+func planNode(id int, detail string, tables, aliases map[string]string) models.PlanNode {
+	node := models.PlanNode{ID: id, Detail: detail, Op: PlanOther}
+
+	switch {
+	case detail == "SCAN CONSTANT ROW":
+		node.Op = PlanConstantRow
+
+	case strings.HasPrefix(detail, "SCAN ") || strings.HasPrefix(detail, "SEARCH "):
+		node.Op = PlanScan
+		if strings.HasPrefix(detail, "SEARCH ") {
+			node.Op = PlanSearch
+		}
+		m := accessRegex.FindStringSubmatch(detail)
+		if m == nil {
+			break
+		}
+		node.Name = m[2]
+		if m[3] != "" {
+			node.Name = m[3]
+		}
+		node.Table = resolveTable(m[2], m[3], tables, aliases)
+		switch {
+		case m[4] == "INTEGER PRIMARY KEY" || m[4] == "PRIMARY KEY":
+			node.Index = m[4]
+		case m[4] != "":
+			node.Index = m[7]
+			node.Automatic = m[5] != ""
+			node.Covering = m[6] != ""
+		}
+		node.Constraint = m[8]
+
+	case strings.HasPrefix(detail, "USE TEMP B-TREE FOR "):
+		node.Op = PlanTempBTree
+		node.Purpose = strings.TrimPrefix(detail, "USE TEMP B-TREE FOR ")
+
+	case subqueryRegex.MatchString(detail):
+		m := subqueryRegex.FindStringSubmatch(detail)
+		node.Op = PlanSubquery
+		node.Correlated = m[1] != ""
+		node.Name = m[2]
+
+	case strings.HasPrefix(detail, "CO-ROUTINE "):
+		node.Op = PlanCoroutine
+		node.Name = strings.TrimPrefix(detail, "CO-ROUTINE ")
+
+	case strings.HasPrefix(detail, "MATERIALIZE "):
+		node.Op = PlanMaterialize
+		node.Name = strings.TrimPrefix(detail, "MATERIALIZE ")
+
+	case detail == "COMPOUND QUERY" || detail == "LEFT-MOST SUBQUERY" || strings.HasPrefix(detail, "UNION ") ||
+		strings.HasPrefix(detail, "INTERSECT ") || strings.HasPrefix(detail, "EXCEPT "):
+		node.Op = PlanCompound
+
+	case detail == "MULTI-INDEX OR" || strings.HasPrefix(detail, "INDEX "):
+		node.Op = PlanMultiIndex
+
+	case strings.Contains(detail, "BLOOM FILTER"):
+		node.Op = PlanBloomFilter
+	}
+	return node
+}
+
+// planWarning flags a step that is likely to be slow
+func planWarning(node models.PlanNode) (models.PlanWarning, bool) {
+	w := models.PlanWarning{NodeID: node.ID, Table: node.Table}
+
+	switch {
+	// A scan of a CTE or subquery reads rows already produced; only
+	// scanning a table without an index reads all of it
+	case node.Op == PlanScan && node.Table != "" && node.Index == "":
+		w.Kind = WarnFullScan
+		w.Message = "full table scan on " + node.Table
+
+	case node.Op == PlanSearch && node.Automatic:
+		w.Kind = WarnAutomaticIndex
+		w.Message = fmt.Sprintf("SQLite builds a temporary index on %s (%s) for every run", planSubject(node), node.Constraint)
+		if node.Table != "" {
+			w.Message += "; a permanent index on those columns would avoid it"
+		}
+
+	case node.Op == PlanTempBTree:
+		w.Kind = WarnTempBTree
+		w.Message = fmt.Sprintf("%s is done in a temporary B-tree; an index matching it would avoid the extra pass", node.Purpose)
+
+	case node.Op == PlanSubquery && node.Correlated:
+		w.Kind = WarnCorrelatedSubquery
+		w.Message = "correlated subquery runs once for every row of the outer query"
+		if node.Name != "" {
+			w.Message = "correlated subquery " + node.Name + " runs once for every row of the outer query"
+		}
+
+	default:
+		return w, false
+	}
+	return w, true
+}
+
+func planSubject(node models.PlanNode) string {
+	if node.Table != "" {
+		return node.Table
+	}
+	return node.Name
+}
+
+// resolveTable finds the table behind a name in a plan step: the name
+// itself, or the alias it was given in the statement. Names of CTEs and
+// subqueries resolve to nothing.
+func resolveTable(name, alias string, tables, aliases map[string]string) string {
+	if alias != "" {
+		return tables[strings.ToLower(name)]
+	}
+	if table, ok := aliases[strings.ToLower(name)]; ok {
+		return table
+	}
+	return tables[strings.ToLower(name)]
+}
+
+// tableAliases maps the aliases a statement gives its tables, as in
+// "FROM orders o" or "JOIN customers AS c", to the tables
+func tableAliases(tokens []sqlToken, tables map[string]string) map[string]string {
+	aliases := make(map[string]string)
+	for i, t := range tokens {
+		if !t.word && !t.quoted {
+			continue
+		}
+		table, ok := tables[strings.ToLower(t.text)]
+		if !ok {
+			continue
+		}
+
+		j := i + 1
+		if j < len(tokens) && tokens[j].word && tokens[j].text == "AS" {
+			j++
+		}
+		if j >= len(tokens) {
+			continue
+		}
+		if next := tokens[j]; next.quoted || (next.word && !aliasStopWords[next.text]) {
+			aliases[strings.ToLower(next.text)] = table
+		}
+	}
+	return aliases
+}
@@ -0,0 +1,42 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestExplainQueryPlanSingleStatement(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := db.Exec("CREATE TABLE t (a, begin)"); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	plan, err := ExplainQueryPlan(ctx, db, "SELECT * FROM t WHERE a = ?; -- trailing comment", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Plan) == 0 {
+		t.Error("empty plan")
+	}
+
+	// Only the first statement would be explained; the rest must not run
+	for _, stmt := range []string{
+		"SELECT 1; CREATE TABLE y (a)",
+		"CREATE TRIGGER tr AFTER UPDATE OF begin ON t BEGIN SELECT 1; END; CREATE TABLE y (a)",
+		"",
+	} {
+		if _, err := ExplainQueryPlan(ctx, db, stmt); !errors.Is(err, ErrNotSingleStatement) {
+			t.Errorf("ExplainQueryPlan(%q) = %v, want ErrNotSingleStatement", stmt, err)
+		}
+	}
+
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name IN ('y', 'tr')").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Error("a statement after the explained one ran")
+	}
+}
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"db-viewer/models"
+)
+
+// ErrInvalidFilter is returned for a filter that cannot be compiled
+var ErrInvalidFilter = errors.New("invalid filter")
+
+const (
+	// maxFilterDepth caps how deeply And and Or may nest
+	maxFilterDepth = 8
+	// maxFilterValues caps the values of an "in" condition
+	maxFilterValues = 1000
+)
+
+// comparisons maps the filter operators taking a single value to SQL
+var comparisons = map[string]string{
+	"eq":   "=",
+	"ne":   "<>",
+	"lt":   "<",
+	"lte":  "<=",
+	"gt":   ">",
+	"gte":  ">=",
+	"like": "LIKE",
+}
+
+// CompileFilter turns a filter on an already resolved table into a
+// parameterised SQL condition. Column names are resolved against the table
+// and only ever reach the SQL quoted; values are always bound.
+func CompileFilter(db *sql.DB, table string, f models.Filter) (string, []interface{}, error) {
+	return compileFilter(db, table, f, 0)
+}
+
+func compileFilter(db *sql.DB, table string, f models.Filter, depth int) (string, []interface{}, error) {
+	if depth > maxFilterDepth {
+		return "", nil, fmt.Errorf("%w: nested more than %d levels deep", ErrInvalidFilter, maxFilterDepth)
+	}
+
+	switch {
+	case len(f.And) > 0 || len(f.Or) > 0:
+		if f.Column != "" || f.Op != "" || (len(f.And) > 0 && len(f.Or) > 0) {
+			return "", nil, fmt.Errorf("%w: a filter is either a condition, an \"and\" or an \"or\"", ErrInvalidFilter)
+		}
+		parts, joiner := f.And, " AND "
+		if len(f.Or) > 0 {
+			parts, joiner = f.Or, " OR "
+		}
+		conds := make([]string, len(parts))
+		var args []interface{}
+		for i, part := range parts {
+			cond, partArgs, err := compileFilter(db, table, part, depth+1)
+			if err != nil {
+				return "", nil, err
+			}
+			conds[i] = cond
+			args = append(args, partArgs...)
+		}
+		return "(" + strings.Join(conds, joiner) + ")", args, nil
+
+	case f.Column == "":
+		return "", nil, fmt.Errorf("%w: a condition needs a column", ErrInvalidFilter)
+	}
+
+	col, err := ResolveColumn(db, table, f.Column)
+	if err != nil {
+		return "", nil, err
+	}
+	quoted := QuoteIdent(col)
+
+	op := strings.ToLower(f.Op)
+	switch op {
+	case "is_null":
+		return quoted + " IS NULL", nil, nil
+	case "not_null":
+		return quoted + " IS NOT NULL", nil, nil
+	case "in":
+		values, ok := f.Value.([]interface{})
+		if !ok || len(values) == 0 || len(values) > maxFilterValues {
+			return "", nil, fmt.Errorf("%w: \"in\" on %q needs a list of 1 to %d values", ErrInvalidFilter, col, maxFilterValues)
+		}
+		args := make([]interface{}, len(values))
+		for i, v := range values {
+			if args[i], err = filterValue(col, v); err != nil {
+				return "", nil, err
+			}
+		}
+		return fmt.Sprintf("%s IN (%s)", quoted, strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")), args, nil
+	}
+
+	sqlOp, ok := comparisons[op]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: unknown operator %q", ErrInvalidFilter, f.Op)
+	}
+	if f.Value == nil {
+		switch op {
+		case "eq":
+			return quoted + " IS NULL", nil, nil
+		case "ne":
+			return quoted + " IS NOT NULL", nil, nil
+		}
+		return "", nil, fmt.Errorf("%w: %q on %q needs a value", ErrInvalidFilter, op, col)
+	}
+	if _, isString := f.Value.(string); op == "like" && !isString {
+		return "", nil, fmt.Errorf("%w: \"like\" on %q needs a string pattern", ErrInvalidFilter, col)
+	}
+	v, err := filterValue(col, f.Value)
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("%s %s ?", quoted, sqlOp), []interface{}{v}, nil
+}
+
+// filterValue accepts the scalar JSON values a column can be compared with
+func filterValue(col string, v interface{}) (interface{}, error) {
+	switch v.(type) {
+	case string, float64, bool:
+		return KeyValue(v), nil
+	}
+	return nil, fmt.Errorf("%w: values compared with %q must be strings, numbers or booleans", ErrInvalidFilter, col)
+}
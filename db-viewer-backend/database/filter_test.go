@@ -0,0 +1,154 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"db-viewer/models"
+)
+
+func TestCompileFilter(t *testing.T) {
+	db := openTestDB(t)
+	for _, stmt := range []string{
+		`CREATE TABLE people (id INTEGER PRIMARY KEY, "Full Name" TEXT, age INTEGER, active BOOLEAN)`,
+		`INSERT INTO people VALUES (1, 'Ann', 30, 1), (2, 'Bob', NULL, 0), (3, 'Cy''s', 41, 1), (4, NULL, 17, NULL)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter models.Filter
+		sql    string
+		args   []interface{}
+		ids    []int64
+	}{
+		{
+			name:   "eq on a resolved column",
+			filter: models.Filter{Column: "full name", Op: "eq", Value: "Ann"},
+			sql:    `"Full Name" = ?`,
+			args:   []interface{}{"Ann"},
+			ids:    []int64{1},
+		},
+		{
+			name:   "whole numbers bound as integers",
+			filter: models.Filter{Column: "age", Op: "GTE", Value: 30.0},
+			sql:    `"age" >= ?`,
+			args:   []interface{}{int64(30)},
+			ids:    []int64{1, 3},
+		},
+		{
+			name:   "eq null",
+			filter: models.Filter{Column: "age", Op: "eq"},
+			sql:    `"age" IS NULL`,
+			ids:    []int64{2},
+		},
+		{
+			name:   "ne null",
+			filter: models.Filter{Column: "Full Name", Op: "ne"},
+			sql:    `"Full Name" IS NOT NULL`,
+			ids:    []int64{1, 2, 3},
+		},
+		{
+			name:   "like with a quote",
+			filter: models.Filter{Column: "Full Name", Op: "like", Value: "%'s"},
+			sql:    `"Full Name" LIKE ?`,
+			args:   []interface{}{"%'s"},
+			ids:    []int64{3},
+		},
+		{
+			name:   "in",
+			filter: models.Filter{Column: "id", Op: "in", Value: []interface{}{1.0, 4.0, "x"}},
+			sql:    `"id" IN (?, ?, ?)`,
+			args:   []interface{}{int64(1), int64(4), "x"},
+			ids:    []int64{1, 4},
+		},
+		{
+			name: "nested and/or",
+			filter: models.Filter{Or: []models.Filter{
+				{And: []models.Filter{{Column: "active", Op: "eq", Value: true}, {Column: "age", Op: "lt", Value: 40.0}}},
+				{Column: "active", Op: "is_null"},
+			}},
+			sql:  `(("active" = ? AND "age" < ?) OR "active" IS NULL)`,
+			args: []interface{}{true, int64(40)},
+			ids:  []int64{1, 4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cond, args, err := CompileFilter(db, "people", tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cond != tt.sql || !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("got %s %v, want %s %v", cond, args, tt.sql, tt.args)
+			}
+
+			rows, err := db.Query("SELECT id FROM people WHERE "+cond+" ORDER BY id", args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+			var ids []int64
+			for rows.Next() {
+				var id int64
+				if err := rows.Scan(&id); err != nil {
+					t.Fatal(err)
+				}
+				ids = append(ids, id)
+			}
+			if !reflect.DeepEqual(ids, tt.ids) {
+				t.Errorf("matched %v, want %v", ids, tt.ids)
+			}
+		})
+	}
+}
+
+func TestCompileFilterRejected(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := db.Exec("CREATE TABLE t (a TEXT, b INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+
+	deep := models.Filter{Column: "a", Op: "is_null"}
+	for i := 0; i <= maxFilterDepth; i++ {
+		deep = models.Filter{And: []models.Filter{deep}}
+	}
+	many := make([]interface{}, maxFilterValues+1)
+	for i := range many {
+		many[i] = "v"
+	}
+
+	tests := []struct {
+		name   string
+		filter models.Filter
+		want   error
+	}{
+		{"unknown operator", models.Filter{Column: "a", Op: "regexp", Value: "x"}, ErrInvalidFilter},
+		{"operator smuggling SQL", models.Filter{Column: "a", Op: "= 1 OR 1 =", Value: "x"}, ErrInvalidFilter},
+		{"no operator", models.Filter{Column: "a", Value: "x"}, ErrInvalidFilter},
+		{"unknown column", models.Filter{Column: "c", Op: "eq", Value: "x"}, ErrUnknownColumn},
+		{"column holding SQL", models.Filter{Column: "a = a OR 1", Op: "eq", Value: "x"}, ErrUnknownColumn},
+		{"unknown column in and", models.Filter{And: []models.Filter{{Column: "a", Op: "is_null"}, {Column: "rowid", Op: "eq", Value: 1.0}}}, ErrUnknownColumn},
+		{"no column", models.Filter{Op: "eq", Value: "x"}, ErrInvalidFilter},
+		{"condition and group", models.Filter{Column: "a", Op: "eq", And: []models.Filter{{Column: "a", Op: "is_null"}}}, ErrInvalidFilter},
+		{"and with or", models.Filter{And: []models.Filter{{Column: "a", Op: "is_null"}}, Or: []models.Filter{{Column: "b", Op: "is_null"}}}, ErrInvalidFilter},
+		{"lt without a value", models.Filter{Column: "b", Op: "lt"}, ErrInvalidFilter},
+		{"like on a number", models.Filter{Column: "a", Op: "like", Value: 1.0}, ErrInvalidFilter},
+		{"object value", models.Filter{Column: "a", Op: "eq", Value: map[string]interface{}{"x": 1.0}}, ErrInvalidFilter},
+		{"in without a list", models.Filter{Column: "a", Op: "in", Value: "x"}, ErrInvalidFilter},
+		{"in with an empty list", models.Filter{Column: "a", Op: "in", Value: []interface{}{}}, ErrInvalidFilter},
+		{"in with too many values", models.Filter{Column: "a", Op: "in", Value: many}, ErrInvalidFilter},
+		{"in with a list value", models.Filter{Column: "a", Op: "in", Value: []interface{}{[]interface{}{"x"}}}, ErrInvalidFilter},
+		{"nested too deep", deep, ErrInvalidFilter},
+	}
+	for _, tt := range tests {
+		cond, _, err := CompileFilter(db, "t", tt.filter)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %q, %v; want %v", tt.name, cond, err, tt.want)
+		}
+	}
+}
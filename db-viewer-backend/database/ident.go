@@ -0,0 +1,142 @@
+package database
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// userTables filters sqlite_master down to the tables users see: SQLite's
+// own and the viewer's metadata tables are hidden
+const userTables = `name NOT LIKE 'sqlite\_%' ESCAPE '\' AND name NOT LIKE '\_dbviewer\_%' ESCAPE '\'`
+
+var (
+	ErrUnknownTable  = errors.New("table not found")
+	ErrUnknownColumn = errors.New("column not found")
+	ErrInvalidIdent  = errors.New("invalid identifier")
+)
+
+// QuoteIdent quotes a table or column name for use in SQL text, doubling any
+// embedded double quotes. Names must never reach a query any other way.
+func QuoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// CleanIdent normalizes a user supplied name (file name, CSV header, form
+// field) before it is used to create a table or column: surrounding space is
+// trimmed, inner whitespace becomes underscores and control characters are
+// dropped. Names starting with sqlite_ or _dbviewer_ are reserved for SQLite
+// and the viewer. The result still has to be quoted with QuoteIdent.
+func CleanIdent(name string) (string, error) {
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(name) {
+		switch {
+		case unicode.IsSpace(r):
+			b.WriteRune('_')
+		case unicode.IsControl(r) || r == unicode.ReplacementChar:
+			continue
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	cleaned := b.String()
+	if cleaned == "" {
+		return "", fmt.Errorf("%w: %q", ErrInvalidIdent, name)
+	}
+	for _, prefix := range []string{"sqlite_", "_dbviewer_"} {
+		if strings.HasPrefix(strings.ToLower(cleaned), prefix) {
+			return "", fmt.Errorf("%w: %q uses the reserved %s prefix", ErrInvalidIdent, name, prefix)
+		}
+	}
+	return cleaned, nil
+}
+
+// CleanColumnNames runs CleanIdent over a header row, naming blank headers
+// after their position and suffixing duplicates so every column is unique.
+func CleanColumnNames(headers []string) []string {
+	names := make([]string, len(headers))
+	seen := make(map[string]bool)
+
+	for i, h := range headers {
+		name, err := CleanIdent(h)
+		if err != nil {
+			name = fmt.Sprintf("column_%d", i+1)
+		}
+
+		base := name
+		for n := 2; seen[strings.ToLower(name)]; n++ {
+			name = fmt.Sprintf("%s_%d", base, n)
+		}
+		seen[strings.ToLower(name)] = true
+		names[i] = name
+	}
+	return names
+}
+
+// ScratchTable names a table that only lives while another is rebuilt or
+// replaced. The _dbviewer_ prefix hides it and keeps uploads from claiming
+// the name, and the random suffix keeps two rebuilds apart, so it never
+// meets a user's table.
+func ScratchTable(purpose string) string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "_dbviewer_" + purpose + "_" + hex.EncodeToString(b)
+}
+
+// ResolveTable checks that a table exists and returns its stored spelling.
+// SQLite compares identifiers case-insensitively, so lookups do too.
+func ResolveTable(db *sql.DB, name string) (string, error) {
+	var stored string
+	err := db.QueryRow(
+		"SELECT name FROM sqlite_master WHERE type = 'table' AND "+userTables+" AND name = ? COLLATE NOCASE",
+		name,
+	).Scan(&stored)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("%w: %q", ErrUnknownTable, name)
+	}
+	if err != nil {
+		return "", err
+	}
+	return stored, nil
+}
+
+// ResolveColumn checks that a column exists on an already resolved table and
+// returns its stored spelling.
+func ResolveColumn(db *sql.DB, table, name string) (string, error) {
+	var stored string
+	err := db.QueryRow(
+		"SELECT name FROM pragma_table_info(?) WHERE name = ? COLLATE NOCASE",
+		table, name,
+	).Scan(&stored)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("%w: %q on table %q", ErrUnknownColumn, name, table)
+	}
+	if err != nil {
+		return "", err
+	}
+	return stored, nil
+}
+
+// TableColumns lists the column names of an already resolved table in order
+func TableColumns(db *sql.DB, table string) ([]string, error) {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?) ORDER BY cid", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cols []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		cols = append(cols, name)
+	}
+	return cols, rows.Err()
+}
@@ -0,0 +1,252 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+// hostileNames are names a file, header or form field may carry that are
+// fine in SQLite once quoted, and must never break out of the quotes
+var hostileNames = []string{
+	"order-date",
+	"group",
+	`a"b`,
+	`""`,
+	"x; DROP TABLE y",
+	"x'); DROP TABLE y; --",
+	"naïve 名前",
+	"[bracketed]",
+	"`backticked`",
+}
+
+func TestQuoteIdent(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"plain", `"plain"`},
+		{"order-date", `"order-date"`},
+		{"group", `"group"`},
+		{`a"b`, `"a""b"`},
+		{`""`, `""""""`},
+		{"x; DROP TABLE y", `"x; DROP TABLE y"`},
+		{"", `""`},
+	}
+	for _, tt := range tests {
+		if got := QuoteIdent(tt.name); got != tt.want {
+			t.Errorf("QuoteIdent(%q) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestQuoteIdentRoundTrip(t *testing.T) {
+	db := openTestDB(t)
+
+	for _, name := range hostileNames {
+		q := QuoteIdent(name)
+		if _, err := db.Exec("CREATE TABLE " + q + " (" + q + " TEXT)"); err != nil {
+			t.Fatalf("create table %q: %v", name, err)
+		}
+		if _, err := db.Exec("INSERT INTO "+q+" ("+q+") VALUES (?)", name); err != nil {
+			t.Fatalf("insert into %q: %v", name, err)
+		}
+
+		var got string
+		if err := db.QueryRow("SELECT " + q + " FROM " + q).Scan(&got); err != nil {
+			t.Fatalf("select from %q: %v", name, err)
+		}
+		if got != name {
+			t.Errorf("table %q holds %q", name, got)
+		}
+	}
+
+	// None of the names may have run as SQL of their own
+	var y int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'y'").Scan(&y); err != nil {
+		t.Fatal(err)
+	}
+	if y != 0 {
+		t.Error("a quoted name created table y")
+	}
+}
+
+func TestCleanIdent(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		invalid bool
+	}{
+		{name: "order-date", want: "order-date"},
+		{name: "group", want: "group"},
+		{name: `a"b`, want: `a"b`},
+		{name: "x; DROP TABLE y", want: "x;_DROP_TABLE_y"},
+		{name: "  padded name  ", want: "padded_name"},
+		{name: "tab\tand\nnewline", want: "tab_and_newline"},
+		{name: "bell\x07", want: "bell"},
+		{name: "bad\uFFFDbyte", want: "badbyte"},
+		{name: "Order", want: "Order"},
+		{name: "", invalid: true},
+		{name: "   ", invalid: true},
+		{name: "\t\n", invalid: true},
+		{name: "\x00\x01", invalid: true},
+		{name: "sqlite_master", invalid: true},
+		{name: "SQLite_Sequence", invalid: true},
+		{name: " sqlite_x", invalid: true},
+		{name: "_dbviewer_x", invalid: true},
+		{name: "_DBViewer_relationships", invalid: true},
+		{name: "sqlitex", want: "sqlitex"},
+		{name: "dbviewer_x", want: "dbviewer_x"},
+	}
+	for _, tt := range tests {
+		got, err := CleanIdent(tt.name)
+		if tt.invalid {
+			if !errors.Is(err, ErrInvalidIdent) {
+				t.Errorf("CleanIdent(%q) = %q, %v; want ErrInvalidIdent", tt.name, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("CleanIdent(%q) = %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestCleanColumnNames(t *testing.T) {
+	tests := []struct {
+		headers []string
+		want    []string
+	}{
+		{
+			headers: []string{"id", "order-date", "group", `a"b`},
+			want:    []string{"id", "order-date", "group", `a"b`},
+		},
+		{
+			headers: []string{"", "   ", "sqlite_rowid", "_dbviewer_x"},
+			want:    []string{"column_1", "column_2", "column_3", "column_4"},
+		},
+		{
+			headers: []string{"Name", "name", "NAME"},
+			want:    []string{"Name", "name_2", "NAME_3"},
+		},
+		{
+			headers: []string{"a b", "a_b", "a_b_2"},
+			want:    []string{"a_b", "a_b_2", "a_b_2_2"},
+		},
+		{
+			headers: []string{"", "column_1"},
+			want:    []string{"column_1", "column_1_2"},
+		},
+	}
+	for _, tt := range tests {
+		got := CleanColumnNames(tt.headers)
+		if len(got) != len(tt.want) {
+			t.Fatalf("CleanColumnNames(%q) = %q, want %q", tt.headers, got, tt.want)
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("CleanColumnNames(%q) = %q, want %q", tt.headers, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestResolveTable(t *testing.T) {
+	db := openTestDB(t)
+	for _, stmt := range []string{
+		`CREATE TABLE "Orders" (id INTEGER PRIMARY KEY, "order-date" TEXT, "group" TEXT, "a""b" TEXT)`,
+		`CREATE TABLE "x; DROP TABLE y" (v TEXT)`,
+		`CREATE TABLE "_dbviewer_x" (v TEXT)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		want    string
+		missing bool
+	}{
+		{name: "Orders", want: "Orders"},
+		{name: "orders", want: "Orders"},
+		{name: "ORDERS", want: "Orders"},
+		{name: "x; DROP TABLE y", want: "x; DROP TABLE y"},
+		{name: "X; drop table Y", want: "x; DROP TABLE y"},
+		{name: "sqlite_master", missing: true},
+		{name: "_dbviewer_x", missing: true},
+		{name: "Orders; DROP TABLE Orders", missing: true},
+		{name: "Orders' OR '1'='1", missing: true},
+		{name: "", missing: true},
+		{name: " ", missing: true},
+	}
+	for _, tt := range tests {
+		got, err := ResolveTable(db, tt.name)
+		if tt.missing {
+			if !errors.Is(err, ErrUnknownTable) {
+				t.Errorf("ResolveTable(%q) = %q, %v; want ErrUnknownTable", tt.name, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ResolveTable(%q) = %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+
+	if _, err := ResolveTable(db, "Orders"); err != nil {
+		t.Errorf("Orders was dropped: %v", err)
+	}
+}
+
+func TestResolveColumn(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := db.Exec(`CREATE TABLE "Orders" (id INTEGER PRIMARY KEY, "order-date" TEXT, "group" TEXT, "a""b" TEXT, "Total" REAL)`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		want    string
+		missing bool
+	}{
+		{name: "order-date", want: "order-date"},
+		{name: "ORDER-DATE", want: "order-date"},
+		{name: "group", want: "group"},
+		{name: `a"b`, want: `a"b`},
+		{name: `A"B`, want: `a"b`},
+		{name: "total", want: "Total"},
+		{name: "id", want: "id"},
+		{name: "rowid", missing: true},
+		{name: "order_date", missing: true},
+		{name: `"group"`, missing: true},
+		{name: "id; DROP TABLE Orders", missing: true},
+		{name: "", missing: true},
+	}
+	for _, tt := range tests {
+		got, err := ResolveColumn(db, "Orders", tt.name)
+		if tt.missing {
+			if !errors.Is(err, ErrUnknownColumn) {
+				t.Errorf("ResolveColumn(%q) = %q, %v; want ErrUnknownColumn", tt.name, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ResolveColumn(%q) = %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+
+	if _, err := ResolveColumn(db, "missing", "id"); !errors.Is(err, ErrUnknownColumn) {
+		t.Errorf("ResolveColumn on a missing table = %v; want ErrUnknownColumn", err)
+	}
+}
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := openMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidKey is returned when a row key does not match a table's primary key
+var ErrInvalidKey = errors.New("invalid row key")
+
+// Key describes how the rows of a table are identified
+type Key struct {
+	// Columns are the primary key columns in key order, or the rowid
+	// pseudo-column for a table without a declared primary key
+	Columns []string
+	// Types are the declared types of Columns
+	Types []string
+	// RowID is set when rows are identified by rowid instead of a declared key
+	RowID bool
+	// Generated is set when SQLite fills in the key of a new row itself:
+	// for rowid tables and a single INTEGER PRIMARY KEY column
+	Generated bool
+}
+
+// TableKey discovers the primary key of an already resolved table from
+// PRAGMA table_info, falling back to rowid when none is declared.
+func TableKey(db *sql.DB, table string) (*Key, error) {
+	rows, err := db.Query("SELECT name, type, pk FROM pragma_table_info(?) WHERE pk > 0 ORDER BY pk", table)
+	if err != nil {
+		return nil, err
+	}
+	key := &Key{}
+	for rows.Next() {
+		var name, typ string
+		var pk int
+		if err := rows.Scan(&name, &typ, &pk); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		key.Columns = append(key.Columns, name)
+		key.Types = append(key.Types, typ)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	withoutRowID, err := isWithoutRowID(db, table)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(key.Columns) == 0 {
+		alias, err := rowIDAlias(db, table)
+		if err != nil {
+			return nil, err
+		}
+		key.Columns = []string{alias}
+		key.Types = []string{"INTEGER"}
+		key.RowID = true
+		key.Generated = true
+		return key, nil
+	}
+
+	key.Generated = len(key.Columns) == 1 && strings.EqualFold(key.Types[0], "INTEGER") && !withoutRowID
+	return key, nil
+}
+
+// Condition builds the WHERE clause (without the keyword) selecting the row
+// with the given key. values is keyed by column name, case-insensitively;
+// when it is empty, id is used as the value of a single-column key.
+func (k *Key) Condition(values map[string]interface{}, id string) (string, []interface{}, error) {
+	if len(values) == 0 {
+		if len(k.Columns) > 1 {
+			return "", nil, fmt.Errorf("%w: the table is keyed on (%s); send a key object", ErrInvalidKey, strings.Join(k.Columns, ", "))
+		}
+		values = map[string]interface{}{k.Columns[0]: id}
+	}
+
+	byName := make(map[string]interface{}, len(values))
+	for name, v := range values {
+		byName[strings.ToLower(name)] = v
+	}
+
+	conds := make([]string, len(k.Columns))
+	args := make([]interface{}, len(k.Columns))
+	for i, col := range k.Columns {
+		v, ok := byName[strings.ToLower(col)]
+		if !ok {
+			return "", nil, fmt.Errorf("%w: missing key column %q", ErrInvalidKey, col)
+		}
+		delete(byName, strings.ToLower(col))
+		conds[i] = QuoteIdent(col) + " = ?"
+		args[i] = KeyValue(v)
+	}
+	if len(byName) > 0 {
+		var extra []string
+		for name := range byName {
+			extra = append(extra, name)
+		}
+		sort.Strings(extra)
+		return "", nil, fmt.Errorf("%w: %s is not part of the key (%s)", ErrInvalidKey, strings.Join(extra, ", "), strings.Join(k.Columns, ", "))
+	}
+	return strings.Join(conds, " AND "), args, nil
+}
+
+// SelectColumns is the select list that returns every column of a table,
+// plus the rowid when rows are identified by it
+func (k *Key) SelectColumns() string {
+	if k.RowID {
+		return k.Columns[0] + ", *"
+	}
+	return "*"
+}
+
+// KeyValue prepares a value decoded from JSON for comparison with a key
+// column: whole numbers are bound as integers, not floats.
+func KeyValue(v interface{}) interface{} {
+	if f, ok := v.(float64); ok && f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return int64(f)
+	}
+	return v
+}
+
+// rowIDAlias returns the first of rowid, _rowid_ and oid that no column of
+// table shadows
+func rowIDAlias(db *sql.DB, table string) (string, error) {
+	cols, err := TableColumns(db, table)
+	if err != nil {
+		return "", err
+	}
+	for _, alias := range []string{"rowid", "_rowid_", "oid"} {
+		shadowed := false
+		for _, col := range cols {
+			if strings.EqualFold(col, alias) {
+				shadowed = true
+			}
+		}
+		if !shadowed {
+			return alias, nil
+		}
+	}
+	return "", fmt.Errorf("%w: table %q shadows every rowid alias", ErrInvalidKey, table)
+}
+
+func isWithoutRowID(db *sql.DB, table string) (bool, error) {
+	var wr bool
+	err := db.QueryRow("SELECT wr FROM pragma_table_list WHERE schema = 'main' AND name = ?", table).Scan(&wr)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return wr, err
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// Lease is a claim on the database of a session or workspace. While any
+// lease on it is held the database is not closed, and its session is not
+// evicted nor its workspace renamed or deleted. Every lease must be
+// released once its request or job is done.
+type Lease struct {
+	DB *sql.DB
+
+	holder *holder
+	once   sync.Once
+}
+
+// holder counts the leases on one open database. mu is the lock of the
+// manager the database belongs to, which guards every field.
+type holder struct {
+	mu       *sync.Mutex
+	db       *sql.DB
+	leases   int
+	lastUsed time.Time
+	// dropped is set once the manager has let go of the database; it is
+	// closed when the last lease is released
+	dropped bool
+}
+
+func newHolder(mu *sync.Mutex, db *sql.DB) *holder {
+	return &holder{mu: mu, db: db, lastUsed: time.Now()}
+}
+
+// lease must be called with mu held
+func (h *holder) lease() *Lease {
+	h.leases++
+	h.lastUsed = time.Now()
+	return &Lease{DB: h.db, holder: h}
+}
+
+// drop must be called with mu held. The database is closed right away when
+// nothing uses it, otherwise by the release of the last lease.
+func (h *holder) drop() {
+	h.dropped = true
+	if h.leases == 0 {
+		h.db.Close()
+	}
+}
+
+// Extend takes another lease on the same database, for a background job
+// that outlives the request holding l
+func (l *Lease) Extend() *Lease {
+	l.holder.mu.Lock()
+	defer l.holder.mu.Unlock()
+	return l.holder.lease()
+}
+
+// Release gives the lease back; releasing it again does nothing
+func (l *Lease) Release() {
+	l.once.Do(func() {
+		h := l.holder
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		h.leases--
+		h.lastUsed = time.Now()
+		if h.dropped && h.leases == 0 {
+			h.db.Close()
+		}
+	})
+}
@@ -0,0 +1,294 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"db-viewer/models"
+)
+
+// ErrInvalidPage is returned for a limit, offset, sort or cursor that cannot be applied
+var ErrInvalidPage = errors.New("invalid page request")
+
+const (
+	// DefaultPageSize is how many rows a page holds unless asked otherwise
+	DefaultPageSize = 100
+	// MaxPageSize caps the rows of a single page
+	MaxPageSize = 1000
+)
+
+// SortColumn orders rows by Column
+type SortColumn struct {
+	Column string
+	Desc   bool
+}
+
+// PageRequest selects a page of a table's rows
+type PageRequest struct {
+	Limit  int
+	Offset int
+	// Cursor is the NextCursor of the previous page and replaces Offset
+	Cursor string
+	Sort   []SortColumn
+	Filter *models.Filter
+}
+
+// pageCursor is what a cursor encodes: the order it was made for and the
+// values of the order's columns in the last row of its page
+type pageCursor struct {
+	Order  string        `json:"o"`
+	Values []interface{} `json:"v"`
+}
+
+// ParseSort reads a sort parameter such as "name,-age": columns in order of
+// precedence, descending when prefixed with "-"
+func ParseSort(s string) []SortColumn {
+	var cols []SortColumn
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		col := SortColumn{Column: part}
+		if strings.HasPrefix(part, "-") {
+			col = SortColumn{Column: strings.TrimSpace(part[1:]), Desc: true}
+		}
+		cols = append(cols, col)
+	}
+	return cols
+}
+
+// ReadPage reads one page of an already resolved table. Rows are ordered by
+// req.Sort and then by the table's key, so the order is total and a cursor
+// can pick up right after the last row of a page (keyset pagination), which
+// unlike an offset stays fast and stable while rows are added.
+func ReadPage(ctx context.Context, db *sql.DB, table string, req PageRequest) (*models.TablePage, error) {
+	if req.Limit <= 0 {
+		req.Limit = DefaultPageSize
+	}
+	if req.Limit > MaxPageSize {
+		return nil, fmt.Errorf("%w: limit may be at most %d", ErrInvalidPage, MaxPageSize)
+	}
+	if req.Offset < 0 || (req.Offset > 0 && req.Cursor != "") {
+		return nil, fmt.Errorf("%w: pass either a non-negative offset or a cursor", ErrInvalidPage)
+	}
+
+	key, err := TableKey(db, table)
+	if err != nil {
+		return nil, err
+	}
+	order, err := pageOrder(db, table, key, req.Sort)
+	if err != nil {
+		return nil, err
+	}
+	orderSpec := orderString(order)
+
+	var conds []string
+	var args []interface{}
+	if req.Filter != nil {
+		cond, filterArgs, err := CompileFilter(db, table, *req.Filter)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+		args = append(args, filterArgs...)
+	}
+
+	page := &models.TablePage{Rows: []map[string]interface{}{}, Limit: req.Limit, Offset: req.Offset}
+	countQuery := "SELECT COUNT(*) FROM " + QuoteIdent(table) + whereClause(conds)
+	if err := db.QueryRowContext(ctx, countQuery, args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+
+	if req.Cursor != "" {
+		values, err := decodeCursor(req.Cursor, orderSpec, len(order))
+		if err != nil {
+			return nil, err
+		}
+		cond, cursorArgs := keysetCondition(order, values)
+		conds = append(conds, cond)
+		args = append(args, cursorArgs...)
+	}
+
+	// The order columns are selected once more at the end of each row, as
+	// plain expressions so the driver hands their stored values back as
+	// they are (a DATE column would come back as a time.Time otherwise)
+	selectList := key.SelectColumns()
+	orderBy := make([]string, len(order))
+	for i, col := range order {
+		selectList += ", +" + QuoteIdent(col.Column)
+		orderBy[i] = QuoteIdent(col.Column)
+		if col.Desc {
+			orderBy[i] += " DESC"
+		}
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s LIMIT %d OFFSET %d",
+		selectList, QuoteIdent(table), whereClause(conds), strings.Join(orderBy, ", "), req.Limit+1, req.Offset)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	width := len(cols) - len(order)
+	page.Columns = cols[:width]
+
+	var last []interface{}
+	for rows.Next() {
+		if len(page.Rows) == req.Limit {
+			// The extra row only tells that there is a next page
+			cursor, err := encodeCursor(orderSpec, last)
+			if err != nil {
+				return nil, err
+			}
+			page.NextCursor = cursor
+			break
+		}
+
+		values := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		textValues(values)
+
+		entry := make(map[string]interface{}, width)
+		for i, col := range page.Columns {
+			entry[col] = values[i]
+		}
+		page.Rows = append(page.Rows, entry)
+		last = values[width:]
+	}
+	return page, rows.Err()
+}
+
+// pageOrder resolves the sort columns and appends the key columns that are
+// not among them
+func pageOrder(db *sql.DB, table string, key *Key, sort []SortColumn) ([]SortColumn, error) {
+	var order []SortColumn
+	seen := make(map[string]bool)
+	for _, s := range sort {
+		col := s.Column
+		if !key.RowID || !strings.EqualFold(col, key.Columns[0]) {
+			resolved, err := ResolveColumn(db, table, col)
+			if err != nil {
+				return nil, err
+			}
+			col = resolved
+		}
+		if seen[strings.ToLower(col)] {
+			return nil, fmt.Errorf("%w: %q is sorted on twice", ErrInvalidPage, col)
+		}
+		seen[strings.ToLower(col)] = true
+		order = append(order, SortColumn{Column: col, Desc: s.Desc})
+	}
+	for _, col := range key.Columns {
+		if !seen[strings.ToLower(col)] {
+			order = append(order, SortColumn{Column: col})
+		}
+	}
+	return order, nil
+}
+
+// orderString writes an order the way ParseSort reads it
+func orderString(order []SortColumn) string {
+	parts := make([]string, len(order))
+	for i, col := range order {
+		parts[i] = col.Column
+		if col.Desc {
+			parts[i] = "-" + col.Column
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// keysetCondition selects the rows that come after values in order. SQLite
+// sorts NULLs first, so they come after everything when descending and
+// before everything when ascending.
+func keysetCondition(order []SortColumn, values []interface{}) (string, []interface{}) {
+	var alternatives []string
+	var args []interface{}
+	for i, col := range order {
+		var conds []string
+		for j := 0; j < i; j++ {
+			conds = append(conds, QuoteIdent(order[j].Column)+" IS ?")
+			args = append(args, values[j])
+		}
+
+		quoted := QuoteIdent(col.Column)
+		switch {
+		case !col.Desc && values[i] == nil:
+			conds = append(conds, quoted+" IS NOT NULL")
+		case !col.Desc:
+			conds = append(conds, quoted+" > ?")
+			args = append(args, values[i])
+		case values[i] == nil:
+			conds = append(conds, "0")
+		default:
+			conds = append(conds, "("+quoted+" < ? OR "+quoted+" IS NULL)")
+			args = append(args, values[i])
+		}
+		alternatives = append(alternatives, "("+strings.Join(conds, " AND ")+")")
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+func whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+func encodeCursor(order string, values []interface{}) (string, error) {
+	b, err := json.Marshal(pageCursor{Order: order, Values: values})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodeCursor reads a cursor back, checking it was made for the same order
+func decodeCursor(s, order string, n int) ([]interface{}, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidPage)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var cursor pageCursor
+	if err := dec.Decode(&cursor); err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidPage)
+	}
+	if cursor.Order != order {
+		return nil, fmt.Errorf("%w: the cursor was made for another sort order", ErrInvalidPage)
+	}
+	if len(cursor.Values) != n {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidPage)
+	}
+
+	for i, v := range cursor.Values {
+		if num, ok := v.(json.Number); ok {
+			if n, err := num.Int64(); err == nil {
+				cursor.Values[i] = n
+			} else if f, err := num.Float64(); err == nil {
+				cursor.Values[i] = f
+			}
+		}
+	}
+	return cursor.Values, nil
+}
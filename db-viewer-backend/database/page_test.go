@@ -0,0 +1,175 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"db-viewer/models"
+)
+
+func openPageDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db := openTestDB(t)
+	for _, stmt := range []string{
+		// No declared key: rows are told apart by rowid
+		"CREATE TABLE plain (name TEXT, n INTEGER)",
+		`INSERT INTO plain VALUES ('a', 2), ('b', NULL), ('c', 1), ('d', 2), ('e', NULL), ('f', 3), ('g', 1)`,
+		// A composite key; SQLite lets the columns of a rowid table's
+		// primary key hold NULL
+		"CREATE TABLE pairs (a TEXT, b INTEGER, v TEXT, PRIMARY KEY (a, b))",
+		`INSERT INTO pairs VALUES ('x', 2, 'x2'), (NULL, 1, 'n1'), ('x', 1, 'x1'), ('y', NULL, 'yn'), (NULL, 2, 'n2'), ('y', 1, 'y1'), (NULL, NULL, 'nn')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// readPages follows the cursors from the first page to the last, collecting
+// col of every row
+func readPages(t *testing.T, db *sql.DB, table, col string, req PageRequest) []interface{} {
+	t.Helper()
+	var got []interface{}
+	for pages := 0; ; pages++ {
+		if pages > 20 {
+			t.Fatal("the cursors never reach the last page")
+		}
+		page, err := ReadPage(context.Background(), db, table, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range page.Rows {
+			got = append(got, row[col])
+		}
+		if page.NextCursor == "" {
+			return got
+		}
+		req.Cursor = page.NextCursor
+	}
+}
+
+func TestReadPageCursorRoundTrip(t *testing.T) {
+	db := openPageDB(t)
+
+	tests := []struct {
+		table string
+		col   string
+		sort  string
+		want  []interface{}
+	}{
+		{table: "plain", col: "name", want: []interface{}{"a", "b", "c", "d", "e", "f", "g"}},
+		{table: "plain", col: "name", sort: "n", want: []interface{}{"b", "e", "c", "g", "a", "d", "f"}},
+		{table: "plain", col: "name", sort: "-n", want: []interface{}{"f", "a", "d", "c", "g", "b", "e"}},
+		{table: "plain", col: "name", sort: "-n,-name", want: []interface{}{"f", "d", "a", "g", "c", "e", "b"}},
+		{table: "plain", col: "name", sort: "-rowid", want: []interface{}{"g", "f", "e", "d", "c", "b", "a"}},
+		{table: "pairs", col: "v", want: []interface{}{"nn", "n1", "n2", "x1", "x2", "yn", "y1"}},
+		{table: "pairs", col: "v", sort: "-a", want: []interface{}{"yn", "y1", "x1", "x2", "nn", "n1", "n2"}},
+		{table: "pairs", col: "v", sort: "-b,v", want: []interface{}{"n2", "x2", "n1", "x1", "y1", "nn", "yn"}},
+	}
+	for _, tt := range tests {
+		for _, limit := range []int{1, 2, 3, 7} {
+			t.Run(fmt.Sprintf("%s sorted on %q by %d", tt.table, tt.sort, limit), func(t *testing.T) {
+				got := readPages(t, db, tt.table, tt.col, PageRequest{Limit: limit, Sort: ParseSort(tt.sort)})
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestReadPageRowIDFallback(t *testing.T) {
+	db := openPageDB(t)
+
+	page, err := ReadPage(context.Background(), db, "plain", PageRequest{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"rowid", "name", "n"}; !reflect.DeepEqual(page.Columns, want) {
+		t.Errorf("columns %q, want %q", page.Columns, want)
+	}
+	if page.Total != 7 || len(page.Rows) != 2 || page.Rows[1]["rowid"] != int64(2) {
+		t.Errorf("page %+v, want rows 1 and 2 of 7", page)
+	}
+
+	// A row added before the cursor's position does not shift the next page
+	if _, err := db.Exec("INSERT INTO plain (rowid, name) VALUES (0, 'z')"); err != nil {
+		t.Fatal(err)
+	}
+	next, err := ReadPage(context.Background(), db, "plain", PageRequest{Limit: 2, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(next.Rows) != 2 || next.Rows[0]["name"] != "c" {
+		t.Errorf("next page %+v, want c and d", next.Rows)
+	}
+}
+
+func TestReadPageFiltered(t *testing.T) {
+	db := openPageDB(t)
+	req := PageRequest{Limit: 1, Sort: ParseSort("-n"), Filter: &models.Filter{Column: "n", Op: "not_null"}}
+	got := readPages(t, db, "plain", "name", req)
+	if want := []interface{}{"f", "a", "d", "c", "g"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	page, err := ReadPage(context.Background(), db, "plain", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 5 {
+		t.Errorf("total %d, want the 5 rows matching the filter", page.Total)
+	}
+}
+
+func TestReadPageRejected(t *testing.T) {
+	db := openPageDB(t)
+	first, err := ReadPage(context.Background(), db, "plain", PageRequest{Limit: 1, Sort: ParseSort("n")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		req  PageRequest
+		want error
+	}{
+		{"limit too large", PageRequest{Limit: MaxPageSize + 1}, ErrInvalidPage},
+		{"negative offset", PageRequest{Offset: -1}, ErrInvalidPage},
+		{"offset and cursor", PageRequest{Offset: 1, Cursor: first.NextCursor, Sort: ParseSort("n")}, ErrInvalidPage},
+		{"cursor of another order", PageRequest{Cursor: first.NextCursor, Sort: ParseSort("-n")}, ErrInvalidPage},
+		{"cursor not base64", PageRequest{Cursor: "not a cursor!"}, ErrInvalidPage},
+		{"cursor not JSON", PageRequest{Cursor: "bm90IGpzb24"}, ErrInvalidPage},
+		{"cursor with too few values", PageRequest{Cursor: mustCursor(t, "n,rowid", 1), Sort: ParseSort("n")}, ErrInvalidPage},
+		{"column sorted twice", PageRequest{Sort: ParseSort("n,-N")}, ErrInvalidPage},
+		{"unknown sort column", PageRequest{Sort: ParseSort("missing")}, ErrUnknownColumn},
+		{"quoted sort column", PageRequest{Sort: ParseSort(`"n"`)}, ErrUnknownColumn},
+	}
+	for _, tt := range tests {
+		if _, err := ReadPage(context.Background(), db, "plain", tt.req); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	got := ParseSort(" name , -age,,- id ")
+	want := []SortColumn{{Column: "name"}, {Column: "age", Desc: true}, {Column: "id", Desc: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseSort = %+v, want %+v", got, want)
+	}
+}
+
+func mustCursor(t *testing.T, order string, values ...interface{}) string {
+	t.Helper()
+	cursor, err := encodeCursor(order, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cursor
+}
@@ -0,0 +1,203 @@
+package database
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidParams is returned for query parameters that cannot be bound
+var ErrInvalidParams = errors.New("invalid query parameters")
+
+// paramNameRegex is what database/sql accepts as the name of an argument
+var paramNameRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// typedParam spells out the type of a parameter the JSON value alone does
+// not pin down, such as a blob or an integer too large for a float
+type typedParam struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// BindParams turns the JSON parameters of a query into arguments for the
+// driver. An array binds ?, ?NNN and $NNN placeholders by position; an
+// object binds :name, @name and $name placeholders by name, its keys given
+// with or without the prefix. Values are JSON scalars or typed values such
+// as {"type": "blob", "value": "aGk="}, with type one of integer, real,
+// text, blob (base64), boolean or null.
+func BindParams(raw json.RawMessage) ([]interface{}, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+
+	switch raw[0] {
+	case '[':
+		var values []json.RawMessage
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+		}
+
+		args := make([]interface{}, len(values))
+		for i, v := range values {
+			arg, err := paramValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("%w: parameter %d: %v", ErrInvalidParams, i+1, err)
+			}
+			args[i] = arg
+		}
+		return args, nil
+
+	case '{':
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidParams, err)
+		}
+
+		args := make([]interface{}, 0, len(values))
+		seen := make(map[string]string, len(values))
+		for key, v := range values {
+			name := strings.TrimLeft(key, ":@$")
+			if !paramNameRegex.MatchString(name) {
+				return nil, fmt.Errorf("%w: %q is not a parameter name", ErrInvalidParams, key)
+			}
+			if other, ok := seen[name]; ok {
+				return nil, fmt.Errorf("%w: %q and %q name the same parameter", ErrInvalidParams, other, key)
+			}
+			seen[name] = key
+			arg, err := paramValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("%w: parameter %s: %v", ErrInvalidParams, key, err)
+			}
+			args = append(args, sql.Named(name, arg))
+		}
+		return args, nil
+	}
+
+	return nil, fmt.Errorf("%w: params must be an array or an object", ErrInvalidParams)
+}
+
+// NamedParams reports whether args bind placeholders by name
+func NamedParams(args []interface{}) bool {
+	for _, arg := range args {
+		if _, ok := arg.(sql.NamedArg); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func paramValue(raw json.RawMessage) (interface{}, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && raw[0] == '{' {
+		var typed typedParam
+		if err := strictUnmarshal(raw, &typed); err != nil {
+			return nil, err
+		}
+		return typedValue(typed)
+	}
+
+	var v interface{}
+	if err := strictUnmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	switch v := v.(type) {
+	case nil, string, bool:
+		return v, nil
+	case json.Number:
+		return numberValue(v)
+	}
+	return nil, errors.New("arrays are not a parameter value")
+}
+
+func typedValue(p typedParam) (interface{}, error) {
+	if p.Type == "null" {
+		return nil, nil
+	}
+	if len(p.Value) == 0 {
+		return nil, fmt.Errorf("a %s parameter needs a value", p.Type)
+	}
+
+	var v interface{}
+	if err := strictUnmarshal(p.Value, &v); err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, nil
+	}
+
+	// Numbers may come as strings so integers beyond 2^53 survive clients
+	// that only have floats
+	text, isString := v.(string)
+	number, isNumber := v.(json.Number)
+	if isNumber {
+		text = number.String()
+	}
+
+	switch p.Type {
+	case "integer":
+		if !isString && !isNumber {
+			break
+		}
+		n, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", text)
+		}
+		return n, nil
+	case "real":
+		if !isString && !isNumber {
+			break
+		}
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", text)
+		}
+		return f, nil
+	case "text":
+		if b, ok := v.(bool); ok {
+			return fmt.Sprint(b), nil
+		}
+		if isString || isNumber {
+			return text, nil
+		}
+	case "blob":
+		if !isString {
+			break
+		}
+		b, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return nil, errors.New("blob values must be base64")
+		}
+		return b, nil
+	case "boolean":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown type %q", p.Type)
+	}
+	return nil, fmt.Errorf("%s is not a valid %s value", p.Value, p.Type)
+}
+
+// numberValue keeps integral JSON numbers as integers
+func numberValue(n json.Number) (interface{}, error) {
+	if i, err := n.Int64(); err == nil {
+		return i, nil
+	}
+	return n.Float64()
+}
+
+// strictUnmarshal decodes with numbers kept as json.Number and unknown
+// fields rejected
+func strictUnmarshal(raw json.RawMessage, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBindParams(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want []interface{}
+	}{
+		{name: "absent", raw: "", want: nil},
+		{name: "null", raw: " null ", want: nil},
+		{name: "empty array", raw: "[]", want: []interface{}{}},
+		{
+			name: "array of scalars",
+			raw:  `[1, 2.5, "x", true, null, -0, 1e3]`,
+			want: []interface{}{int64(1), 2.5, "x", true, nil, int64(0), float64(1000)},
+		},
+		{
+			name: "object with and without prefixes",
+			raw:  `{":a": 1, "@b": "x", "$c": null, "d": false}`,
+			want: []interface{}{sql.Named("a", int64(1)), sql.Named("b", "x"), sql.Named("c", nil), sql.Named("d", false)},
+		},
+		{
+			name: "typed values",
+			raw: `[{"type": "integer", "value": "9007199254740993"}, {"type": "integer", "value": 7},
+				{"type": "real", "value": "1.5"}, {"type": "real", "value": 2},
+				{"type": "text", "value": 12}, {"type": "text", "value": true},
+				{"type": "blob", "value": "aGk="}, {"type": "boolean", "value": false},
+				{"type": "null"}, {"type": "integer", "value": null}]`,
+			want: []interface{}{int64(9007199254740993), int64(7), 1.5, 2.0, "12", "true", []byte("hi"), false, nil, nil},
+		},
+		{
+			name: "typed value in an object",
+			raw:  `{"data": {"type": "blob", "value": ""}}`,
+			want: []interface{}{sql.Named("data", []byte{})},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BindParams(json.RawMessage(tt.raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Object keys come in no particular order
+			if len(got) > 0 && NamedParams(got) {
+				got = byName(got)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BindParams(%s) = %#v, want %#v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindParamsRejected(t *testing.T) {
+	for _, raw := range []string{
+		`"x"`,
+		`1`,
+		`[1,`,
+		`[[1]]`,
+		`[{"a": 1}]`,
+		`[{"type": "integer"}]`,
+		`[{"type": "integer", "value": "1.5"}]`,
+		`[{"type": "integer", "value": "99999999999999999999"}]`,
+		`[{"type": "integer", "value": true}]`,
+		`[{"type": "real", "value": "abc"}]`,
+		`[{"type": "blob", "value": "not base64!"}]`,
+		`[{"type": "blob", "value": 1}]`,
+		`[{"type": "boolean", "value": "true"}]`,
+		`[{"type": "date", "value": "2024-01-01"}]`,
+		`[{"type": "text", "value": "x", "extra": 1}]`,
+		`{"1a": 1}`,
+		`{"a-b": 1}`,
+		`{"": 1}`,
+		`{"a b": 1}`,
+		`{":a": 1, "@a": 2}`,
+		`{"a": [1]}`,
+	} {
+		if args, err := BindParams(json.RawMessage(raw)); !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("BindParams(%s) = %v, %v; want ErrInvalidParams", raw, args, err)
+		}
+	}
+}
+
+// TestBindParamsQuery runs bound parameters against SQLite: positional ones
+// fill ?, ?NNN and $NNN, named ones any prefix, and a placeholder left
+// without a value fails the query
+func TestBindParamsQuery(t *testing.T) {
+	db := openTestDB(t)
+
+	tests := []struct {
+		query string
+		raw   string
+		want  []interface{}
+		fails bool
+	}{
+		{query: "SELECT ?, ?", raw: `[1, "x"]`, want: []interface{}{int64(1), "x"}},
+		{query: "SELECT ?2, ?1", raw: `[1, 2]`, want: []interface{}{int64(2), int64(1)}},
+		{query: "SELECT :a, @b", raw: `{"a": 1, "@b": 2}`, want: []interface{}{int64(1), int64(2)}},
+		{query: "SELECT :a, $a", raw: `{"@a": 3}`, want: []interface{}{int64(3), int64(3)}},
+		{query: "SELECT typeof(?), length(?)", raw: `[{"type": "text", "value": 10}, {"type": "blob", "value": "AAEC"}]`, want: []interface{}{"text", int64(3)}},
+		{query: "SELECT ?, ?", raw: `[1]`, fails: true},
+		{query: "SELECT ?, ?", raw: `[]`, fails: true},
+		{query: "SELECT ?3, ?1", raw: `[1, 2]`, fails: true},
+		{query: "SELECT :a, :b", raw: `{"a": 1}`, fails: true},
+		{query: "SELECT :a, :b", raw: `{"a": 1, "c": 2}`, fails: true},
+	}
+	for _, tt := range tests {
+		args, err := BindParams(json.RawMessage(tt.raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]interface{}, 2)
+		err = db.QueryRow(tt.query, args...).Scan(&got[0], &got[1])
+		switch {
+		case tt.fails && err == nil:
+			t.Errorf("%s with %s ran, want an error", tt.query, tt.raw)
+		case !tt.fails && err != nil:
+			t.Errorf("%s with %s: %v", tt.query, tt.raw, err)
+		case !tt.fails && !reflect.DeepEqual(got, tt.want):
+			t.Errorf("%s with %s = %#v, want %#v", tt.query, tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestNamedParams(t *testing.T) {
+	if !NamedParams(nil) {
+		t.Error("no parameters are not named")
+	}
+	if NamedParams([]interface{}{sql.Named("a", 1), 2}) {
+		t.Error("a positional parameter counts as named")
+	}
+}
+
+// byName sorts named arguments by name
+func byName(args []interface{}) []interface{} {
+	sorted := slices.Clone(args)
+	slices.SortFunc(sorted, func(a, b interface{}) int {
+		return strings.Compare(a.(sql.NamedArg).Name, b.(sql.NamedArg).Name)
+	})
+	return sorted
+}
@@ -0,0 +1,371 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"db-viewer/config"
+)
+
+// ErrNotAllowed is returned for a statement the query policy rejects
+var ErrNotAllowed = errors.New("statement not allowed")
+
+// Statement kinds a query policy allows or rejects
+const (
+	KindSelect      = "select"
+	KindInsert      = "insert"
+	KindUpdate      = "update"
+	KindDelete      = "delete"
+	KindCreate      = "create"
+	KindDrop        = "drop"
+	KindAlter       = "alter"
+	KindTransaction = "transaction"
+	KindPragma      = "pragma"
+	KindPragmaSet   = "pragma_set"
+	KindExplain     = "explain"
+	KindAnalyze     = "analyze"
+	KindVacuum      = "vacuum"
+	KindReindex     = "reindex"
+	KindAttach      = "attach"
+	KindDetach      = "detach"
+	KindOther       = "other"
+)
+
+// statementKinds lists the kinds an allowlist may name. ATTACH and DETACH
+// are left out: they reach files outside the database and are never allowed.
+var statementKinds = []string{
+	KindSelect, KindInsert, KindUpdate, KindDelete, KindCreate, KindDrop, KindAlter, KindTransaction,
+	KindPragma, KindPragmaSet, KindExplain, KindAnalyze, KindVacuum, KindReindex, KindOther,
+}
+
+// readKinds are the kinds that leave the database as it was
+var readKinds = []string{KindSelect, KindPragma, KindExplain}
+
+// defaultKinds are what each role may run unless the configuration says otherwise
+var defaultKinds = map[string][]string{
+	config.RoleReader: readKinds,
+	config.RoleEditor: append(slices.Clone(readKinds), KindInsert, KindUpdate, KindDelete, KindTransaction),
+	config.RoleAdmin:  statementKinds,
+}
+
+// infoPragmas take an argument without changing anything, e.g. table_info(t)
+var infoPragmas = map[string]bool{
+	"TABLE_INFO": true, "TABLE_XINFO": true, "TABLE_LIST": true, "INDEX_LIST": true, "INDEX_INFO": true,
+	"INDEX_XINFO": true, "FOREIGN_KEY_LIST": true, "FOREIGN_KEY_CHECK": true, "INTEGRITY_CHECK": true,
+	"QUICK_CHECK": true,
+}
+
+// lockedPragmas are never set through a query: they would turn off the
+// checks the viewer relies on, corrupt the schema or reach the file system
+var lockedPragmas = map[string]bool{
+	"WRITABLE_SCHEMA": true, "TRUSTED_SCHEMA": true, "FOREIGN_KEYS": true, "QUERY_ONLY": true,
+	"IGNORE_CHECK_CONSTRAINTS": true, "LEGACY_ALTER_TABLE": true, "JOURNAL_MODE": true,
+	"LOCKING_MODE": true, "MMAP_SIZE": true, "TEMP_STORE_DIRECTORY": true, "DATA_STORE_DIRECTORY": true,
+}
+
+// QueryPolicy decides which statements a role may run
+type QueryPolicy struct {
+	Role    string
+	allowed map[string]bool
+}
+
+// NewQueryPolicy builds the policy of role. kinds replaces the role's
+// default allowlist when it is not nil.
+func NewQueryPolicy(role string, kinds []string) (*QueryPolicy, error) {
+	if kinds == nil {
+		var ok bool
+		if kinds, ok = defaultKinds[role]; !ok {
+			return nil, fmt.Errorf("unknown query role %q", role)
+		}
+	}
+
+	p := &QueryPolicy{Role: role, allowed: make(map[string]bool, len(kinds))}
+	for _, kind := range kinds {
+		if !slices.Contains(statementKinds, kind) {
+			return nil, fmt.Errorf("role %s: unknown statement kind %q", role, kind)
+		}
+		p.allowed[kind] = true
+	}
+	return p, nil
+}
+
+// ReadOnly reports whether the policy only allows statements that read
+func (p *QueryPolicy) ReadOnly() bool {
+	for kind := range p.allowed {
+		if !slices.Contains(readKinds, kind) {
+			return false
+		}
+	}
+	return true
+}
+
+// Allows reports whether the role may run statements of the given kind
+func (p *QueryPolicy) Allows(kind string) bool {
+	return p.allowed[kind]
+}
+
+// Check classifies stmt and rejects it with ErrNotAllowed unless the role
+// may run it. ATTACH, DETACH, load_extension(), VACUUM INTO and setting the
+// locked pragmas are rejected for every role. An EXPLAIN is checked by the
+// statement it explains, since SQLite applies pragmas and attaches while
+// preparing it, and it may not explain setting a pragma.
+func (p *QueryPolicy) Check(stmt string) (string, error) {
+	tokens := tokenize(stmt)
+	kind := classify(tokens)
+
+	// SQLite also takes a quoted identifier as a function name
+	for i, t := range tokens {
+		if strings.EqualFold(t.text, "load_extension") && i+1 < len(tokens) && tokens[i+1].text == "(" {
+			return kind, fmt.Errorf("%w: load_extension() is never allowed", ErrNotAllowed)
+		}
+	}
+
+	if kind != KindExplain {
+		return kind, p.check(kind, tokens)
+	}
+
+	inner := explained(tokens)
+	innerKind := classify(inner)
+	if innerKind == KindPragmaSet {
+		return kind, fmt.Errorf("%w: EXPLAIN cannot set pragmas", ErrNotAllowed)
+	}
+	if err := p.check(innerKind, inner); err != nil {
+		return kind, err
+	}
+	return kind, p.check(kind, tokens)
+}
+
+// check applies the rules for every role and then the allowlist to a
+// statement of the given kind
+func (p *QueryPolicy) check(kind string, tokens []sqlToken) error {
+	switch kind {
+	case KindAttach, KindDetach:
+		return fmt.Errorf("%w: %s is never allowed", ErrNotAllowed, strings.ToUpper(kind))
+	case KindVacuum:
+		for _, t := range tokens {
+			if t.word && t.text == "INTO" && t.depth == 0 {
+				return fmt.Errorf("%w: VACUUM INTO is never allowed", ErrNotAllowed)
+			}
+		}
+	case KindPragmaSet:
+		if name, _ := pragmaName(tokens); lockedPragmas[name] {
+			return fmt.Errorf("%w: PRAGMA %s cannot be set", ErrNotAllowed, strings.ToLower(name))
+		}
+	}
+
+	if !p.allowed[kind] {
+		what := strings.ToUpper(kind) + " statements"
+		switch kind {
+		case KindPragmaSet:
+			what = "Setting pragmas"
+		case KindOther:
+			if len(tokens) > 0 {
+				what = "Statements starting with " + tokens[0].text
+			}
+		}
+		return fmt.Errorf("%w: %s are not allowed for the %s role", ErrNotAllowed, what, p.Role)
+	}
+	return nil
+}
+
+// explained returns the statement an EXPLAIN or EXPLAIN QUERY PLAN wraps
+func explained(tokens []sqlToken) []sqlToken {
+	rest := tokens[1:]
+	if len(rest) >= 2 && rest[0].word && rest[0].text == "QUERY" && rest[1].word && rest[1].text == "PLAN" {
+		rest = rest[2:]
+	}
+	return rest
+}
+
+// classify tells the kind of a statement by its leading keyword, looking
+// past the common table expressions of a WITH clause
+func classify(tokens []sqlToken) string {
+	if len(tokens) == 0 || !tokens[0].word {
+		return KindOther
+	}
+
+	switch tokens[0].text {
+	case "SELECT", "VALUES":
+		return KindSelect
+	case "WITH":
+		// The CTE bodies are parenthesised, so the statement's own verb is
+		// the first one outside any parentheses
+		for _, t := range tokens[1:] {
+			if !t.word || t.depth > 0 {
+				continue
+			}
+			switch t.text {
+			case "SELECT", "VALUES":
+				return KindSelect
+			case "INSERT", "REPLACE":
+				return KindInsert
+			case "UPDATE":
+				return KindUpdate
+			case "DELETE":
+				return KindDelete
+			}
+		}
+		return KindOther
+	case "INSERT", "REPLACE":
+		return KindInsert
+	case "UPDATE":
+		return KindUpdate
+	case "DELETE":
+		return KindDelete
+	case "CREATE":
+		return KindCreate
+	case "DROP":
+		return KindDrop
+	case "ALTER":
+		return KindAlter
+	case "BEGIN", "COMMIT", "END", "ROLLBACK", "SAVEPOINT", "RELEASE":
+		return KindTransaction
+	case "PRAGMA":
+		if _, set := pragmaName(tokens); set {
+			return KindPragmaSet
+		}
+		return KindPragma
+	case "EXPLAIN":
+		return KindExplain
+	case "ANALYZE":
+		return KindAnalyze
+	case "VACUUM":
+		return KindVacuum
+	case "REINDEX":
+		return KindReindex
+	case "ATTACH":
+		return KindAttach
+	case "DETACH":
+		return KindDetach
+	}
+	return KindOther
+}
+
+// CreatedType returns the type of schema object a CREATE statement makes,
+// as sqlite_master names it: table, index, view or trigger. It is empty for
+// any other statement.
+func CreatedType(stmt string) string {
+	tokens := tokenize(stmt)
+	if classify(tokens) != KindCreate {
+		return ""
+	}
+	for _, t := range tokens[1:] {
+		if !t.word {
+			return ""
+		}
+		switch t.text {
+		case "TEMP", "TEMPORARY", "UNIQUE", "VIRTUAL":
+			continue
+		case "TABLE", "INDEX", "VIEW", "TRIGGER":
+			return strings.ToLower(t.text)
+		}
+		return ""
+	}
+	return ""
+}
+
+// pragmaName returns the name of the pragma in PRAGMA [schema.]name and
+// whether the statement sets it: "= value" always does, "(arg)" does unless
+// the pragma only reports on its argument
+func pragmaName(tokens []sqlToken) (string, bool) {
+	rest := tokens[1:]
+	if len(rest) >= 3 && rest[1].text == "." {
+		rest = rest[2:]
+	}
+	if len(rest) == 0 {
+		return "", false
+	}
+
+	name := strings.ToUpper(rest[0].text)
+	if len(rest) == 1 {
+		return name, false
+	}
+	switch rest[1].text {
+	case "=":
+		return name, true
+	case "(":
+		return name, !infoPragmas[name]
+	}
+	return name, false
+}
+
+// sqlToken is a keyword, identifier, literal or punctuation mark of a
+// statement. Bare words are upper-cased; quoted identifiers keep their
+// case and string literals are reduced to a single quote.
+type sqlToken struct {
+	text   string
+	word   bool
+	quoted bool
+	depth  int
+}
+
+// tokenize cuts a statement into tokens, dropping whitespace and comments
+// and tracking how deeply each token sits inside parentheses
+func tokenize(stmt string) []sqlToken {
+	var tokens []sqlToken
+	depth := 0
+
+	for i := 0; i < len(stmt); i++ {
+		ch := stmt[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n':
+
+		case ch == '-' && i+1 < len(stmt) && stmt[i+1] == '-':
+			for i < len(stmt) && stmt[i] != '\n' {
+				i++
+			}
+
+		case ch == '/' && i+1 < len(stmt) && stmt[i+1] == '*':
+			end := strings.Index(stmt[i+2:], "*/")
+			if end < 0 {
+				return tokens
+			}
+			i += end + 3
+
+		case ch == '\'' || ch == '"' || ch == '`' || ch == '[':
+			closing := ch
+			if ch == '[' {
+				closing = ']'
+			}
+			start := i + 1
+			for i++; i < len(stmt); i++ {
+				if stmt[i] == closing {
+					if closing != ']' && i+1 < len(stmt) && stmt[i+1] == closing {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			text := "'"
+			if ch != '\'' {
+				text = stmt[start:min(i, len(stmt))]
+			}
+			tokens = append(tokens, sqlToken{text: text, quoted: ch != '\'', depth: depth})
+
+		case isWordChar(ch):
+			j := i
+			for j < len(stmt) && isWordChar(stmt[j]) {
+				j++
+			}
+			tokens = append(tokens, sqlToken{text: strings.ToUpper(stmt[i:j]), word: true, depth: depth})
+			i = j - 1
+
+		case ch == '(':
+			tokens = append(tokens, sqlToken{text: "(", depth: depth})
+			depth++
+
+		case ch == ')':
+			if depth > 0 {
+				depth--
+			}
+			tokens = append(tokens, sqlToken{text: ")", depth: depth})
+
+		default:
+			tokens = append(tokens, sqlToken{text: string(ch), depth: depth})
+		}
+	}
+	return tokens
+}
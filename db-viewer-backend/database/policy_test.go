@@ -0,0 +1,237 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"db-viewer/config"
+)
+
+func mustPolicy(t *testing.T, role string, kinds []string) *QueryPolicy {
+	t.Helper()
+	p, err := NewQueryPolicy(role, kinds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		stmt string
+		want string
+	}{
+		{"SELECT 1", KindSelect},
+		{"values (1)", KindSelect},
+		{"WITH x AS (SELECT 1) SELECT * FROM x", KindSelect},
+		{"WITH x AS (DELETE FROM t) SELECT 1", KindSelect},
+		{"WITH x AS (SELECT 1) DELETE FROM t WHERE a IN x", KindDelete},
+		{"WITH x AS (SELECT 1) INSERT INTO t SELECT * FROM x", KindInsert},
+		{"WITH x AS (SELECT 1) UPDATE t SET a = 1", KindUpdate},
+		{"REPLACE INTO t VALUES (1)", KindInsert},
+		{"CREATE TABLE t (a)", KindCreate},
+		{"DROP TABLE t", KindDrop},
+		{"ALTER TABLE t ADD b", KindAlter},
+		{"SAVEPOINT s", KindTransaction},
+		{"PRAGMA table_info(t)", KindPragma},
+		{"PRAGMA user_version", KindPragma},
+		{"PRAGMA main.user_version = 3", KindPragmaSet},
+		{"PRAGMA cache_size(10)", KindPragmaSet},
+		{"EXPLAIN SELECT 1", KindExplain},
+		{"ATTACH 'x.db' AS x", KindAttach},
+		{"DETACH x", KindDetach},
+		{"VACUUM", KindVacuum},
+		{"-- DELETE\nSELECT 1", KindSelect},
+		{"/* SELECT */ DELETE FROM t", KindDelete},
+		{"'SELECT'", KindOther},
+		{"", KindOther},
+	}
+	for _, tt := range tests {
+		if got := classify(tokenize(tt.stmt)); got != tt.want {
+			t.Errorf("classify(%q) = %s, want %s", tt.stmt, got, tt.want)
+		}
+	}
+}
+
+func TestQueryPolicyRoleDefaults(t *testing.T) {
+	stmts := map[string]string{
+		"select":     "SELECT * FROM t",
+		"pragma":     "PRAGMA table_info(t)",
+		"explain":    "EXPLAIN QUERY PLAN SELECT * FROM t",
+		"insert":     "INSERT INTO t VALUES (1)",
+		"update":     "UPDATE t SET a = 1",
+		"delete":     "DELETE FROM t",
+		"begin":      "BEGIN",
+		"create":     "CREATE TABLE u (a)",
+		"drop":       "DROP TABLE t",
+		"alter":      "ALTER TABLE t ADD b",
+		"pragma_set": "PRAGMA cache_size = 10",
+		"analyze":    "ANALYZE",
+		"vacuum":     "VACUUM",
+		"reindex":    "REINDEX",
+	}
+	allowed := map[string][]string{
+		config.RoleReader: {"select", "pragma", "explain"},
+		config.RoleEditor: {"select", "pragma", "explain", "insert", "update", "delete", "begin"},
+		config.RoleAdmin:  {"select", "pragma", "explain", "insert", "update", "delete", "begin", "create", "drop", "alter", "pragma_set", "analyze", "vacuum", "reindex"},
+	}
+
+	for _, role := range config.Roles {
+		p := mustPolicy(t, role, nil)
+		may := make(map[string]bool)
+		for _, name := range allowed[role] {
+			may[name] = true
+		}
+		for name, stmt := range stmts {
+			_, err := p.Check(stmt)
+			switch {
+			case may[name] && err != nil:
+				t.Errorf("%s: %q rejected: %v", role, stmt, err)
+			case !may[name] && !errors.Is(err, ErrNotAllowed):
+				t.Errorf("%s: %q allowed, want ErrNotAllowed", role, stmt)
+			}
+		}
+	}
+
+	if !mustPolicy(t, config.RoleReader, nil).ReadOnly() {
+		t.Error("reader policy is not read-only")
+	}
+	if mustPolicy(t, config.RoleEditor, nil).ReadOnly() {
+		t.Error("editor policy is read-only")
+	}
+}
+
+func TestQueryPolicyCustomKinds(t *testing.T) {
+	p := mustPolicy(t, config.RoleReader, []string{KindSelect, KindInsert})
+	if _, err := p.Check("INSERT INTO t VALUES (1)"); err != nil {
+		t.Errorf("insert rejected: %v", err)
+	}
+	if _, err := p.Check("PRAGMA table_info(t)"); !errors.Is(err, ErrNotAllowed) {
+		t.Errorf("pragma allowed by an allowlist without it")
+	}
+
+	if _, err := NewQueryPolicy(config.RoleAdmin, []string{KindAttach}); err == nil {
+		t.Error("an allowlist may name attach")
+	}
+	if _, err := NewQueryPolicy("root", nil); err == nil {
+		t.Error("unknown role accepted")
+	}
+}
+
+// TestQueryPolicyNeverAllowed covers what no role, however configured, may run
+func TestQueryPolicyNeverAllowed(t *testing.T) {
+	stmts := []string{
+		"ATTACH DATABASE '/tmp/x.db' AS x",
+		"attach '/tmp/x.db' as x",
+		"DETACH DATABASE main",
+		"VACUUM INTO '/tmp/copy.db'",
+		"VACUUM main INTO '/tmp/copy.db'",
+		"SELECT load_extension('/tmp/evil.so')",
+		"SELECT LOAD_EXTENSION ('/tmp/evil.so')",
+		`SELECT "load_extension"('/tmp/evil.so')`,
+		"SELECT * FROM t WHERE load_extension('x') IS NULL",
+		"EXPLAIN ATTACH '/tmp/x.db' AS x",
+		"EXPLAIN QUERY PLAN VACUUM INTO '/tmp/copy.db'",
+		"EXPLAIN PRAGMA cache_size = 10",
+	}
+	for name := range lockedPragmas {
+		stmts = append(stmts, "PRAGMA "+name+" = 0", "PRAGMA main."+name+"(0)")
+	}
+
+	admin := mustPolicy(t, config.RoleAdmin, statementKinds)
+	for _, stmt := range stmts {
+		if _, err := admin.Check(stmt); !errors.Is(err, ErrNotAllowed) {
+			t.Errorf("%q allowed, want ErrNotAllowed", stmt)
+		}
+	}
+
+	// Reading a locked pragma and vacuuming in place are fine
+	for _, stmt := range []string{"PRAGMA foreign_keys", "VACUUM", "SELECT 'load_extension(x)'"} {
+		if _, err := admin.Check(stmt); err != nil {
+			t.Errorf("%q rejected: %v", stmt, err)
+		}
+	}
+}
+
+// TestQueryPolicyHiddenKeywords checks that comments and literals neither
+// hide a statement's kind nor lend it another
+func TestQueryPolicyHiddenKeywords(t *testing.T) {
+	reader := mustPolicy(t, config.RoleReader, nil)
+
+	allowed := []string{
+		"SELECT 'DELETE FROM t; ATTACH x'",
+		"SELECT 1 -- ; DELETE FROM t",
+		"/* DELETE FROM t; */ SELECT 1",
+		`SELECT "delete" FROM t`,
+		"SELECT [insert] FROM t",
+	}
+	for _, stmt := range allowed {
+		if _, err := reader.Check(stmt); err != nil {
+			t.Errorf("%q rejected: %v", stmt, err)
+		}
+	}
+
+	rejected := []string{
+		"-- SELECT\nDELETE FROM t",
+		"/* SELECT */ DELETE FROM t",
+		"/* SELECT 1; */ INSERT INTO t VALUES ('SELECT')",
+		"EXPLAIN DELETE FROM t",
+		"WITH x AS (SELECT 1) DELETE FROM t",
+	}
+	for _, stmt := range rejected {
+		if _, err := reader.Check(stmt); !errors.Is(err, ErrNotAllowed) {
+			t.Errorf("%q allowed for reader", stmt)
+		}
+	}
+}
+
+// TestQueryPolicyTriggerScript is a trigger with a column named "begin"
+// followed by statements no role may run: split correctly, they are checked
+// on their own and rejected
+func TestQueryPolicyTriggerScript(t *testing.T) {
+	admin := mustPolicy(t, config.RoleAdmin, nil)
+	script := "CREATE TRIGGER tr AFTER UPDATE OF begin ON t BEGIN SELECT 1; END; ATTACH DATABASE '/tmp/x' AS e; PRAGMA foreign_keys=OFF"
+
+	stmts := SplitStatements(script)
+	if len(stmts) != 3 {
+		t.Fatalf("split into %d statements, want 3", len(stmts))
+	}
+	wantErr := []bool{false, true, true}
+	for i, stmt := range stmts {
+		_, err := admin.Check(stmt.SQL)
+		if got := errors.Is(err, ErrNotAllowed); got != wantErr[i] {
+			t.Errorf("%q: rejected = %v, want %v", stmt.SQL, got, wantErr[i])
+		}
+	}
+
+	if _, err := SingleStatement(script); !errors.Is(err, ErrNotSingleStatement) {
+		t.Errorf("SingleStatement accepted the script: %v", err)
+	}
+	if stmt, err := SingleStatement("SELECT 1; -- done\n"); err != nil || stmt != "SELECT 1" {
+		t.Errorf("SingleStatement(SELECT 1;) = %q, %v", stmt, err)
+	}
+	if _, err := SingleStatement(" -- nothing\n"); !errors.Is(err, ErrNotSingleStatement) {
+		t.Errorf("SingleStatement accepted a comment")
+	}
+}
+
+func TestCreatedType(t *testing.T) {
+	tests := map[string]string{
+		"CREATE TABLE t (a)":                                                "table",
+		"create temp table if not exists t (a)":                             "table",
+		"CREATE VIRTUAL TABLE f USING fts5(a)":                              "table",
+		"CREATE UNIQUE INDEX i ON t (a)":                                    "index",
+		"CREATE VIEW v AS SELECT 1":                                         "view",
+		"CREATE TEMPORARY TRIGGER tr AFTER INSERT ON t BEGIN SELECT 1; END": "trigger",
+		"/* CREATE VIEW */ CREATE TABLE t (a)":                              "table",
+		"ATTACH DATABASE 'x' AS e":                                          "",
+		"SELECT 'CREATE TABLE t (a)'":                                       "",
+		"CREATE \"TABLE\" t (a)":                                            "",
+		"":                                                                  "",
+	}
+	for stmt, want := range tests {
+		if got := CreatedType(stmt); got != want {
+			t.Errorf("CreatedType(%q) = %q, want %q", stmt, got, want)
+		}
+	}
+}
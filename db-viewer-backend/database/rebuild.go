@@ -0,0 +1,596 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	"db-viewer/models"
+)
+
+// tableDef is what a table is recreated from when its constraints change
+type tableDef struct {
+	columns      []string
+	columnDefs   []string
+	primaryKey   []string
+	unique       [][]string
+	foreignKeys  []foreignKeyDef
+	withoutRowID bool
+	// lost lists what the table declares that a rebuild would drop
+	lost []string
+	// schema holds the CREATE statements of the table's indexes and triggers
+	schema []string
+}
+
+// foreignKeyDef is a declared foreign key as PRAGMA foreign_key_list lists it
+type foreignKeyDef struct {
+	from     []string
+	table    string
+	to       []string
+	onUpdate string
+	onDelete string
+}
+
+// GuessConstraints suggests constraints for an existing table from the
+// naming conventions of GuessKeys, checking the values of an "id" column
+// when the table has no primary key. Columns that already declare a
+// foreign key are left alone.
+func GuessConstraints(ctx context.Context, db *sql.DB, table string) (models.ConstraintsRequest, error) {
+	key, err := TableKey(db, table)
+	if err != nil {
+		return models.ConstraintsRequest{}, err
+	}
+	columns, err := TableColumns(db, table)
+	if err != nil {
+		return models.ConstraintsRequest{}, err
+	}
+
+	var unique func(string) (bool, error)
+	if key.RowID {
+		unique = func(col string) (bool, error) {
+			var unique bool
+			query := fmt.Sprintf("SELECT COUNT(*) > 0 AND COUNT(DISTINCT %[1]s) = COUNT(*) FROM %[2]s", QuoteIdent(col), QuoteIdent(table))
+			err := db.QueryRowContext(ctx, query).Scan(&unique)
+			return unique, err
+		}
+	}
+	guess, err := GuessKeys(ctx, db, table, columns, unique)
+	if err != nil {
+		return guess, err
+	}
+
+	declared, err := ForeignKeys(ctx, db, table)
+	if err != nil {
+		return guess, err
+	}
+	var fks []models.ForeignKey
+	for _, fk := range guess.ForeignKeys {
+		if fk.TargetColumn != "" && !hasForeignKey(declared, fk.Column) {
+			fks = append(fks, fk)
+		}
+	}
+	guess.ForeignKeys = fks
+	return guess, nil
+}
+
+// GuessKeys applies the naming conventions the importer uses to the columns
+// of table, which need not exist yet. The first "id" column unique reports
+// as holding unique, non-NULL values becomes the primary key; with unique
+// nil none is guessed. <name>_id columns reference the primary key of the
+// table <name> or <name>s, other than table itself; one whose table has no
+// single-column primary key to point at comes back without a TargetColumn.
+func GuessKeys(ctx context.Context, db *sql.DB, table string, columns []string, unique func(column string) (bool, error)) (models.ConstraintsRequest, error) {
+	var guess models.ConstraintsRequest
+
+	if unique != nil {
+		for _, col := range columns {
+			if !strings.EqualFold(col, "id") {
+				continue
+			}
+			ok, err := unique(col)
+			if err != nil {
+				return guess, err
+			}
+			if ok {
+				guess.PrimaryKey = []string{col}
+			}
+			break
+		}
+	}
+
+	tables, err := TableNames(ctx, db)
+	if err != nil {
+		return guess, err
+	}
+	var others []string
+	for _, name := range tables {
+		if !strings.EqualFold(name, table) {
+			others = append(others, name)
+		}
+	}
+
+	for _, col := range columns {
+		target := GuessReferencedTable(others, col)
+		if target == "" {
+			continue
+		}
+		column, _ := ReferenceTarget(db, target)
+		guess.ForeignKeys = append(guess.ForeignKeys, models.ForeignKey{Column: col, TargetTable: target, TargetColumn: column})
+	}
+	return guess, nil
+}
+
+func hasForeignKey(fks []models.ForeignKey, column string) bool {
+	for _, fk := range fks {
+		if strings.EqualFold(fk.Column, column) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyConstraints rebuilds an already resolved table with the primary key
+// of req (keeping the current one when it is empty) and the foreign keys of
+// req on top of those it declares; a new foreign key on a column replaces
+// the one it had. SQLite cannot add constraints in place, so the table is
+// copied into a new one while foreign keys are off, keeping its column
+// types, NOT NULL, defaults, UNIQUE constraints, indexes and triggers.
+// Tables using what the rebuild cannot carry over (see lostFeatures) are
+// refused with ErrInvalidConstraint.
+//
+// A new primary key over duplicate values fails with ErrDuplicateKeys and
+// the duplicates as violations, changing nothing. Rows that break a foreign
+// key do not stop the rebuild; they are reported as violations.
+func ApplyConstraints(ctx context.Context, db *sql.DB, table string, req models.ConstraintsRequest) (*models.ConstraintsResult, error) {
+	primaryKey, err := resolveColumns(db, table, req.PrimaryKey)
+	if err != nil {
+		return nil, err
+	}
+	added, err := resolveForeignKeys(db, table, req.ForeignKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	def, err := readTableDef(ctx, db, table)
+	if err != nil {
+		return nil, err
+	}
+	result := &models.ConstraintsResult{Table: table, Violations: []models.ConstraintViolation{}}
+
+	if len(primaryKey) > 0 && !sameColumns(primaryKey, def.primaryKey) {
+		duplicates, err := DuplicateKeys(ctx, db, table, primaryKey)
+		if err != nil {
+			return nil, err
+		}
+		if len(duplicates) > 0 {
+			result.PrimaryKey = def.primaryKey
+			result.Violations = duplicates
+			if result.ForeignKeys, err = ForeignKeys(ctx, db, table); err != nil {
+				return nil, err
+			}
+			return result, fmt.Errorf("%w: %s", ErrDuplicateKeys, strings.Join(primaryKey, ", "))
+		}
+		def.primaryKey = primaryKey
+	} else {
+		primaryKey = nil
+	}
+
+	for _, fk := range added {
+		kept := def.foreignKeys[:0]
+		for _, existing := range def.foreignKeys {
+			if len(existing.from) != 1 || !strings.EqualFold(existing.from[0], fk.Column) {
+				kept = append(kept, existing)
+			}
+		}
+		def.foreignKeys = append(kept, foreignKeyDef{from: []string{fk.Column}, table: fk.TargetTable, to: []string{fk.TargetColumn}})
+	}
+
+	rebuild := len(primaryKey) > 0 || len(added) > 0
+	if rebuild && len(def.lost) > 0 {
+		return nil, fmt.Errorf("%w: %q uses %s, which cannot be rebuilt", ErrInvalidConstraint, table, strings.Join(def.lost, ", "))
+	}
+
+	err = WithoutForeignKeys(ctx, db, func(conn *sql.Conn) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if rebuild {
+			if err := rebuildTable(ctx, tx, table, def); err != nil {
+				return err
+			}
+		}
+		if result.Violations, err = CheckForeignKeys(ctx, tx, table); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.PrimaryKey = def.primaryKey
+	if result.PrimaryKey == nil {
+		result.PrimaryKey = []string{}
+	}
+	if result.ForeignKeys, err = ForeignKeys(ctx, db, table); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// rebuildTable recreates table from def and copies its rows over, following
+// SQLite's procedure for schema changes ALTER TABLE cannot make
+func rebuildTable(ctx context.Context, tx *sql.Tx, table string, def *tableDef) error {
+	scratch := ScratchTable("constraints")
+	columns := quoteAll(def.columns)
+
+	stmts := []string{
+		def.createSQL(scratch),
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", QuoteIdent(scratch), columns, columns, QuoteIdent(table)),
+		"DROP TABLE " + QuoteIdent(table),
+	}
+	for i, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			if i == 1 {
+				// The copy fails on values the new key cannot hold, e.g.
+				// text in an INTEGER PRIMARY KEY
+				return fmt.Errorf("%w: %v", ErrInvalidConstraint, err)
+			}
+			return fmt.Errorf("failed to rebuild %q: %w", table, err)
+		}
+	}
+	if err := RenameTable(ctx, tx, scratch, table); err != nil {
+		return fmt.Errorf("failed to rebuild %q: %w", table, err)
+	}
+	for _, stmt := range def.schema {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to rebuild %q: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// RenameTable renames a table in place of one just dropped. SQLite checks
+// every view and trigger when a table is renamed and fails on those that
+// mention the dropped table, so the legacy rename, which does not, is used.
+func RenameTable(ctx context.Context, tx *sql.Tx, from, to string) error {
+	if _, err := tx.ExecContext(ctx, "PRAGMA legacy_alter_table = ON"); err != nil {
+		return err
+	}
+	defer tx.ExecContext(context.Background(), "PRAGMA legacy_alter_table = OFF")
+
+	_, err := tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s RENAME TO %s", QuoteIdent(from), QuoteIdent(to)))
+	return err
+}
+
+// createSQL writes the CREATE TABLE statement for def under name
+func (def *tableDef) createSQL(name string) string {
+	parts := append([]string(nil), def.columnDefs...)
+	if len(def.primaryKey) > 0 {
+		parts = append(parts, fmt.Sprintf("PRIMARY KEY (%s)", quoteAll(def.primaryKey)))
+	}
+	for _, cols := range def.unique {
+		parts = append(parts, fmt.Sprintf("UNIQUE (%s)", quoteAll(cols)))
+	}
+	for _, fk := range def.foreignKeys {
+		clause := fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s", quoteAll(fk.from), QuoteIdent(fk.table))
+		if len(fk.to) > 0 {
+			clause += " (" + quoteAll(fk.to) + ")"
+		}
+		if fk.onUpdate != "" && fk.onUpdate != "NO ACTION" {
+			clause += " ON UPDATE " + fk.onUpdate
+		}
+		if fk.onDelete != "" && fk.onDelete != "NO ACTION" {
+			clause += " ON DELETE " + fk.onDelete
+		}
+		parts = append(parts, clause)
+	}
+
+	sql := fmt.Sprintf("CREATE TABLE %s (%s)", QuoteIdent(name), strings.Join(parts, ", "))
+	if def.withoutRowID {
+		sql += " WITHOUT ROWID"
+	}
+	return sql
+}
+
+// readTableDef collects the definition of table from its pragmas
+func readTableDef(ctx context.Context, db *sql.DB, table string) (*tableDef, error) {
+	def := &tableDef{}
+
+	var generated int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM pragma_table_xinfo(?) WHERE hidden > 0", table).Scan(&generated); err != nil {
+		return nil, err
+	}
+	if generated > 0 {
+		def.lost = append(def.lost, "generated columns")
+	}
+
+	var createSQL string
+	if err := db.QueryRowContext(ctx, "SELECT sql FROM main.sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&createSQL); err != nil {
+		return nil, err
+	}
+	def.lost = append(def.lost, lostFeatures(createSQL)...)
+
+	type keyColumn struct {
+		name string
+		pk   int
+	}
+	var keyColumns []keyColumn
+	rows, err := db.QueryContext(ctx, `SELECT name, type, "notnull", dflt_value, pk FROM pragma_table_info(?) ORDER BY cid`, table)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name, typ string
+		var notNull bool
+		var dflt sql.NullString
+		var pk int
+		if err := rows.Scan(&name, &typ, &notNull, &dflt, &pk); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		col := QuoteIdent(name)
+		if typ != "" {
+			col += " " + typ
+		}
+		if notNull {
+			col += " NOT NULL"
+		}
+		if dflt.Valid {
+			col += " DEFAULT " + dflt.String
+		}
+		def.columns = append(def.columns, name)
+		def.columnDefs = append(def.columnDefs, col)
+		if pk > 0 {
+			keyColumns = append(keyColumns, keyColumn{name, pk})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	def.primaryKey = make([]string, len(keyColumns))
+	for _, kc := range keyColumns {
+		def.primaryKey[kc.pk-1] = kc.name
+	}
+
+	if def.withoutRowID, err = isWithoutRowID(db, table); err != nil {
+		return nil, err
+	}
+	if def.unique, err = uniqueConstraints(ctx, db, table); err != nil {
+		return nil, err
+	}
+	if def.foreignKeys, err = foreignKeyDefs(ctx, db, table); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.QueryContext(ctx, "SELECT sql FROM main.sqlite_master WHERE type IN ('index', 'trigger') AND tbl_name = ? AND sql IS NOT NULL ORDER BY rowid", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var stmt string
+		if err := rows.Scan(&stmt); err != nil {
+			return nil, err
+		}
+		def.schema = append(def.schema, stmt)
+	}
+	return def, rows.Err()
+}
+
+// lostFeatures lists what a CREATE TABLE statement declares that the
+// pragmas readTableDef reads do not report, so a rebuild would drop it
+func lostFeatures(createSQL string) []string {
+	var lost []string
+	add := func(feature string) {
+		if !slices.Contains(lost, feature) {
+			lost = append(lost, feature)
+		}
+	}
+
+	tokens := tokenize(createSQL)
+	for i, t := range tokens {
+		if !t.word {
+			continue
+		}
+		if t.depth == 0 {
+			if t.text == "STRICT" {
+				add("STRICT")
+			}
+			continue
+		}
+		switch t.text {
+		case "CHECK":
+			add("CHECK constraints")
+		case "COLLATE":
+			add("COLLATE")
+		case "AUTOINCREMENT":
+			add("AUTOINCREMENT")
+		case "DEFERRABLE":
+			add("deferred foreign keys")
+		case "CONFLICT":
+			if i > 0 && tokens[i-1].text == "ON" {
+				add("ON CONFLICT clauses")
+			}
+		}
+	}
+	return lost
+}
+
+// uniqueConstraints lists the column sets of table's UNIQUE constraints,
+// whose automatic indexes have no SQL of their own to recreate them from
+func uniqueConstraints(ctx context.Context, db *sql.DB, table string) ([][]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT name FROM pragma_index_list(?) WHERE origin = 'u' ORDER BY seq DESC`, table)
+	if err != nil {
+		return nil, err
+	}
+	var indexes []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		indexes = append(indexes, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var unique [][]string
+	for _, index := range indexes {
+		cols, err := indexColumns(ctx, db, index)
+		if err != nil {
+			return nil, err
+		}
+		unique = append(unique, cols)
+	}
+	return unique, nil
+}
+
+func indexColumns(ctx context.Context, q Queryer, index string) ([]string, error) {
+	rows, err := q.QueryContext(ctx, "SELECT name FROM pragma_index_info(?) ORDER BY seqno", index)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cols []string
+	for rows.Next() {
+		var col sql.NullString
+		if err := rows.Scan(&col); err != nil {
+			return nil, err
+		}
+		cols = append(cols, col.String)
+	}
+	return cols, rows.Err()
+}
+
+// foreignKeyDefs groups the rows of PRAGMA foreign_key_list into keys
+func foreignKeyDefs(ctx context.Context, db *sql.DB, table string) ([]foreignKeyDef, error) {
+	rows, err := db.QueryContext(ctx, `SELECT id, "table", "from", "to", on_update, on_delete FROM pragma_foreign_key_list(?) ORDER BY id, seq`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var defs []foreignKeyDef
+	lastID := -1
+	for rows.Next() {
+		var id int
+		var parent, from, onUpdate, onDelete string
+		var to sql.NullString
+		if err := rows.Scan(&id, &parent, &from, &to, &onUpdate, &onDelete); err != nil {
+			return nil, err
+		}
+		if id != lastID {
+			defs = append(defs, foreignKeyDef{table: parent, onUpdate: onUpdate, onDelete: onDelete})
+			lastID = id
+		}
+		fk := &defs[len(defs)-1]
+		fk.from = append(fk.from, from)
+		if to.Valid {
+			fk.to = append(fk.to, to.String)
+		}
+	}
+	return defs, rows.Err()
+}
+
+// resolveColumns resolves the names of a primary key against table
+func resolveColumns(db *sql.DB, table string, names []string) ([]string, error) {
+	var cols []string
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		col, err := ResolveColumn(db, table, name)
+		if err != nil {
+			return nil, err
+		}
+		if seen[strings.ToLower(col)] {
+			return nil, fmt.Errorf("%w: column %q is named twice in the primary key", ErrInvalidConstraint, col)
+		}
+		seen[strings.ToLower(col)] = true
+		cols = append(cols, col)
+	}
+	return cols, nil
+}
+
+// resolveForeignKeys resolves the columns and targets of new foreign keys.
+// A key without a target column refers to the target's primary key, which
+// must then be a single declared column; a named target column must be the
+// target's primary key or UNIQUE, as SQLite requires.
+func resolveForeignKeys(db *sql.DB, table string, fks []models.ForeignKey) ([]models.ForeignKey, error) {
+	var resolved []models.ForeignKey
+	for _, fk := range fks {
+		col, err := ResolveColumn(db, table, fk.Column)
+		if err != nil {
+			return nil, err
+		}
+		target, err := ResolveTable(db, fk.TargetTable)
+		if err != nil {
+			return nil, err
+		}
+
+		var targetCol string
+		if fk.TargetColumn == "" {
+			var ok bool
+			if targetCol, ok = ReferenceTarget(db, target); !ok {
+				return nil, fmt.Errorf("%w: %q has no single-column primary key; name the target_column", ErrInvalidConstraint, target)
+			}
+		} else {
+			if targetCol, err = ResolveColumn(db, target, fk.TargetColumn); err != nil {
+				return nil, err
+			}
+			unique, err := isUniqueColumn(db, target, targetCol)
+			if err != nil {
+				return nil, err
+			}
+			if !unique {
+				return nil, fmt.Errorf("%w: %s.%s is neither the primary key nor UNIQUE", ErrInvalidConstraint, target, targetCol)
+			}
+		}
+		resolved = append(resolved, models.ForeignKey{Column: col, TargetTable: target, TargetColumn: targetCol})
+	}
+	return resolved, nil
+}
+
+// isUniqueColumn reports whether column on its own is the declared primary
+// key of table or has a unique index
+func isUniqueColumn(db *sql.DB, table, column string) (bool, error) {
+	if key, ok := ReferenceTarget(db, table); ok && strings.EqualFold(key, column) {
+		return true, nil
+	}
+	var n int
+	err := db.QueryRow(`
+		SELECT COUNT(*) FROM pragma_index_list(?) AS il
+		WHERE il."unique" = 1
+		  AND (SELECT COUNT(*) FROM pragma_index_info(il.name)) = 1
+		  AND (SELECT name FROM pragma_index_info(il.name)) = ? COLLATE NOCASE`, table, column).Scan(&n)
+	return n > 0, err
+}
+
+func sameColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func quoteAll(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = QuoteIdent(name)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"db-viewer/models"
+)
+
+// Kinds of Relationship
+const (
+	RelationshipDeclared = "declared"
+	RelationshipInferred = "inferred"
+)
+
+// Relationships lists the links between tables: the foreign keys each table
+// declares, one per column pair, and for columns without one the links
+// GuessReferencedTable infers from their names, unless they were rejected,
+// plus the suggestions that were accepted.
+func Relationships(ctx context.Context, db *sql.DB, tables []string) ([]models.Relationship, error) {
+	decisions, err := loadDecisions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	statuses := decisionStatuses(decisions)
+
+	relationships := []models.Relationship{}
+	for _, table := range tables {
+		declared, err := declaredRelationships(ctx, db, table)
+		if err != nil {
+			return nil, err
+		}
+		relationships = append(relationships, declared...)
+
+		columns, err := TableColumns(db, table)
+		if err != nil {
+			return nil, err
+		}
+		for _, col := range columns {
+			if hasRelationship(declared, col) {
+				continue
+			}
+			target := GuessReferencedTable(tables, col)
+			if target == "" {
+				continue
+			}
+			rel := models.Relationship{SourceTable: table, SourceColumn: col, TargetTable: target, Kind: RelationshipInferred}
+			rel.TargetColumn, _ = ReferenceTarget(db, target)
+			if statuses[decisionKey(table, col, target, rel.TargetColumn)] == SuggestionRejected {
+				continue
+			}
+			relationships = append(relationships, rel)
+		}
+	}
+
+	for _, d := range decisions {
+		if d.status != SuggestionAccepted || !containsFold(tables, d.SourceTable) || !containsFold(tables, d.TargetTable) || linked(relationships, d.RelationshipDecision) {
+			continue
+		}
+		relationships = append(relationships, models.Relationship{
+			SourceTable:  d.SourceTable,
+			SourceColumn: d.SourceColumn,
+			TargetTable:  d.TargetTable,
+			TargetColumn: d.TargetColumn,
+			Kind:         RelationshipInferred,
+		})
+	}
+	return relationships, nil
+}
+
+// linked reports whether relationships already link the columns of d
+func linked(relationships []models.Relationship, d models.RelationshipDecision) bool {
+	for _, rel := range relationships {
+		if decisionKey(rel.SourceTable, rel.SourceColumn, rel.TargetTable, rel.TargetColumn) == decisionKey(d.SourceTable, d.SourceColumn, d.TargetTable, d.TargetColumn) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// declaredRelationships reads the foreign keys of table from PRAGMA
+// foreign_key_list. A key that refers to its parent's primary key
+// implicitly is given the primary key's columns as targets.
+func declaredRelationships(ctx context.Context, db *sql.DB, table string) ([]models.Relationship, error) {
+	rows, err := db.QueryContext(ctx, `SELECT seq, "table", "from", "to", on_update, on_delete FROM pragma_foreign_key_list(?) ORDER BY id, seq`, table)
+	if err != nil {
+		return nil, err
+	}
+
+	var relationships []models.Relationship
+	var implicit []int
+	var seqs []int
+	for rows.Next() {
+		var seq int
+		var to sql.NullString
+		rel := models.Relationship{SourceTable: table, Kind: RelationshipDeclared}
+		if err := rows.Scan(&seq, &rel.TargetTable, &rel.SourceColumn, &to, &rel.OnUpdate, &rel.OnDelete); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		rel.TargetColumn = to.String
+		if !to.Valid {
+			implicit = append(implicit, len(relationships))
+		}
+		seqs = append(seqs, seq)
+		relationships = append(relationships, rel)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Look the parents' keys up once the pragma's rows are closed
+	for _, i := range implicit {
+		parent, err := ResolveTable(db, relationships[i].TargetTable)
+		if err != nil {
+			// A key may refer to a table that does not exist (yet)
+			continue
+		}
+		key, err := TableKey(db, parent)
+		if err != nil {
+			return nil, err
+		}
+		if !key.RowID && seqs[i] < len(key.Columns) {
+			relationships[i].TargetColumn = key.Columns[seqs[i]]
+		}
+	}
+	return relationships, nil
+}
+
+func hasRelationship(relationships []models.Relationship, column string) bool {
+	for _, rel := range relationships {
+		if strings.EqualFold(rel.SourceColumn, column) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"db-viewer/models"
+)
+
+// DescribeTable reports the columns, key, indexes and row count of an
+// already resolved table, without reading its rows
+func DescribeTable(ctx context.Context, db *sql.DB, table string) (*models.TableSchema, error) {
+	schema := &models.TableSchema{Name: table}
+
+	var err error
+	if schema.Columns, err = Columns(ctx, db, table); err != nil {
+		return nil, err
+	}
+	key, err := TableKey(db, table)
+	if err != nil {
+		return nil, err
+	}
+	schema.PrimaryKey = key.Columns
+	if schema.Indexes, err = Indexes(ctx, db, table); err != nil {
+		return nil, err
+	}
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+QuoteIdent(table)).Scan(&schema.RowCount); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// Columns describes the columns of an already resolved table. Columns
+// declared without a type are reported as VARCHAR.
+func Columns(ctx context.Context, db *sql.DB, table string) ([]models.ColumnInfo, error) {
+	rows, err := db.QueryContext(ctx, `SELECT name, type, "notnull", dflt_value FROM pragma_table_info(?) ORDER BY cid`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := []models.ColumnInfo{}
+	for rows.Next() {
+		var col models.ColumnInfo
+		var dflt sql.NullString
+		if err := rows.Scan(&col.Name, &col.Type, &col.NotNull, &dflt); err != nil {
+			return nil, err
+		}
+		if col.Type == "" {
+			col.Type = "VARCHAR"
+		}
+		if dflt.Valid {
+			col.Default = &dflt.String
+		}
+		columns = append(columns, col)
+	}
+	return columns, rows.Err()
+}
+
+// Indexes lists the indexes of an already resolved table with their columns
+func Indexes(ctx context.Context, db *sql.DB, table string) ([]models.IndexInfo, error) {
+	rows, err := db.QueryContext(ctx, `SELECT name, "unique", origin FROM pragma_index_list(?) ORDER BY seq DESC`, table)
+	if err != nil {
+		return nil, err
+	}
+	indexes := []models.IndexInfo{}
+	for rows.Next() {
+		var index models.IndexInfo
+		if err := rows.Scan(&index.Name, &index.Unique, &index.Origin); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		indexes = append(indexes, index)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Read the columns once the index list is closed
+	for i := range indexes {
+		if indexes[i].Columns, err = indexColumns(ctx, db, indexes[i].Name); err != nil {
+			return nil, err
+		}
+	}
+	return indexes, nil
+}
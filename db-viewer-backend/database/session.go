@@ -0,0 +1,150 @@
+package database
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// ErrTooManySessions is returned when every session slot is taken by a
+// session in use
+var ErrTooManySessions = errors.New("too many sessions")
+
+var sessionTokenRegex = regexp.MustCompile(`^[a-f0-9]{32}$`)
+
+// SessionManager gives every client session its own in-memory database,
+// holds at most max of them and drops the ones that have been idle for
+// longer than the TTL.
+type SessionManager struct {
+	ttl time.Duration
+	max int
+
+	mu       sync.Mutex
+	sessions map[string]*holder
+	stop     chan struct{}
+}
+
+// NewSessionManager starts a manager that keeps up to max sessions and
+// evicts those idle for ttl
+func NewSessionManager(ttl time.Duration, max int) *SessionManager {
+	m := &SessionManager{
+		ttl:      ttl,
+		max:      max,
+		sessions: make(map[string]*holder),
+		stop:     make(chan struct{}),
+	}
+	go m.evictLoop()
+	return m
+}
+
+// Get leases the database of the session identified by token. A token the
+// manager did not issue, or no longer knows, starts a new session under a
+// fresh token, so callers must hand the returned token back to the client.
+// With max sessions open, the least recently used one nothing is using
+// makes room; when every one is in use Get fails with ErrTooManySessions.
+func (m *SessionManager) Get(token string) (*Lease, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if sessionTokenRegex.MatchString(token) {
+		if s, ok := m.sessions[token]; ok {
+			return s.lease(), token, nil
+		}
+	}
+
+	if len(m.sessions) >= m.max && !m.evictOldest() {
+		return nil, "", ErrTooManySessions
+	}
+
+	db, err := openMemory()
+	if err != nil {
+		return nil, "", err
+	}
+	token = newSessionToken()
+	s := newHolder(&m.mu, db)
+	m.sessions[token] = s
+	return s.lease(), token, nil
+}
+
+// End throws a session's database away. Requests and jobs still using it
+// finish first; the session is gone for everyone else right away.
+func (m *SessionManager) End(token string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.sessions[token]; ok {
+		s.drop()
+		delete(m.sessions, token)
+	}
+}
+
+// Close stops the eviction loop and drops every session
+func (m *SessionManager) Close() {
+	close(m.stop)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for token, s := range m.sessions {
+		s.drop()
+		delete(m.sessions, token)
+	}
+}
+
+func (m *SessionManager) evictLoop() {
+	interval := m.ttl / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case now := <-ticker.C:
+			m.evictIdle(now)
+		}
+	}
+}
+
+// evictIdle drops the sessions unused for longer than the TTL; a session
+// serving a request or running a job is never idle
+func (m *SessionManager) evictIdle(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for token, s := range m.sessions {
+		if s.leases == 0 && now.Sub(s.lastUsed) > m.ttl {
+			s.drop()
+			delete(m.sessions, token)
+		}
+	}
+}
+
+// evictOldest drops the least recently used session nothing is using. It
+// must be called with mu held.
+func (m *SessionManager) evictOldest() bool {
+	var oldest string
+	for token, s := range m.sessions {
+		if s.leases == 0 && (oldest == "" || s.lastUsed.Before(m.sessions[oldest].lastUsed)) {
+			oldest = token
+		}
+	}
+	if oldest == "" {
+		return false
+	}
+	m.sessions[oldest].drop()
+	delete(m.sessions, oldest)
+	return true
+}
+
+func newSessionToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
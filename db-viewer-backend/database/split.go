@@ -0,0 +1,221 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotSingleStatement is returned for text that holds no statement, or
+// more than one, where exactly one is expected
+var ErrNotSingleStatement = errors.New("expected exactly one statement")
+
+// Statement is one SQL statement cut out of a script
+type Statement struct {
+	SQL string `json:"sql"`
+	// Offset is the byte offset of the statement within the script
+	Offset int `json:"offset"`
+	// Line is the 1-based line the statement starts on
+	Line int `json:"line"`
+}
+
+// Stages of a CREATE TRIGGER statement, which tell the BEGIN that opens
+// its body from columns, tables and triggers named "begin"
+const (
+	triggerHeader = iota // up to the ON of "ON table"
+	triggerOn            // the next token names the table
+	triggerTable         // the first BEGIN from here on opens the body
+	triggerBody          // inside BEGIN ... END
+)
+
+// SplitStatements cuts a script into statements at top-level semicolons.
+// Semicolons inside string literals, quoted identifiers, comments and the
+// BEGIN ... END body of CREATE TRIGGER do not end a statement. Comments
+// before a statement are not part of it, and statements holding nothing but
+// whitespace and comments are dropped.
+//
+// Where it cannot tell a keyword from an identifier, e.g. a column named
+// "end" inside a trigger body, it cuts too early rather than too late: a
+// piece cut short fails to run, while one running past its end would carry
+// a statement the query policy never saw.
+func SplitStatements(script string) []Statement {
+	var stmts []Statement
+
+	line := 1
+	hasCode := false
+
+	// The leading words tell whether this is a CREATE TRIGGER. Its body
+	// nests CASE ... END, so track the stage and the depth of those.
+	var leading []string
+	inTrigger := false
+	stage := triggerHeader
+	depth := 0
+
+	var current Statement
+	emit := func(end int) {
+		if hasCode {
+			current.SQL = strings.TrimSpace(script[current.Offset:end])
+			stmts = append(stmts, current)
+		}
+		hasCode = false
+		leading = leading[:0]
+		inTrigger = false
+		stage = triggerHeader
+		depth = 0
+	}
+
+	markCode := func(i int) {
+		if !hasCode {
+			hasCode = true
+			current = Statement{Offset: i, Line: line}
+		}
+	}
+
+	for i := 0; i < len(script); i++ {
+		ch := script[i]
+		switch {
+		case ch == '\n':
+			line++
+
+		case ch == '-' && i+1 < len(script) && script[i+1] == '-':
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+			if i < len(script) {
+				line++
+			}
+
+		case ch == '/' && i+1 < len(script) && script[i+1] == '*':
+			i += 2
+			for i < len(script) && !(script[i] == '*' && i+1 < len(script) && script[i+1] == '/') {
+				if script[i] == '\n' {
+					line++
+				}
+				i++
+			}
+			i++
+
+		case ch == '\'' || ch == '"' || ch == '`' || ch == '[':
+			markCode(i)
+			if inTrigger && stage == triggerOn {
+				stage = triggerTable
+			}
+			closing := ch
+			if ch == '[' {
+				closing = ']'
+			}
+			for i++; i < len(script); i++ {
+				if script[i] == '\n' {
+					line++
+				}
+				if script[i] == closing {
+					// Doubled quotes are an escaped quote, not the end
+					if closing != ']' && i+1 < len(script) && script[i+1] == closing {
+						i++
+						continue
+					}
+					break
+				}
+			}
+
+		case ch == ';':
+			if inTrigger && stage == triggerBody && depth > 0 {
+				continue
+			}
+			emit(i)
+
+		case isWordChar(ch):
+			markCode(i)
+			j := i
+			for j < len(script) && isWordChar(script[j]) {
+				j++
+			}
+			word := strings.ToUpper(script[i:j])
+			i = j - 1
+
+			if len(leading) < 6 && !inTrigger {
+				leading = append(leading, word)
+				inTrigger = isCreateTrigger(leading)
+				continue
+			}
+			if !inTrigger {
+				continue
+			}
+			switch stage {
+			case triggerHeader:
+				if word == "ON" {
+					stage = triggerOn
+				}
+			case triggerOn:
+				stage = triggerTable
+			case triggerTable:
+				if word == "BEGIN" {
+					stage = triggerBody
+					depth = 1
+				}
+			case triggerBody:
+				switch word {
+				case "CASE":
+					depth++
+				case "END":
+					if depth > 0 {
+						depth--
+					}
+				}
+			}
+
+		case ch != ' ' && ch != '\t' && ch != '\r':
+			markCode(i)
+		}
+	}
+	emit(len(script))
+
+	return stmts
+}
+
+// SingleStatement returns the one statement of query, without the trailing
+// semicolon. Text holding no statement, or anything after the first, is
+// rejected, so what runs is exactly the statement that was checked.
+func SingleStatement(query string) (string, error) {
+	stmts := SplitStatements(query)
+	if len(stmts) != 1 {
+		return "", fmt.Errorf("%w, found %d", ErrNotSingleStatement, len(stmts))
+	}
+	return stmts[0].SQL, nil
+}
+
+// IsTransactionControl reports whether stmt begins, commits or rolls back a
+// transaction, which a script run inside a transaction of its own must skip
+func IsTransactionControl(stmt string) bool {
+	fields := strings.Fields(strings.ToUpper(stmt))
+	if len(fields) == 0 {
+		return false
+	}
+	switch fields[0] {
+	case "BEGIN", "COMMIT", "END", "ROLLBACK":
+		return true
+	}
+	return false
+}
+
+// isCreateTrigger reports whether the leading words are
+// [EXPLAIN [QUERY PLAN]] CREATE [TEMP|TEMPORARY] TRIGGER
+func isCreateTrigger(words []string) bool {
+	if len(words) > 0 && words[0] == "EXPLAIN" {
+		words = words[1:]
+		if len(words) >= 2 && words[0] == "QUERY" && words[1] == "PLAN" {
+			words = words[2:]
+		}
+	}
+	if len(words) < 2 || words[0] != "CREATE" {
+		return false
+	}
+	if words[1] == "TRIGGER" {
+		return true
+	}
+	return len(words) >= 3 && (words[1] == "TEMP" || words[1] == "TEMPORARY") && words[2] == "TRIGGER"
+}
+
+func isWordChar(ch byte) bool {
+	return ch == '_' || ch == '$' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9') || ch >= 0x80
+}
@@ -0,0 +1,177 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "plain",
+			script: "SELECT 1; SELECT 2;SELECT 3",
+			want:   []string{"SELECT 1", "SELECT 2", "SELECT 3"},
+		},
+		{
+			name:   "blank and comment-only statements",
+			script: ";;  -- nothing\n; /* nor this */ ;SELECT 1;",
+			want:   []string{"SELECT 1"},
+		},
+		{
+			name:   "semicolons in strings",
+			script: "INSERT INTO t VALUES ('a;b', 'it''s; fine'); SELECT 2",
+			want:   []string{"INSERT INTO t VALUES ('a;b', 'it''s; fine')", "SELECT 2"},
+		},
+		{
+			name:   "semicolons in quoted identifiers",
+			script: "SELECT \"a;b\", [c;d], `e;f` FROM t; SELECT 2",
+			want:   []string{"SELECT \"a;b\", [c;d], `e;f` FROM t", "SELECT 2"},
+		},
+		{
+			name:   "semicolons and keywords in comments",
+			script: "SELECT 1 -- ; CREATE TRIGGER BEGIN\n; /* ; BEGIN CASE */ SELECT 2",
+			want:   []string{"SELECT 1 -- ; CREATE TRIGGER BEGIN", "SELECT 2"},
+		},
+		{
+			name:   "keyword-named identifiers outside triggers",
+			script: "CREATE TABLE begin (end, \"case\"); SELECT end FROM begin; SELECT 3",
+			want:   []string{"CREATE TABLE begin (end, \"case\")", "SELECT end FROM begin", "SELECT 3"},
+		},
+		{
+			name:   "trigger",
+			script: "CREATE TRIGGER tr AFTER INSERT ON t BEGIN UPDATE t SET a = 1; DELETE FROM u; END; SELECT 1",
+			want:   []string{"CREATE TRIGGER tr AFTER INSERT ON t BEGIN UPDATE t SET a = 1; DELETE FROM u; END", "SELECT 1"},
+		},
+		{
+			name:   "temp trigger with when clause",
+			script: "CREATE TEMP TRIGGER IF NOT EXISTS tr BEFORE UPDATE ON t FOR EACH ROW WHEN new.a > 0 BEGIN SELECT 1; END; SELECT 2",
+			want:   []string{"CREATE TEMP TRIGGER IF NOT EXISTS tr BEFORE UPDATE ON t FOR EACH ROW WHEN new.a > 0 BEGIN SELECT 1; END", "SELECT 2"},
+		},
+		{
+			name:   "nested case in trigger body",
+			script: "CREATE TRIGGER tr AFTER INSERT ON t BEGIN UPDATE t SET a = CASE WHEN new.a THEN CASE new.b WHEN 1 THEN 2 END ELSE 3 END; SELECT 1; END; SELECT 2",
+			want:   []string{"CREATE TRIGGER tr AFTER INSERT ON t BEGIN UPDATE t SET a = CASE WHEN new.a THEN CASE new.b WHEN 1 THEN 2 END ELSE 3 END; SELECT 1; END", "SELECT 2"},
+		},
+		{
+			name:   "case in when clause",
+			script: "CREATE TRIGGER tr AFTER INSERT ON t WHEN CASE new.a WHEN 1 THEN 1 END BEGIN SELECT 1; END; SELECT 2",
+			want:   []string{"CREATE TRIGGER tr AFTER INSERT ON t WHEN CASE new.a WHEN 1 THEN 1 END BEGIN SELECT 1; END", "SELECT 2"},
+		},
+		{
+			name:   "column named begin in the header",
+			script: "CREATE TRIGGER tr AFTER UPDATE OF begin ON t BEGIN SELECT 1; END; ATTACH DATABASE '/tmp/x' AS e; PRAGMA foreign_keys=OFF",
+			want: []string{
+				"CREATE TRIGGER tr AFTER UPDATE OF begin ON t BEGIN SELECT 1; END",
+				"ATTACH DATABASE '/tmp/x' AS e",
+				"PRAGMA foreign_keys=OFF",
+			},
+		},
+		{
+			name:   "trigger and table named begin",
+			script: "CREATE TRIGGER begin AFTER INSERT ON begin BEGIN SELECT 1; END; SELECT 2",
+			want:   []string{"CREATE TRIGGER begin AFTER INSERT ON begin BEGIN SELECT 1; END", "SELECT 2"},
+		},
+		{
+			name:   "quoted table name",
+			script: "CREATE TRIGGER tr AFTER INSERT ON \"begin\" BEGIN SELECT 1; END; SELECT 2",
+			want:   []string{"CREATE TRIGGER tr AFTER INSERT ON \"begin\" BEGIN SELECT 1; END", "SELECT 2"},
+		},
+		{
+			name:   "explained trigger",
+			script: "EXPLAIN QUERY PLAN CREATE TRIGGER tr AFTER INSERT ON t BEGIN SELECT 1; END; SELECT 2",
+			want:   []string{"EXPLAIN QUERY PLAN CREATE TRIGGER tr AFTER INSERT ON t BEGIN SELECT 1; END", "SELECT 2"},
+		},
+		{
+			// "end" as a column ends the body early: the pieces fail to run
+			// instead of hiding the statements after the trigger
+			name:   "column named end in trigger body",
+			script: "CREATE TRIGGER tr AFTER INSERT ON t BEGIN UPDATE t SET end = 1; END; SELECT 2",
+			want:   []string{"CREATE TRIGGER tr AFTER INSERT ON t BEGIN UPDATE t SET end = 1", "END", "SELECT 2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, stmt := range SplitStatements(tt.script) {
+				got = append(got, stmt.SQL)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitStatements(%q)\n got %q\nwant %q", tt.script, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitStatementsPositions(t *testing.T) {
+	script := "-- header\nSELECT 1;\n\n  SELECT\n 2; /* x */ SELECT 'a\nb'; SELECT 4"
+	want := []Statement{
+		{SQL: "SELECT 1", Offset: 10, Line: 2},
+		{SQL: "SELECT\n 2", Offset: 23, Line: 4},
+		{SQL: "SELECT 'a\nb'", Offset: 42, Line: 5},
+		{SQL: "SELECT 4", Offset: 56, Line: 6},
+	}
+
+	got := SplitStatements(script)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitStatements positions\n got %+v\nwant %+v", got, want)
+	}
+}
+
+// TestSplitStatementsMatchSQLite runs the pieces one by one: each must be a
+// whole statement to SQLite, or the triggers would not be created intact
+func TestSplitStatementsMatchSQLite(t *testing.T) {
+	db := openTestDB(t)
+
+	script := `
+		CREATE TABLE t (a, b, "begin", "end");
+		CREATE TABLE log (msg);
+		CREATE TRIGGER tr AFTER UPDATE OF begin ON t FOR EACH ROW
+		WHEN CASE WHEN new.a > 0 THEN 1 ELSE 0 END
+		BEGIN
+			INSERT INTO log VALUES (CASE new.b WHEN 1 THEN 'one; b' ELSE CASE WHEN new.a THEN 'a' END END);
+			INSERT INTO log VALUES ('second; statement');
+		END;
+		INSERT INTO t VALUES (1, 1, 0, 0);
+		UPDATE t SET "begin" = 1`
+
+	stmts := SplitStatements(script)
+	if len(stmts) != 5 {
+		t.Fatalf("got %d statements, want 5: %+v", len(stmts), stmts)
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt.SQL); err != nil {
+			t.Fatalf("%s: %v", stmt.SQL, err)
+		}
+	}
+
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM log").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("the trigger logged %d rows, want 2", n)
+	}
+}
+
+func TestIsTransactionControl(t *testing.T) {
+	tests := map[string]bool{
+		"BEGIN":                 true,
+		"begin transaction":     true,
+		"COMMIT":                true,
+		"end":                   true,
+		"ROLLBACK":              true,
+		"SELECT 1":              false,
+		"CREATE TABLE begin(a)": false,
+		"":                      false,
+	}
+	for stmt, want := range tests {
+		if got := IsTransactionControl(stmt); got != want {
+			t.Errorf("IsTransactionControl(%q) = %v, want %v", stmt, got, want)
+		}
+	}
+}
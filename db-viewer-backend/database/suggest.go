@@ -0,0 +1,410 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"db-viewer/models"
+)
+
+// Statuses of a RelationshipSuggestion
+const (
+	SuggestionPending  = "pending"
+	SuggestionAccepted = "accepted"
+	SuggestionRejected = "rejected"
+)
+
+const (
+	// decisionsTable keeps accepted and rejected suggestions; userTables hides it
+	decisionsTable = "_dbviewer_relationships"
+	// maxSampledValues caps the distinct values read per column
+	maxSampledValues = 50000
+	// maxLoadedValues caps the distinct values held for all columns at once
+	maxLoadedValues = 500000
+	// maxComparedValues caps the value lookups of one round of suggestions
+	maxComparedValues = 2000000
+	// minInclusion is the share of a source's values a target must hold
+	minInclusion = 0.8
+)
+
+const decisionsSchema = `CREATE TABLE IF NOT EXISTS _dbviewer_relationships (
+	source_table TEXT NOT NULL,
+	source_column TEXT NOT NULL,
+	target_table TEXT NOT NULL,
+	target_column TEXT NOT NULL,
+	status TEXT NOT NULL,
+	decided_at TEXT NOT NULL,
+	PRIMARY KEY (source_table, source_column, target_table, target_column)
+)`
+
+// columnProfile is what suggestions are computed from for one column
+type columnProfile struct {
+	table, column string
+	// affinity is the type affinity SQLite gives the declared type
+	affinity string
+	distinct int64
+	// unique is set when every row holds a distinct non-NULL value
+	unique bool
+	// key is set for a single-column declared primary key
+	key bool
+	// declared is set when the column already has a foreign key
+	declared bool
+	// values holds up to maxSampledValues distinct values once loaded
+	values map[string]bool
+}
+
+// candidate is a source and target worth comparing the values of
+type candidate struct {
+	source, target *columnProfile
+	affinity       float64
+}
+
+// SuggestRelationships proposes foreign keys by comparing the value sets of
+// columns across tables. Targets are columns holding unique, non-NULL
+// values; sources are columns of other tables with a compatible type and no
+// more distinct values than a target could hold. A source qualifies when at
+// least minInclusion of its distinct values occur in the target.
+//
+// Confidence weighs that overlap by how much it shows: a handful of distinct
+// values, or a few values out of a large key, fit inside any key by chance,
+// as small integers fit inside any id column. It then adds how well the
+// source's name matches the target table and whether the target is a
+// declared key. A source whose name says nothing about the target scores at
+// most 0.65. Columns that already declare a foreign key, and single-column
+// primary keys, are not proposed as sources. Rejected suggestions are left
+// out unless includeRejected is set.
+//
+// The values read and compared are capped, the likeliest pairs (by name,
+// then by size) going first; truncated reports that some pairs were skipped.
+func SuggestRelationships(ctx context.Context, db *sql.DB, minConfidence float64, includeRejected bool) ([]models.RelationshipSuggestion, bool, error) {
+	tables, err := TableNames(ctx, db)
+	if err != nil {
+		return nil, false, err
+	}
+	var profiles []*columnProfile
+	for _, table := range tables {
+		tableProfiles, err := profileTable(ctx, db, table)
+		if err != nil {
+			return nil, false, err
+		}
+		profiles = append(profiles, tableProfiles...)
+	}
+	decisions, err := loadDecisions(ctx, db)
+	if err != nil {
+		return nil, false, err
+	}
+	statuses := decisionStatuses(decisions)
+
+	var candidates []candidate
+	for _, target := range profiles {
+		if !target.unique || target.distinct < 2 || target.distinct > maxSampledValues {
+			continue
+		}
+		for _, source := range profiles {
+			if source.declared || source.key || source.distinct == 0 ||
+				strings.EqualFold(source.table, target.table) ||
+				!compatibleAffinity(source.affinity, target.affinity) ||
+				float64(source.distinct)*minInclusion > float64(target.distinct) {
+				continue
+			}
+			candidates = append(candidates, candidate{source, target, nameAffinity(source.column, target.table)})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].affinity != candidates[j].affinity {
+			return candidates[i].affinity > candidates[j].affinity
+		}
+		return candidates[i].source.distinct < candidates[j].source.distinct
+	})
+
+	suggestions := []models.RelationshipSuggestion{}
+	truncated := false
+	loaded, compared := 0, 0
+	for _, cand := range candidates {
+		source, target := cand.source, cand.target
+
+		cost := int(min(source.distinct, maxSampledValues))
+		if compared+cost > maxComparedValues {
+			truncated = true
+			continue
+		}
+		ok := true
+		for _, p := range []*columnProfile{target, source} {
+			if p.values != nil {
+				continue
+			}
+			if loaded+int(min(p.distinct, maxSampledValues)) > maxLoadedValues {
+				ok = false
+				break
+			}
+			if err := loadValues(ctx, db, p); err != nil {
+				return nil, false, err
+			}
+			loaded += len(p.values)
+		}
+		if !ok {
+			truncated = true
+			continue
+		}
+		compared += len(source.values)
+
+		found := 0
+		for v := range source.values {
+			if target.values[v] {
+				found++
+			}
+		}
+		inclusion := float64(found) / float64(len(source.values))
+		if inclusion < minInclusion {
+			continue
+		}
+
+		confidence := suggestionConfidence(inclusion, source.distinct, target.distinct, cand.affinity, target.key)
+		if confidence < minConfidence {
+			continue
+		}
+
+		s := models.RelationshipSuggestion{
+			SourceTable:    source.table,
+			SourceColumn:   source.column,
+			TargetTable:    target.table,
+			TargetColumn:   target.column,
+			Confidence:     confidence,
+			Inclusion:      math.Round(inclusion*100) / 100,
+			SourceDistinct: source.distinct,
+			TargetDistinct: target.distinct,
+			Status:         SuggestionPending,
+		}
+		if status, ok := statuses[decisionKey(s.SourceTable, s.SourceColumn, s.TargetTable, s.TargetColumn)]; ok {
+			s.Status = status
+		}
+		if s.Status == SuggestionRejected && !includeRejected {
+			continue
+		}
+		suggestions = append(suggestions, s)
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Confidence > suggestions[j].Confidence
+	})
+	return suggestions, truncated, nil
+}
+
+// suggestionConfidence scores a source whose values occur in a target.
+// The overlap counts for little with few distinct source values (a weight
+// reaching 1 at about 30) and with few of the target's values used: five
+// small integers inside a thousand ids say nothing, eight hundred do.
+func suggestionConfidence(inclusion float64, sourceDistinct, targetDistinct int64, affinity float64, targetKey bool) float64 {
+	weight := math.Min(1, math.Log10(float64(sourceDistinct))/1.5)
+	coverage := math.Min(1, float64(sourceDistinct)/float64(targetDistinct))
+	overlap := inclusion * weight * (0.5 + 0.5*coverage)
+
+	keyScore := 0.3
+	if targetKey {
+		keyScore = 1
+	}
+	confidence := 0.5*overlap + 0.35*affinity + 0.15*keyScore
+	return math.Round(confidence*100) / 100
+}
+
+// profileTable reads the declared type, distinct values and uniqueness of
+// every column of table, in one pass over its rows
+func profileTable(ctx context.Context, db *sql.DB, table string) ([]*columnProfile, error) {
+	key, err := TableKey(db, table)
+	if err != nil {
+		return nil, err
+	}
+	declared, err := ForeignKeys(ctx, db, table)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT name, type FROM pragma_table_info(?) ORDER BY cid", table)
+	if err != nil {
+		return nil, err
+	}
+	var profiles []*columnProfile
+	for rows.Next() {
+		var col, typ string
+		if err := rows.Scan(&col, &typ); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		profiles = append(profiles, &columnProfile{
+			table:    table,
+			column:   col,
+			affinity: typeAffinity(typ),
+			key:      !key.RowID && len(key.Columns) == 1 && strings.EqualFold(key.Columns[0], col),
+			declared: hasForeignKey(declared, col),
+		})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(profiles) == 0 {
+		return nil, nil
+	}
+
+	counts := []string{"COUNT(*)"}
+	for _, p := range profiles {
+		counts = append(counts, fmt.Sprintf("COUNT(%[1]s), COUNT(DISTINCT %[1]s)", QuoteIdent(p.column)))
+	}
+	var total int64
+	nonNull := make([]int64, len(profiles))
+	dest := []interface{}{&total}
+	for i, p := range profiles {
+		dest = append(dest, &nonNull[i], &p.distinct)
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(counts, ", "), QuoteIdent(table))
+	if err := db.QueryRowContext(ctx, query).Scan(dest...); err != nil {
+		return nil, err
+	}
+	for i, p := range profiles {
+		p.unique = total > 0 && nonNull[i] == total && p.distinct == total
+	}
+	return profiles, nil
+}
+
+// loadValues reads up to maxSampledValues distinct values of a column
+func loadValues(ctx context.Context, db *sql.DB, p *columnProfile) error {
+	query := fmt.Sprintf("SELECT DISTINCT CAST(%[1]s AS TEXT) FROM %[2]s WHERE %[1]s IS NOT NULL LIMIT %[3]d", QuoteIdent(p.column), QuoteIdent(p.table), maxSampledValues)
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	p.values = make(map[string]bool)
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return err
+		}
+		p.values[v] = true
+	}
+	return rows.Err()
+}
+
+// typeAffinity applies SQLite's rules for the affinity of a declared type
+func typeAffinity(declared string) string {
+	t := strings.ToUpper(declared)
+	switch {
+	case strings.Contains(t, "INT"):
+		return "INTEGER"
+	case strings.Contains(t, "CHAR") || strings.Contains(t, "CLOB") || strings.Contains(t, "TEXT"):
+		return "TEXT"
+	case t == "" || strings.Contains(t, "BLOB"):
+		return "BLOB"
+	case strings.Contains(t, "REAL") || strings.Contains(t, "FLOA") || strings.Contains(t, "DOUB"):
+		return "REAL"
+	}
+	return "NUMERIC"
+}
+
+// compatibleAffinity reports whether values of one affinity can refer to a
+// key of the other: the same affinity, or numbers for numbers. Untyped
+// columns hold anything, so they match every affinity.
+func compatibleAffinity(source, target string) bool {
+	numeric := func(a string) bool { return a == "INTEGER" || a == "NUMERIC" || a == "REAL" }
+	return source == target || source == "BLOB" || target == "BLOB" || (numeric(source) && numeric(target))
+}
+
+// nameAffinity scores how well a column name refers to a table: 1 when its
+// stem (the name without an _id, _ref, _key, _code or _fk suffix) is the
+// table's name or singular, 0.5 when one contains the other, else 0
+func nameAffinity(column, table string) float64 {
+	stem := strings.ToLower(column)
+	for _, suffix := range []string{"_id", "_ref", "_key", "_code", "_fk", "id"} {
+		if strings.HasSuffix(stem, suffix) && len(stem) > len(suffix) {
+			stem = strings.TrimSuffix(stem, suffix)
+			break
+		}
+	}
+	table = strings.ToLower(table)
+	singular := singularize(table)
+
+	switch {
+	case stem == table || stem == singular:
+		return 1
+	case len(stem) >= 3 && (strings.Contains(stem, singular) || strings.Contains(singular, stem)):
+		return 0.5
+	}
+	return 0
+}
+
+// singularize undoes the common English plural endings of a table name
+func singularize(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 3:
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "ses") || strings.HasSuffix(name, "xes") || strings.HasSuffix(name, "ches") || strings.HasSuffix(name, "shes"):
+		return strings.TrimSuffix(name, "es")
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss"):
+		return strings.TrimSuffix(name, "s")
+	}
+	return name
+}
+
+// DecideRelationship records that a suggested relationship was accepted or
+// rejected. Accepted relationships show up in Relationships; rejected ones
+// are no longer suggested nor inferred from names.
+func DecideRelationship(ctx context.Context, db *sql.DB, d models.RelationshipDecision, status string) error {
+	if _, err := db.ExecContext(ctx, decisionsSchema); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, "INSERT INTO "+QuoteIdent(decisionsTable)+` VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO UPDATE SET status = excluded.status, decided_at = excluded.decided_at`,
+		d.SourceTable, d.SourceColumn, d.TargetTable, d.TargetColumn, status, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+// decision is a recorded accept or reject
+type decision struct {
+	models.RelationshipDecision
+	status string
+}
+
+// loadDecisions reads the recorded decisions, if any were made
+func loadDecisions(ctx context.Context, db *sql.DB) ([]decision, error) {
+	var exists int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", decisionsTable).Scan(&exists); err != nil || exists == 0 {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT source_table, source_column, target_table, target_column, status FROM "+QuoteIdent(decisionsTable)+" ORDER BY decided_at")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var decisions []decision
+	for rows.Next() {
+		var d decision
+		if err := rows.Scan(&d.SourceTable, &d.SourceColumn, &d.TargetTable, &d.TargetColumn, &d.status); err != nil {
+			return nil, err
+		}
+		decisions = append(decisions, d)
+	}
+	return decisions, rows.Err()
+}
+
+// decisionStatuses indexes decisions by decisionKey
+func decisionStatuses(decisions []decision) map[string]string {
+	statuses := make(map[string]string, len(decisions))
+	for _, d := range decisions {
+		statuses[decisionKey(d.SourceTable, d.SourceColumn, d.TargetTable, d.TargetColumn)] = d.status
+	}
+	return statuses
+}
+
+// decisionKey identifies a relationship; tables and columns compare
+// case-insensitively, as in SQLite
+func decisionKey(sourceTable, sourceColumn, targetTable, targetColumn string) string {
+	return strings.ToLower(strings.Join([]string{sourceTable, sourceColumn, targetTable, targetColumn}, "\x00"))
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+// suggestSchema builds a customers table of 1000 ids and an orders table
+// whose customer_id and buyer columns use 800 of them, next to integer
+// columns whose small values fit inside any id column by chance
+const suggestSchema = `
+CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT, referrer INT);
+WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 1000)
+INSERT INTO customers SELECT i, 'c' || i, (i % 500) + 1 FROM n;
+
+CREATE TABLE orders (
+	id INTEGER PRIMARY KEY,
+	customer_id INT,
+	buyer INT,
+	quantity INT,
+	status INT,
+	age INT,
+	code TEXT
+);
+WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 2000)
+INSERT INTO orders SELECT i, (i % 800) + 1, (i % 800) + 1, (i % 5) + 1, (i % 3) + 1, (i % 60) + 18, CAST((i % 800) + 1 AS TEXT) FROM n;
+`
+
+func openSuggestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db := openTestDB(t)
+	for _, stmt := range SplitStatements(suggestSchema) {
+		if _, err := db.Exec(stmt.SQL); err != nil {
+			t.Fatalf("%s: %v", stmt.SQL, err)
+		}
+	}
+	return db
+}
+
+func TestSuggestRelationships(t *testing.T) {
+	db := openSuggestDB(t)
+
+	suggestions, truncated, err := SuggestRelationships(context.Background(), db, 0.7, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if truncated {
+		t.Error("a small database should not be truncated")
+	}
+	if len(suggestions) != 1 {
+		t.Fatalf("got %d suggestions, want only orders.customer_id: %+v", len(suggestions), suggestions)
+	}
+	s := suggestions[0]
+	if s.SourceTable != "orders" || s.SourceColumn != "customer_id" || s.TargetTable != "customers" || s.TargetColumn != "id" {
+		t.Errorf("got %s.%s -> %s.%s, want orders.customer_id -> customers.id", s.SourceTable, s.SourceColumn, s.TargetTable, s.TargetColumn)
+	}
+	if s.Inclusion != 1 || s.SourceDistinct != 800 || s.TargetDistinct != 1000 {
+		t.Errorf("got inclusion %v of %d values in %d, want 1 of 800 in 1000", s.Inclusion, s.SourceDistinct, s.TargetDistinct)
+	}
+}
+
+func TestSuggestRelationshipsSkipsUnrelatedIntegers(t *testing.T) {
+	db := openSuggestDB(t)
+
+	// Even at the bar the default used to be, small integers inside the id
+	// range are not links
+	suggestions, _, err := SuggestRelationships(context.Background(), db, 0.5, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[string]float64)
+	for _, s := range suggestions {
+		found[s.SourceTable+"."+s.SourceColumn+"->"+s.TargetTable+"."+s.TargetColumn] = s.Confidence
+	}
+	for _, unrelated := range []string{
+		"orders.quantity->customers.id",
+		"orders.status->customers.id",
+		"orders.age->customers.id",
+	} {
+		if confidence, ok := found[unrelated]; ok {
+			t.Errorf("suggested %s with confidence %v", unrelated, confidence)
+		}
+	}
+
+	// Self-references and text holding numbers are never compared
+	for _, skipped := range []string{
+		"customers.referrer->customers.id",
+		"orders.code->customers.id",
+	} {
+		if _, ok := found[skipped]; ok {
+			t.Errorf("suggested %s", skipped)
+		}
+	}
+
+	// A column named after nothing is only suggested below the default bar
+	buyer, ok := found["orders.buyer->customers.id"]
+	if !ok {
+		t.Fatal("orders.buyer -> customers.id not suggested at 0.5")
+	}
+	if buyer >= 0.7 {
+		t.Errorf("orders.buyer scored %v, want below the default 0.7", buyer)
+	}
+}
+
+func TestSuggestionConfidence(t *testing.T) {
+	tests := []struct {
+		name           string
+		sourceDistinct int64
+		targetDistinct int64
+		affinity       float64
+		key            bool
+		min, max       float64
+	}{
+		{"named reference", 800, 1000, 1, true, 0.9, 1},
+		{"named reference into a small lookup table", 5, 5, 1, true, 0.7, 1},
+		{"unnamed reference", 800, 1000, 0, true, 0.5, 0.65},
+		{"small integers in a large key", 5, 1000, 0, true, 0, 0.3},
+		{"ages in a large key", 60, 1000, 0, true, 0, 0.5},
+		{"one value", 1, 1000, 0, true, 0, 0.15},
+	}
+	for _, tt := range tests {
+		got := suggestionConfidence(1, tt.sourceDistinct, tt.targetDistinct, tt.affinity, tt.key)
+		if got < tt.min || got > tt.max {
+			t.Errorf("%s: confidence %v, want between %v and %v", tt.name, got, tt.min, tt.max)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"db-viewer/models"
+)
+
+const workspaceExt = ".db"
+
+var (
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+	ErrWorkspaceExists   = errors.New("workspace already exists")
+	ErrInvalidWorkspace  = errors.New("workspace names may only contain letters, digits, '-' and '_'")
+	ErrWorkspaceBusy     = errors.New("workspace is in use by a running request or import")
+)
+
+var workspaceNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// WorkspaceManager keeps one SQLite file per workspace inside a directory
+// and caches the open connection of each workspace that has been used.
+type WorkspaceManager struct {
+	dir string
+
+	mu    sync.Mutex
+	conns map[string]*holder
+}
+
+// NewWorkspaceManager prepares dir to hold workspace files
+func NewWorkspaceManager(dir string) (*WorkspaceManager, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &WorkspaceManager{dir: dir, conns: make(map[string]*holder)}, nil
+}
+
+// Create makes a new, empty workspace
+func (w *WorkspaceManager) Create(name string) error {
+	if !workspaceNameRegex.MatchString(name) {
+		return ErrInvalidWorkspace
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.exists(name) {
+		return ErrWorkspaceExists
+	}
+
+	db, err := openFile(w.path(name))
+	if err != nil {
+		return err
+	}
+	w.conns[name] = newHolder(&w.mu, db)
+	return nil
+}
+
+// Open leases the connection of an existing workspace. The workspace cannot
+// be renamed or deleted until the lease is released.
+func (w *WorkspaceManager) Open(name string) (*Lease, error) {
+	if !workspaceNameRegex.MatchString(name) {
+		return nil, ErrInvalidWorkspace
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if h, ok := w.conns[name]; ok {
+		return h.lease(), nil
+	}
+	if !w.exists(name) {
+		return nil, ErrWorkspaceNotFound
+	}
+
+	db, err := openFile(w.path(name))
+	if err != nil {
+		return nil, err
+	}
+	h := newHolder(&w.mu, db)
+	w.conns[name] = h
+	return h.lease(), nil
+}
+
+// List describes every workspace in the directory, sorted by name
+func (w *WorkspaceManager) List() ([]models.WorkspaceInfo, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaces := []models.WorkspaceInfo{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), workspaceExt) {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), workspaceExt)
+		if !workspaceNameRegex.MatchString(name) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		_, open := w.conns[name]
+
+		workspaces = append(workspaces, models.WorkspaceInfo{
+			Name:       name,
+			SizeBytes:  info.Size(),
+			ModifiedAt: info.ModTime(),
+			Open:       open,
+		})
+	}
+
+	sort.Slice(workspaces, func(i, j int) bool { return workspaces[i].Name < workspaces[j].Name })
+	return workspaces, nil
+}
+
+// Rename moves a workspace to a new name, closing its connection first. A
+// workspace with requests or imports running on it is not renamed.
+func (w *WorkspaceManager) Rename(oldName, newName string) error {
+	if !workspaceNameRegex.MatchString(oldName) || !workspaceNameRegex.MatchString(newName) {
+		return ErrInvalidWorkspace
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.exists(oldName) {
+		return ErrWorkspaceNotFound
+	}
+	if w.exists(newName) {
+		return ErrWorkspaceExists
+	}
+	if w.busy(oldName) {
+		return ErrWorkspaceBusy
+	}
+
+	w.closeConn(oldName)
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		err := os.Rename(w.path(oldName)+suffix, w.path(newName)+suffix)
+		if err != nil && !(suffix != "" && errors.Is(err, os.ErrNotExist)) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete closes a workspace and removes its files from disk. A workspace
+// with requests or imports running on it is not deleted.
+func (w *WorkspaceManager) Delete(name string) error {
+	if !workspaceNameRegex.MatchString(name) {
+		return ErrInvalidWorkspace
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.exists(name) {
+		return ErrWorkspaceNotFound
+	}
+	if w.busy(name) {
+		return ErrWorkspaceBusy
+	}
+
+	w.closeConn(name)
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		err := os.Remove(w.path(name) + suffix)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes every cached connection, each once the requests and imports
+// still using it are done
+func (w *WorkspaceManager) Close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for name := range w.conns {
+		w.closeConn(name)
+	}
+}
+
+func (w *WorkspaceManager) path(name string) string {
+	return filepath.Join(w.dir, name+workspaceExt)
+}
+
+func (w *WorkspaceManager) exists(name string) bool {
+	_, err := os.Stat(w.path(name))
+	return err == nil
+}
+
+// busy reports whether a lease on the workspace is held; mu must be held
+func (w *WorkspaceManager) busy(name string) bool {
+	h, ok := w.conns[name]
+	return ok && h.leases > 0
+}
+
+// closeConn must be called with mu held
+func (w *WorkspaceManager) closeConn(name string) {
+	if h, ok := w.conns[name]; ok {
+		h.drop()
+		delete(w.conns, name)
+	}
+}
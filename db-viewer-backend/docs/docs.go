@@ -15,16 +15,627 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/db-info": {
+        "/constraints/{tableName}": {
+            "post": {
+                "description": "Rebuilds a table with a PRIMARY KEY and FOREIGN KEY ... REFERENCES constraints. Omitted fields are guessed from the column names: an \"id\" column with unique values becomes the primary key of a table without one, and \u003cname\u003e_id columns reference the primary key of the table \u003cname\u003e or \u003cname\u003es. A primary key over duplicate values returns 409 with the duplicates and changes nothing; rows that break a foreign key are kept and listed as violations. Tables using CHECK constraints, COLLATE, AUTOINCREMENT, ON CONFLICT clauses, deferred foreign keys, generated columns or STRICT are refused with 400.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Constraints"
+                ],
+                "summary": "Declare constraints",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Table name",
+                        "name": "tableName",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Constraints to declare",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/models.ConstraintsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ConstraintsResult"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs/{id}": {
+            "get": {
+                "description": "Returns the state, rows processed, bytes read and errors of a background import started by this session or workspace",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Jobs"
+                ],
+                "summary": "Get Job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.JobStatus"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/jobs/{id}/cancel": {
+            "post": {
+                "description": "Cancels a running background import started by this session or workspace; rows from batches already committed are kept",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Jobs"
+                ],
+                "summary": "Cancel Job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/query": {
+            "post": {
+                "description": "Executes a raw SQL query against the in-memory database. The query is stopped when the request ends, when its timeout passes or when it is canceled through POST /query/{id}/cancel. At most DB_VIEWER_MAX_QUERY_ROWS rows (or max_rows) are returned, with truncated set when there were more. Rows are arrays ordered like columns, which carry each column's declared type; statements that return no rows report rows_affected. With stream set (or Accept: application/x-ndjson) the result is written as NDJSON while it is read: a {\"query_id\", \"columns\"} line, one array per row and a closing {\"row_count\", \"truncated\", \"rows_affected\", \"elapsed_ms\"} or {\"error\", \"reason\"} line. Without script the query must hold exactly one statement. With script set every statement runs, optionally in one transaction, and each gets its own result; the first failing statement stops the script and is reported with its index and line. params binds ? placeholders from an array or :name, @name and $name placeholders from an object; scripts only take named params. Every statement is checked against the policy of the role (the X-Query-Role header, at most DB_VIEWER_QUERY_ROLE) before any runs; a rejected one fails the request with 403 and reason \"not_allowed\".",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "QueryExecuter"
+                ],
+                "summary": "Run SQL Query",
+                "parameters": [
+                    {
+                        "description": "SQL Query",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.QueryRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Role to run as: reader, editor or admin",
+                        "name": "X-Query-Role",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.QueryResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "408": {
+                        "description": "Request Timeout",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "499": {
+                        "description": "",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/query/explain": {
+            "post": {
+                "description": "Runs EXPLAIN QUERY PLAN on a single statement, which is planned but not run, and returns the plan as a tree. Each node carries its op (scan, search, temp_btree, subquery, ...), the table and index it uses and the constraint an index is searched with. warnings points at the nodes likely to be slow: full table scans, automatic indexes, temporary B-trees for ORDER BY, GROUP BY or DISTINCT, and correlated subqueries. params binds placeholders as in POST /query. Only statements the role may run can be explained, and PRAGMA statements never can.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "QueryExecuter"
+                ],
+                "summary": "Explain Query",
+                "parameters": [
+                    {
+                        "description": "SQL Query",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ExplainRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Role to run as: reader, editor or admin",
+                        "name": "X-Query-Role",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.QueryPlan"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "408": {
+                        "description": "Request Timeout",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/query/{id}/cancel": {
+            "post": {
+                "description": "Cancels a query started with the same query_id by this session or workspace; the query's own request fails with reason \"canceled\"",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "QueryExecuter"
+                ],
+                "summary": "Cancel Query",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Query ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/relationships/suggest": {
+            "get": {
+                "description": "Compares the distinct values of columns across tables and proposes foreign keys to columns holding unique values, from type-compatible columns of other tables, with the share of source values found in the target (inclusion). The confidence weighs that overlap by the number of distinct source values and the share of the target they cover, and adds how well the column name matches the target table and whether the target is a primary key; a column whose name says nothing about the target scores at most 0.65. The values read and compared are capped; truncated is set when pairs had to be skipped. Rejected suggestions are left out unless include_rejected is set.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Relationships"
+                ],
+                "summary": "Suggest relationships",
+                "parameters": [
+                    {
+                        "type": "number",
+                        "default": 0.7,
+                        "description": "Lowest confidence returned",
+                        "name": "min_confidence",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Also return rejected suggestions",
+                        "name": "include_rejected",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/relationships/suggest/accept": {
+            "post": {
+                "description": "Records a suggested relationship as accepted, so it shows up in /db-info. It does not declare a foreign key; use /constraints/{tableName} for that.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Relationships"
+                ],
+                "summary": "Accept relationship",
+                "parameters": [
+                    {
+                        "description": "Relationship",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.RelationshipDecision"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/relationships/suggest/reject": {
+            "post": {
+                "description": "Records a suggested relationship as rejected, so it is neither suggested again nor inferred from column names in /db-info",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Relationships"
+                ],
+                "summary": "Reject relationship",
+                "parameters": [
+                    {
+                        "description": "Relationship",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.RelationshipDecision"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/schema": {
+            "get": {
+                "description": "Returns every table with its columns, types, primary key, indexes and row count, plus the relationships between tables, without row data. sample adds up to that many leading rows of each table as a preview.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tables"
+                ],
+                "summary": "Get schema",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Preview rows per table (at most 100)",
+                        "name": "sample",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/session": {
+            "delete": {
+                "description": "Drops the in-memory database of the calling session",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Sessions"
+                ],
+                "summary": "End Session",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/table-data/{tableName}": {
             "get": {
-                "description": "Returns all tables, their rows, and inferred relationships based on column names (e.g., user_id -\u003e users)",
+                "description": "Returns a page of rows with the total matching the filter. Rows are sorted by sort (e.g. \"name,-age\", \"-\" for descending) and then by the primary key. Page with offset, or pass the next_cursor of the previous page as cursor to continue right after it. filter is a JSON condition {\"column\": \"age\", \"op\": \"gt\", \"value\": 30} with op one of eq, ne, lt, lte, gt, gte, like, in, is_null or not_null, combined with {\"and\": [...]} or {\"or\": [...]}; a JSON array is shorthand for \"and\".",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tables"
+                ],
+                "summary": "Get table data",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Table name",
+                        "name": "tableName",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 100,
+                        "description": "Rows per page (at most 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Rows to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort columns, e.g. name,-age",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "JSON filter",
+                        "name": "filter",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.TablePage"
+                        }
+                    }
+                }
+            }
+        },
+        "/upload": {
+            "post": {
+                "description": "Uploads a file and creates tables in SQLite: one per CSV file, one per XLSX sheet (named \u003cfile\u003e_\u003csheet\u003e), or one per JSON/NDJSON file with nested objects flattened into parent_child columns. SQLite databases (.sqlite/.db) are copied in table by table, and SQL scripts (.sql) run in one transaction with failed statements reported. Both are held to the policy of the query role (see POST /query): every statement of a script, and the CREATE statement of every object in a database, must pass it, and a database can only be uploaded by a role that may run CREATE and INSERT; otherwise the upload fails with 403. With split_arrays=true, arrays of objects in JSON become child tables linked by a \u003cparent\u003e_id column (parent_\u003cparent\u003e_id when the child objects have a field of that name); the parent objects must either all have an \"id\" or none, and a child object using the linking column fails the upload with 400. With async=true the import runs as a background job polled via /jobs/{id}.\nschema is a JSON object that pins column types, renames or skips columns and sets the primary key, e.g. {\"columns\": {\"zip\": {\"type\": \"VARCHAR\"}, \"Full Name\": {\"rename\": \"name\"}, \"tmp\": {\"skip\": true}}, \"primary_key\": [\"id\"]}. It applies to every sheet of a workbook and to the root table of a JSON upload. With dry_run=true nothing is written: the response holds the inferred schema and the first preview_rows parsed rows of each table.\nCSV files are sniffed for their delimiter (comma, semicolon, tab or pipe), encoding (UTF-8 with or without BOM, UTF-16 with BOM, otherwise Windows-1252) and stray quotes; delimiter, encoding and lazy_quotes override the detection. ragged decides what happens to rows with the wrong number of fields: reject (default), pad, truncate or fit (pad and truncate).\nWith constraints=true the tables the upload creates get a PRIMARY KEY on an \"id\" column holding unique values and FOREIGN KEY constraints from \u003cname\u003e_id columns to the primary key of the table \u003cname\u003e or \u003cname\u003es; rows that break them are rejected. Foreign keys are enforced; SQLite databases and SQL scripts keep their own, and rows breaking them are listed under violations.\nmode decides what happens to an existing table: create (default) fails with 409, replace swaps in the new data once it is loaded, append inserts into it and upsert updates the rows matching key_column and inserts the rest. For append and upsert the file's headers must match the table's columns (case-insensitively); a mismatch returns 400 with a \"mapping\" report.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "handleDBInfo"
+                    "DataFileUpload"
+                ],
+                "summary": "Upload CSV / XLSX / JSON / SQLite / SQL",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "CSV, XLSX, JSON, NDJSON, SQLite or SQL File",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Run the import as a background job",
+                        "name": "async",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Split arrays of JSON objects into child tables",
+                        "name": "split_arrays",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "default": "create",
+                        "description": "create, replace, append or upsert",
+                        "name": "mode",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Column matched on in upsert mode",
+                        "name": "key_column",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "JSON schema override",
+                        "name": "schema",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "CSV delimiter, e.g. ; or tab",
+                        "name": "delimiter",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "CSV encoding, e.g. utf-8 or windows-1252",
+                        "name": "encoding",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Accept stray quotes in CSV fields",
+                        "name": "lazy_quotes",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "default": "reject",
+                        "description": "reject, pad, truncate or fit",
+                        "name": "ragged",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Declare the primary and foreign keys the column names suggest",
+                        "name": "constraints",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Return the inferred schema and a preview without importing",
+                        "name": "dry_run",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Rows returned by a dry run",
+                        "name": "preview_rows",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Role SQL scripts and SQLite databases are checked against: reader, editor or admin",
+                        "name": "X-Query-Role",
+                        "in": "header"
+                    }
                 ],
-                "summary": "Get Database Schema \u0026 Data",
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -32,13 +643,48 @@ const docTemplate = `{
                             "type": "object",
                             "additionalProperties": true
                         }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
                     }
                 }
             }
         },
-        "/query": {
+        "/workspaces": {
+            "get": {
+                "description": "Lists every file-backed workspace",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Workspaces"
+                ],
+                "summary": "List Workspaces",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.WorkspaceInfo"
+                            }
+                        }
+                    }
+                }
+            },
             "post": {
-                "description": "Executes a raw SQL query against the in-memory database",
+                "description": "Creates a new, empty SQLite file for a workspace",
                 "consumes": [
                     "application/json"
                 ],
@@ -46,19 +692,89 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "QueryExecuter"
+                    "Workspaces"
                 ],
-                "summary": "Run SQL Query",
+                "summary": "Create Workspace",
                 "parameters": [
                     {
-                        "description": "SQL Query",
+                        "description": "Workspace",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.CreateWorkspaceRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/workspaces/{name}": {
+            "put": {
+                "description": "Renames a workspace and its file on disk; fails with 409 while a request or import is running on it",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Workspaces"
+                ],
+                "summary": "Rename Workspace",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Workspace name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New name",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/main.QueryRequest"
+                            "$ref": "#/definitions/models.RenameWorkspaceRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     }
+                }
+            },
+            "delete": {
+                "description": "Closes a workspace and removes its file from disk; fails with 409 while a request or import is running on it",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Workspaces"
+                ],
+                "summary": "Delete Workspace",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Workspace name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
                 "responses": {
                     "200": {
@@ -71,25 +787,22 @@ const docTemplate = `{
                 }
             }
         },
-        "/upload": {
+        "/workspaces/{name}/open": {
             "post": {
-                "description": "Uploads a CSV file and creates a table in SQLite",
-                "consumes": [
-                    "multipart/form-data"
-                ],
+                "description": "Opens a workspace and remembers it in a cookie for subsequent requests",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "DataFileUpload"
+                    "Workspaces"
                 ],
-                "summary": "Upload CSV",
+                "summary": "Open Workspace",
                 "parameters": [
                     {
-                        "type": "file",
-                        "description": "CSV File",
-                        "name": "file",
-                        "in": "formData",
+                        "type": "string",
+                        "description": "Workspace name",
+                        "name": "name",
+                        "in": "path",
                         "required": true
                     }
                 ],
@@ -106,12 +819,382 @@ const docTemplate = `{
         }
     },
     "definitions": {
-        "main.QueryRequest": {
+        "models.ConstraintViolation": {
+            "type": "object",
+            "properties": {
+                "columns": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "count": {
+                    "type": "integer"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "rowid": {
+                    "type": "integer"
+                },
+                "table": {
+                    "type": "string"
+                },
+                "target_table": {
+                    "type": "string"
+                },
+                "values": {
+                    "type": "array",
+                    "items": {}
+                }
+            }
+        },
+        "models.ConstraintsRequest": {
+            "type": "object",
+            "properties": {
+                "foreign_keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ForeignKey"
+                    }
+                },
+                "primary_key": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.ConstraintsResult": {
+            "type": "object",
+            "properties": {
+                "foreign_keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ForeignKey"
+                    }
+                },
+                "primary_key": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "table": {
+                    "type": "string"
+                },
+                "violations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ConstraintViolation"
+                    }
+                }
+            }
+        },
+        "models.CreateWorkspaceRequest": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "sales"
+                }
+            }
+        },
+        "models.ExplainRequest": {
+            "type": "object",
+            "properties": {
+                "params": {
+                    "type": "object"
+                },
+                "query": {
+                    "type": "string",
+                    "example": "SELECT * FROM orders WHERE customer_id = 7"
+                }
+            }
+        },
+        "models.ForeignKey": {
             "type": "object",
             "properties": {
+                "column": {
+                    "type": "string"
+                },
+                "target_column": {
+                    "type": "string"
+                },
+                "target_table": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.JobStatus": {
+            "type": "object",
+            "properties": {
+                "bytes_read": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "result": {},
+                "rows_processed": {
+                    "type": "integer"
+                },
+                "rows_rejected": {
+                    "type": "integer"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "total_bytes": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.PlanNode": {
+            "type": "object",
+            "properties": {
+                "automatic": {
+                    "type": "boolean"
+                },
+                "children": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.PlanNode"
+                    }
+                },
+                "constraint": {
+                    "type": "string"
+                },
+                "correlated": {
+                    "type": "boolean"
+                },
+                "covering": {
+                    "type": "boolean"
+                },
+                "detail": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "index": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "op": {
+                    "type": "string"
+                },
+                "purpose": {
+                    "type": "string"
+                },
+                "table": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.PlanWarning": {
+            "type": "object",
+            "properties": {
+                "kind": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "node_id": {
+                    "type": "integer"
+                },
+                "table": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.QueryColumn": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "nullable": {
+                    "type": "boolean"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.QueryPlan": {
+            "type": "object",
+            "properties": {
+                "plan": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.PlanNode"
+                    }
+                },
+                "warnings": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.PlanWarning"
+                    }
+                }
+            }
+        },
+        "models.QueryRequest": {
+            "type": "object",
+            "properties": {
+                "max_rows": {
+                    "description": "MaxRows lowers the number of rows returned; the server's own cap still applies unless streaming",
+                    "type": "integer",
+                    "example": 500
+                },
+                "params": {
+                    "description": "Params binds placeholders: an array by position (?, ?NNN), an object by\nname (:name, @name, $name). Values are JSON scalars or typed values such\nas {\"type\": \"blob\", \"value\": \"aGk=\"}.",
+                    "type": "object"
+                },
                 "query": {
                     "type": "string",
                     "example": "SELECT * FROM users"
+                },
+                "query_id": {
+                    "description": "QueryID names the query so it can be canceled while it runs; one is generated if empty",
+                    "type": "string",
+                    "example": "3f2a9c1e"
+                },
+                "script": {
+                    "description": "Script runs every statement of Query instead of only the first",
+                    "type": "boolean"
+                },
+                "stream": {
+                    "description": "Stream writes the result as NDJSON, one row per line, as rows are read",
+                    "type": "boolean"
+                },
+                "timeout_ms": {
+                    "description": "TimeoutMs overrides the server's default query timeout, up to its maximum",
+                    "type": "integer",
+                    "example": 5000
+                },
+                "transaction": {
+                    "description": "Transaction runs a script in a single transaction that a failing statement rolls back",
+                    "type": "boolean"
+                }
+            }
+        },
+        "models.QueryResult": {
+            "type": "object",
+            "properties": {
+                "columns": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.QueryColumn"
+                    }
+                },
+                "elapsed_ms": {
+                    "type": "number"
+                },
+                "query_id": {
+                    "type": "string"
+                },
+                "row_count": {
+                    "type": "integer"
+                },
+                "rows": {
+                    "type": "array",
+                    "items": {
+                        "type": "array",
+                        "items": {}
+                    }
+                },
+                "rows_affected": {
+                    "type": "integer"
+                },
+                "truncated": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "models.RelationshipDecision": {
+            "type": "object",
+            "properties": {
+                "source_column": {
+                    "type": "string"
+                },
+                "source_table": {
+                    "type": "string"
+                },
+                "target_column": {
+                    "type": "string"
+                },
+                "target_table": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.RenameWorkspaceRequest": {
+            "type": "object",
+            "properties": {
+                "new_name": {
+                    "type": "string",
+                    "example": "sales_2024"
+                }
+            }
+        },
+        "models.TablePage": {
+            "type": "object",
+            "properties": {
+                "columns": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "next_cursor": {
+                    "type": "string"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "rows": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "additionalProperties": true
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.WorkspaceInfo": {
+            "type": "object",
+            "properties": {
+                "modified_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "open": {
+                    "type": "boolean"
+                },
+                "size_bytes": {
+                    "type": "integer"
                 }
             }
         }
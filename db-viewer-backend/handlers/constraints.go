@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"db-viewer/database"
+	"db-viewer/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleApplyConstraints declares primary and foreign keys on a table
+// @Summary      Declare constraints
+// @Description  Rebuilds a table with a PRIMARY KEY and FOREIGN KEY ... REFERENCES constraints. Omitted fields are guessed from the column names: an "id" column with unique values becomes the primary key of a table without one, and <name>_id columns reference the primary key of the table <name> or <name>s. A primary key over duplicate values returns 409 with the duplicates and changes nothing; rows that break a foreign key are kept and listed as violations. Tables using CHECK constraints, COLLATE, AUTOINCREMENT, ON CONFLICT clauses, deferred foreign keys, generated columns or STRICT are refused with 400.
+// @Tags         Constraints
+// @Accept       json
+// @Produce      json
+// @Param        tableName path string true "Table name"
+// @Param        request body models.ConstraintsRequest false "Constraints to declare"
+// @Success      200  {object}  models.ConstraintsResult
+// @Failure      409  {object}  map[string]interface{}
+// @Router       /constraints/{tableName} [post]
+func HandleApplyConstraints(c *gin.Context) {
+	db := getDB(c)
+
+	var req models.ConstraintsRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	tableName, err := database.ResolveTable(db, c.Param("tableName"))
+	if err != nil {
+		respondIdentError(c, err)
+		return
+	}
+
+	if len(req.PrimaryKey) == 0 || len(req.ForeignKeys) == 0 {
+		guess, err := database.GuessConstraints(c.Request.Context(), db, tableName)
+		if err != nil {
+			respondIdentError(c, err)
+			return
+		}
+		if len(req.PrimaryKey) == 0 {
+			req.PrimaryKey = guess.PrimaryKey
+		}
+		if len(req.ForeignKeys) == 0 {
+			req.ForeignKeys = guess.ForeignKeys
+		}
+	}
+
+	result, err := database.ApplyConstraints(c.Request.Context(), db, tableName, req)
+	switch {
+	case errors.Is(err, database.ErrDuplicateKeys):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "result": result})
+		return
+	case errors.Is(err, database.ErrInvalidConstraint):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		respondIdentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"db-viewer/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	dbContextKey    = "db"
+	leaseContextKey = "lease"
+	workspaceHeader = "X-Workspace"
+	workspaceCookie = "workspace"
+	sessionHeader   = "X-Session-ID"
+	sessionCookie   = "session_id"
+)
+
+// UseDatabase picks the connection every following handler runs against.
+// In memory storage mode every client session gets its own database, keyed
+// by the X-Session-ID header or the "session_id" cookie. In file storage
+// mode the workspace comes from the X-Workspace header, the "workspace"
+// query parameter or cookie, in that order. The session's or workspace's
+// database is leased for the length of the request.
+func UseDatabase() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if database.Workspaces == nil {
+			lease, token, err := database.Sessions.Get(sessionToken(c))
+			if errors.Is(err, database.ErrTooManySessions) {
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Too many sessions are in use; try again later"})
+				return
+			}
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			defer lease.Release()
+
+			c.Header(sessionHeader, token)
+			c.SetCookie(sessionCookie, token, 0, "/", "", false, true)
+			c.Set(dbContextKey, lease.DB)
+			c.Set(leaseContextKey, lease)
+			c.Next()
+			return
+		}
+
+		lease, err := database.Workspaces.Open(workspaceName(c))
+		if err != nil {
+			respondWorkspaceError(c, err)
+			c.Abort()
+			return
+		}
+		defer lease.Release()
+
+		c.Set(dbContextKey, lease.DB)
+		c.Set(leaseContextKey, lease)
+		c.Next()
+	}
+}
+
+// getDB returns the connection chosen by UseDatabase
+func getDB(c *gin.Context) *sql.DB {
+	return c.MustGet(dbContextKey).(*sql.DB)
+}
+
+// extendLease keeps the database chosen by UseDatabase open for work that
+// outlives the request; the returned function must be called once it is done
+func extendLease(c *gin.Context) func() {
+	return c.MustGet(leaseContextKey).(*database.Lease).Extend().Release
+}
+
+// sessionToken reads the session token from the header or cookie. It is
+// never taken from the URL, where it would end up in logs and histories.
+func sessionToken(c *gin.Context) string {
+	if token := c.GetHeader(sessionHeader); token != "" {
+		return token
+	}
+	token, _ := c.Cookie(sessionCookie)
+	return token
+}
+
+// HandleEndSession discards the caller's in-memory database
+// @Summary      End Session
+// @Description  Drops the in-memory database of the calling session
+// @Tags         Sessions
+// @Produce      json
+// @Success      200  {object}  map[string]interface{}
+// @Router       /session [delete]
+func HandleEndSession(c *gin.Context) {
+	if database.Sessions == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sessions are only used with DB_VIEWER_STORAGE=memory"})
+		return
+	}
+
+	database.Sessions.End(sessionToken(c))
+	c.SetCookie(sessionCookie, "", -1, "/", "", false, true)
+	c.JSON(http.StatusOK, gin.H{"message": "Session ended"})
+}
+
+func workspaceName(c *gin.Context) string {
+	if name := c.GetHeader(workspaceHeader); name != "" {
+		return name
+	}
+	if name := c.Query("workspace"); name != "" {
+		return name
+	}
+	if name, err := c.Cookie(workspaceCookie); err == nil && name != "" {
+		return name
+	}
+	return database.DefaultWorkspace
+}
@@ -3,9 +3,12 @@ package handlers
 import (
 	"database/sql"
 	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"mime"
 	"net/http"
-	"regexp"
+	"strconv"
 	"strings"
 
 	"db-viewer/database" // REPLACE 'db-viewer' WITH YOUR ACTUAL MODULE NAME
@@ -14,172 +17,36 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// HandleFileUpload uploads a CSV
-// @Summary      Upload CSV
-// @Description  Uploads a CSV file and creates a table in SQLite
-// @Tags         DataFileUpload
-// @Accept       multipart/form-data
-// @Produce      json
-// @Param        file formData file true "CSV File"
-// @Success      200  {object}  map[string]interface{}
-// @Router       /upload [post]
-func HandleFileUpload(c *gin.Context) {
-	fileHeader, err := c.FormFile("file")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
-		return
-	}
-
-	file, err := fileHeader.Open()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to open file"})
-		return
-	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse CSV"})
-		return
-	}
-
-	if len(records) < 1 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "CSV is empty"})
-		return
-	}
-
-	tableName := strings.Split(fileHeader.Filename, ".")[0]
-	tableName = strings.ReplaceAll(tableName, " ", "_")
-	headers := records[0]
-
-	// --- NEW LOGIC: Infer Types from Data ---
-	// We pass the data rows (records[1:]) to guess the types
-	columnTypes := guessColumnTypes(headers, records[1:])
-
-	createTableSQL := buildSmartCreateTableSQL(tableName, headers, columnTypes)
-
-	if _, err := database.DB.Exec(createTableSQL); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create table: " + err.Error()})
-		return
-	}
-
-	if err := insertData(tableName, records[1:]); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to insert data"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message":   "Table created successfully",
-		"tableName": tableName,
-		"columns":   headers,
-	})
-}
-
-// HandleQuery runs SQL
-// @Summary      Run SQL Query
-// @Description  Executes a raw SQL query against the in-memory database
-// @Tags         QueryExecuter
-// @Accept       json
-// @Produce      json
-// @Param        request body models.QueryRequest true "SQL Query"
-// @Success      200  {object}  map[string]interface{}
-// @Router       /query [post]
-func HandleQuery(c *gin.Context) {
-	var req models.QueryRequest
-
-	if err := c.BindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
-		return
-	}
-
-	rows, err := database.DB.Query(req.Query)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-	defer rows.Close()
-
-	columns, _ := rows.Columns()
-	count := len(columns)
-	tableData := []map[string]interface{}{}
-
-	for rows.Next() {
-		values := make([]interface{}, count)
-		valuePtrs := make([]interface{}, count)
-
-		for i := range columns {
-			valuePtrs[i] = &values[i]
-		}
-
-		rows.Scan(valuePtrs...)
-
-		entry := make(map[string]interface{})
-		for i, col := range columns {
-			var v interface{}
-			val := values[i]
-			b, ok := val.([]byte)
-			if ok {
-				v = string(b)
-			} else {
-				v = val
-			}
-			entry[col] = v
-		}
-		tableData = append(tableData, entry)
-	}
-
-	c.JSON(http.StatusOK, gin.H{"data": tableData})
-}
-
-// HandleGetDBInfo returns Schema + Data + Relationships
+// HandleGetDBInfo returns Schema + Data + Relationships. It reads every row
+// of every table; GET /schema describes the tables without their rows.
 func HandleGetDBInfo(c *gin.Context) {
-	rows, err := database.DB.Query("SELECT name FROM sqlite_master WHERE type='table'")
+	db := getDB(c)
+
+	tableNames, err := database.TableNames(c.Request.Context(), db)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
 
 	var tables []models.TableInfo
-	var tableNames []string
-
-	for rows.Next() {
-		var name string
-		rows.Scan(&name)
-		tableNames = append(tableNames, name)
-	}
-	rows.Close()
 
 	for _, tbl := range tableNames {
 		// Get Schema
-		schemaRows, err := database.DB.Query(fmt.Sprintf("PRAGMA table_info(%s)", tbl))
+		fullColumns, err := database.Columns(c.Request.Context(), db, tbl)
 		if err != nil {
 			continue
 		}
 
-		var fullColumns []models.ColumnInfo
-
-		for schemaRows.Next() {
-			var cid int
-			var name, ctype string
-			var notnull, pk int
-			var dflt interface{}
-
-			schemaRows.Scan(&cid, &name, &ctype, &notnull, &dflt, &pk)
-			if ctype == "" {
-				ctype = "VARCHAR"
-			}
-
-			fullColumns = append(fullColumns, models.ColumnInfo{
-				Name: name,
-				Type: ctype,
-			})
+		// Get Key
+		primaryKey := []string{}
+		selectList := "*"
+		if key, err := database.TableKey(db, tbl); err == nil {
+			primaryKey = key.Columns
+			selectList = key.SelectColumns()
 		}
-		schemaRows.Close()
 
 		// Get Data
-		dataRows, err := database.DB.Query(fmt.Sprintf("SELECT * FROM %s", tbl))
+		dataRows, err := db.Query("SELECT " + selectList + " FROM " + database.QuoteIdent(tbl))
 		var tableData []map[string]interface{}
 
 		if err == nil {
@@ -211,29 +78,18 @@ func HandleGetDBInfo(c *gin.Context) {
 		}
 
 		tables = append(tables, models.TableInfo{
-			Name:    tbl,
-			Columns: fullColumns,
-			Rows:    tableData,
+			Name:       tbl,
+			Columns:    fullColumns,
+			PrimaryKey: primaryKey,
+			Rows:       tableData,
 		})
 	}
 
-	// Calculate Relationships
-	relationships := []models.Relationship{}
-	for _, sourceTbl := range tables {
-		for _, col := range sourceTbl.Columns {
-			if strings.HasSuffix(col.Name, "_id") {
-				targetTblName := strings.TrimSuffix(col.Name, "_id")
-				for _, targetTbl := range tableNames {
-					if targetTbl == targetTblName || targetTbl == targetTblName+"s" {
-						relationships = append(relationships, models.Relationship{
-							SourceTable:  sourceTbl.Name,
-							SourceColumn: col.Name,
-							TargetTable:  targetTbl,
-						})
-					}
-				}
-			}
-		}
+	// Get Relationships, declared and inferred
+	relationships, err := database.Relationships(c.Request.Context(), db, tableNames)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -244,14 +100,24 @@ func HandleGetDBInfo(c *gin.Context) {
 
 // HandleAddColumn executes ALTER TABLE
 func HandleAddColumn(c *gin.Context) {
+	db := getDB(c)
+
 	var req models.AddColumnRequest
 	if err := c.BindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
 
-	tableName := strings.ReplaceAll(req.TableName, " ", "_")
-	colName := strings.ReplaceAll(req.ColumnName, " ", "_")
+	tableName, err := database.ResolveTable(db, req.TableName)
+	if err != nil {
+		respondIdentError(c, err)
+		return
+	}
+	colName, err := database.CleanIdent(req.ColumnName)
+	if err != nil {
+		respondIdentError(c, err)
+		return
+	}
 	colType := strings.ToUpper(req.ColumnType)
 
 	validTypes := map[string]bool{"VARCHAR": true, "INT": true, "DECIMAL": true, "REAL": true, "BOOLEAN": true}
@@ -259,9 +125,9 @@ func HandleAddColumn(c *gin.Context) {
 		colType = "VARCHAR"
 	}
 
-	query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", tableName, colName, colType)
+	query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", database.QuoteIdent(tableName), database.QuoteIdent(colName), colType)
 
-	if _, err := database.DB.Exec(query); err != nil {
+	if _, err := db.Exec(query); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -271,16 +137,23 @@ func HandleAddColumn(c *gin.Context) {
 
 // HandleExportCSV streams the table data
 func HandleExportCSV(c *gin.Context) {
-	tableName := c.Param("tableName")
-	rows, err := database.DB.Query(fmt.Sprintf("SELECT * FROM %s", tableName))
+	db := getDB(c)
+
+	tableName, err := database.ResolveTable(db, c.Param("tableName"))
+	if err != nil {
+		respondIdentError(c, err)
+		return
+	}
+
+	rows, err := db.Query("SELECT * FROM " + database.QuoteIdent(tableName))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Table not found"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	defer rows.Close()
 
 	c.Header("Content-Description", "File Transfer")
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.csv", tableName))
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": tableName + ".csv"}))
 	c.Header("Content-Type", "text/csv")
 
 	writer := csv.NewWriter(c.Writer)
@@ -314,222 +187,267 @@ func HandleExportCSV(c *gin.Context) {
 	writer.Flush()
 }
 
-// HandleGetTableData fetches only the rows
+// HandleGetTableData fetches one page of a table's rows
+// @Summary      Get table data
+// @Description  Returns a page of rows with the total matching the filter. Rows are sorted by sort (e.g. "name,-age", "-" for descending) and then by the primary key. Page with offset, or pass the next_cursor of the previous page as cursor to continue right after it. filter is a JSON condition {"column": "age", "op": "gt", "value": 30} with op one of eq, ne, lt, lte, gt, gte, like, in, is_null or not_null, combined with {"and": [...]} or {"or": [...]}; a JSON array is shorthand for "and".
+// @Tags         Tables
+// @Produce      json
+// @Param        tableName path  string true  "Table name"
+// @Param        limit     query int    false "Rows per page (at most 1000)" default(100)
+// @Param        offset    query int    false "Rows to skip"
+// @Param        cursor    query string false "next_cursor of the previous page"
+// @Param        sort      query string false "Sort columns, e.g. name,-age"
+// @Param        filter    query string false "JSON filter"
+// @Success      200  {object}  models.TablePage
+// @Router       /table-data/{tableName} [get]
 func HandleGetTableData(c *gin.Context) {
-	tableName := c.Param("tableName")
-	rows, err := database.DB.Query(fmt.Sprintf("SELECT * FROM %s", tableName))
+	db := getDB(c)
+
+	tableName, err := database.ResolveTable(db, c.Param("tableName"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondIdentError(c, err)
 		return
 	}
-	defer rows.Close()
 
-	cols, _ := rows.Columns()
-	var result []map[string]interface{}
-
-	for rows.Next() {
-		values := make([]interface{}, len(cols))
-		valuePtrs := make([]interface{}, len(cols))
-		for i := range cols {
-			valuePtrs[i] = &values[i]
-		}
-		rows.Scan(valuePtrs...)
+	req, err := parsePageRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-		entry := make(map[string]interface{})
-		for i, col := range cols {
-			val := values[i]
-			if b, ok := val.([]byte); ok {
-				entry[col] = string(b)
-			} else {
-				entry[col] = val
-			}
-		}
-		result = append(result, entry)
+	page, err := database.ReadPage(c.Request.Context(), db, tableName, req)
+	if err != nil {
+		respondIdentError(c, err)
+		return
 	}
-	c.JSON(http.StatusOK, result)
+	c.JSON(http.StatusOK, page)
 }
 
 // HandleUpdateCell executes the SQL Update
 func HandleUpdateCell(c *gin.Context) {
+	db := getDB(c)
+
 	var req models.UpdateCellRequest
 	if err := c.BindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
 
-	tableName := strings.ReplaceAll(req.TableName, " ", "_")
-	colName := strings.ReplaceAll(req.ColumnName, " ", "_")
+	tableName, err := database.ResolveTable(db, req.TableName)
+	if err != nil {
+		respondIdentError(c, err)
+		return
+	}
+	colName, err := database.ResolveColumn(db, tableName, req.ColumnName)
+	if err != nil {
+		respondIdentError(c, err)
+		return
+	}
+	where, keyArgs, err := rowCondition(db, tableName, req.Key, req.RecordID)
+	if err != nil {
+		respondIdentError(c, err)
+		return
+	}
 
-	query := fmt.Sprintf("UPDATE %s SET %s = ? WHERE id = ?", tableName, colName)
+	query := fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s", database.QuoteIdent(tableName), database.QuoteIdent(colName), where)
 
-	if _, err := database.DB.Exec(query, req.NewValue, req.RecordID); err != nil {
+	res, err := db.Exec(query, append([]interface{}{req.NewValue}, keyArgs...)...)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No row matches the key"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Updated successfully"})
 }
 
 // HandleInsertRow inserts a new row
 func HandleInsertRow(c *gin.Context) {
+	db := getDB(c)
+
 	var req models.InsertRowRequest
 	if err := c.BindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
 
-	tableName := strings.ReplaceAll(req.TableName, " ", "_")
-
-	var maxID sql.NullInt64
-	err := database.DB.QueryRow(fmt.Sprintf("SELECT MAX(id) FROM %s", tableName)).Scan(&maxID)
-
-	nextID := 1
-	if err == nil && maxID.Valid {
-		nextID = int(maxID.Int64) + 1
+	tableName, err := database.ResolveTable(db, req.TableName)
+	if err != nil {
+		respondIdentError(c, err)
+		return
 	}
+	quoted := database.QuoteIdent(tableName)
 
-	query := fmt.Sprintf("INSERT INTO %s (id) VALUES (?)", tableName)
-	_, err = database.DB.Exec(query, nextID)
+	key, err := database.TableKey(db, tableName)
 	if err != nil {
-		_, err = database.DB.Exec(fmt.Sprintf("INSERT INTO %s DEFAULT VALUES", tableName))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var cols, placeholders []string
+	var args []interface{}
+	values := make(map[string]interface{}, len(req.Values))
+	for name, v := range req.Values {
+		col, err := database.ResolveColumn(db, tableName, name)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			respondIdentError(c, err)
 			return
 		}
+		values[col] = database.KeyValue(v)
+		cols = append(cols, database.QuoteIdent(col))
+		placeholders = append(placeholders, "?")
+		args = append(args, values[col])
+	}
+
+	// A single integer key SQLite does not fill in itself gets MAX + 1
+	if len(key.Columns) == 1 && !key.Generated && strings.Contains(strings.ToUpper(key.Types[0]), "INT") {
+		if _, given := values[key.Columns[0]]; !given {
+			var maxID sql.NullInt64
+			if err := db.QueryRow("SELECT MAX(" + database.QuoteIdent(key.Columns[0]) + ") FROM " + quoted).Scan(&maxID); err == nil {
+				values[key.Columns[0]] = maxID.Int64 + 1
+				cols = append(cols, database.QuoteIdent(key.Columns[0]))
+				placeholders = append(placeholders, "?")
+				args = append(args, maxID.Int64+1)
+			}
+		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Row created", "id": nextID})
+	// Rows with a NULL key could never be addressed again
+	if !key.Generated {
+		var missing []string
+		for _, col := range key.Columns {
+			if values[col] == nil {
+				missing = append(missing, col)
+			}
+		}
+		if len(missing) > 0 {
+			respondIdentError(c, fmt.Errorf("%w: new rows need a value for %s", database.ErrInvalidKey, strings.Join(missing, ", ")))
+			return
+		}
+	}
+
+	query := "INSERT INTO " + quoted + " DEFAULT VALUES"
+	if len(cols) > 0 {
+		query = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quoted, strings.Join(cols, ", "), strings.Join(placeholders, ", "))
+	}
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Read the key back for keys SQLite generated
+	newKey := make(map[string]interface{}, len(key.Columns))
+	if rowID, err := res.LastInsertId(); err == nil && key.Generated {
+		newKey[key.Columns[0]] = rowID
+	} else {
+		for _, col := range key.Columns {
+			newKey[col] = values[col]
+		}
+	}
+
+	response := gin.H{"message": "Row created", "key": newKey}
+	if len(key.Columns) == 1 {
+		response["id"] = newKey[key.Columns[0]]
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // HandleDeleteRow deletes a row
 func HandleDeleteRow(c *gin.Context) {
+	db := getDB(c)
+
 	var req models.DeleteRowRequest
 	if err := c.BindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
 
-	tableName := strings.ReplaceAll(req.TableName, " ", "_")
-	query := fmt.Sprintf("DELETE FROM %s WHERE id = ?", tableName)
+	tableName, err := database.ResolveTable(db, req.TableName)
+	if err != nil {
+		respondIdentError(c, err)
+		return
+	}
+	where, args, err := rowCondition(db, tableName, req.Key, req.RecordID)
+	if err != nil {
+		respondIdentError(c, err)
+		return
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s", database.QuoteIdent(tableName), where)
 
-	if _, err := database.DB.Exec(query, req.RecordID); err != nil {
+	res, err := db.Exec(query, args...)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No row matches the key"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Row deleted successfully"})
 }
 
 // --- HELPER FUNCTIONS ---
 
-func buildCreateTableSQL(tableName string, headers []string) string {
-	var cols []string
-	for _, h := range headers {
-		cleanHeader := strings.ReplaceAll(h, " ", "_")
-		cols = append(cols, fmt.Sprintf("%s TEXT", cleanHeader))
-	}
-	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s);", tableName, strings.Join(cols, ", "))
-}
-
-func insertData(tableName string, rows [][]string) error {
-	tx, err := database.DB.Begin()
-	if err != nil {
-		return err
-	}
-
-	placeholders := make([]string, len(rows[0]))
-	for i := range placeholders {
-		placeholders[i] = "?"
-	}
-	stmtStr := fmt.Sprintf("INSERT INTO %s VALUES (%s)", tableName, strings.Join(placeholders, ","))
-
-	stmt, err := tx.Prepare(stmtStr)
+// rowCondition picks a row by its primary key, given as a key object or the
+// legacy single record id
+func rowCondition(db *sql.DB, table string, values map[string]interface{}, recordID string) (string, []interface{}, error) {
+	key, err := database.TableKey(db, table)
 	if err != nil {
-		return err
+		return "", nil, err
 	}
-	defer stmt.Close()
-
-	for _, row := range rows {
-		args := make([]interface{}, len(row))
-		for i, v := range row {
-			args[i] = v
-		}
-		if _, err := stmt.Exec(args...); err != nil {
-			return err
-		}
-	}
-	return tx.Commit()
+	return key.Condition(values, recordID)
 }
 
-// inferColumnType (Private helper, currently unused but preserved)
-func inferColumnType(values []string) string {
-	if len(values) == 0 {
-		return "VARCHAR"
+// parsePageRequest reads the paging, sorting and filtering query parameters
+func parsePageRequest(c *gin.Context) (database.PageRequest, error) {
+	req := database.PageRequest{
+		Cursor: c.Query("cursor"),
+		Sort:   database.ParseSort(c.Query("sort")),
 	}
-	isInt := true
-	isFloat := true
-	isBool := true
-	hasData := false
-
-	intRegex := regexp.MustCompile(`^-?\d+$`)
-	floatRegex := regexp.MustCompile(`^-?\d*\.\d+$`)
-
-	for _, v := range values {
-		if v == "" {
-			continue
-		}
-		hasData = true
-		if !intRegex.MatchString(v) {
-			isInt = false
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return req, fmt.Errorf("invalid limit %q", v)
 		}
-		if !floatRegex.MatchString(v) && !intRegex.MatchString(v) {
-			isFloat = false
+		req.Limit = n
+	}
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return req, fmt.Errorf("invalid offset %q", v)
 		}
-		lowerV := strings.ToLower(v)
-		if lowerV != "true" && lowerV != "false" && lowerV != "0" && lowerV != "1" && lowerV != "yes" && lowerV != "no" {
-			isBool = false
+		req.Offset = n
+	}
+	if v := strings.TrimSpace(c.Query("filter")); v != "" {
+		var filter models.Filter
+		var err error
+		if strings.HasPrefix(v, "[") {
+			err = json.Unmarshal([]byte(v), &filter.And)
+		} else {
+			err = json.Unmarshal([]byte(v), &filter)
 		}
-	}
-
-	if !hasData {
-		return "VARCHAR"
-	}
-	if isBool {
-		return "BOOL"
-	}
-	if isInt {
-		return "INT"
-	}
-	if isFloat {
-		return "DECIMAL"
-	}
-	return "VARCHAR"
-}
-
-func guessColumnTypes(headers []string, rows [][]string) []string {
-	colTypes := make([]string, len(headers))
-
-	for i := range headers {
-		// Extract all values for this specific column
-		var colValues []string
-		for _, row := range rows {
-			if i < len(row) {
-				colValues = append(colValues, row[i])
-			}
+		if err != nil {
+			return req, fmt.Errorf("invalid filter: %v", err)
 		}
-		// Use our existing logic to guess
-		colTypes[i] = inferColumnType(colValues)
+		req.Filter = &filter
 	}
-	return colTypes
+	return req, nil
 }
 
-// buildSmartCreateTableSQL constructs the SQL with REAL types (INT, BOOL) instead of just TEXT
-func buildSmartCreateTableSQL(tableName string, headers []string, types []string) string {
-	var cols []string
-	for i, h := range headers {
-		cleanHeader := strings.ReplaceAll(h, " ", "_")
-		sqlType := types[i] // Use the guessed type
-		cols = append(cols, fmt.Sprintf("%s %s", cleanHeader, sqlType))
+// respondIdentError maps identifier lookup failures to HTTP statuses
+func respondIdentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, database.ErrUnknownTable), errors.Is(err, database.ErrUnknownColumn):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrInvalidIdent), errors.Is(err, database.ErrInvalidKey), errors.Is(err, database.ErrInvalidFilter),
+		errors.Is(err, database.ErrInvalidPage):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 	}
-	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s);", tableName, strings.Join(cols, ", "))
 }
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"db-viewer/jobs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleGetJob reports the progress of a background import
+// @Summary      Get Job
+// @Description  Returns the state, rows processed, bytes read and errors of a background import started by this session or workspace
+// @Tags         Jobs
+// @Produce      json
+// @Param        id path string true "Job ID"
+// @Success      200  {object}  models.JobStatus
+// @Failure      404  {object}  map[string]interface{}
+// @Router       /jobs/{id} [get]
+func HandleGetJob(c *gin.Context) {
+	job, err := importJobs.Get(getDB(c), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, job.Status())
+}
+
+// HandleCancelJob stops a running background import
+// @Summary      Cancel Job
+// @Description  Cancels a running background import started by this session or workspace; rows from batches already committed are kept
+// @Tags         Jobs
+// @Produce      json
+// @Param        id path string true "Job ID"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      404  {object}  map[string]interface{}
+// @Failure      409  {object}  map[string]interface{}
+// @Router       /jobs/{id}/cancel [post]
+func HandleCancelJob(c *gin.Context) {
+	err := importJobs.Cancel(getDB(c), c.Param("id"))
+	switch {
+	case errors.Is(err, jobs.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, jobs.ErrJobFinished):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Job cancellation requested"})
+}
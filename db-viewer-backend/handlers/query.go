@@ -0,0 +1,664 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"db-viewer/config"
+	"db-viewer/database"
+	"db-viewer/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// statusClientClosedRequest is the non-standard status reported for queries
+// canceled before they finished, as nginx does for dropped requests.
+const statusClientClosedRequest = 499
+
+const ndjsonMIME = "application/x-ndjson"
+
+// streamFlushRows is how many streamed rows are written between flushes
+const streamFlushRows = 100
+
+// Reasons a query failed, reported alongside the error message
+const (
+	reasonTimeout  = "timeout"
+	reasonCanceled = "canceled"
+	reasonSQLError = "sql_error"
+
+	reasonNotAllowed = "not_allowed"
+)
+
+// queryRoleHeader lets a client run its queries as a lesser role than the
+// server allows, e.g. to browse without risking a write
+const queryRoleHeader = "X-Query-Role"
+
+var (
+	queryTimeout    = 30 * time.Second
+	maxQueryTimeout = 5 * time.Minute
+	maxQueryRows    = 10000
+
+	queryIDRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+	errQueryCanceled = errors.New("query was canceled")
+
+	maxQueryRole  = config.RoleAdmin
+	queryPolicies = mustQueryPolicies(nil)
+)
+
+// Configure applies the settings of cfg that the handlers use
+func Configure(cfg config.Config) error {
+	policies, err := newQueryPolicies(cfg.QueryAllow)
+	if err != nil {
+		return err
+	}
+
+	queryTimeout = cfg.QueryTimeout
+	maxQueryTimeout = cfg.MaxQueryTimeout
+	maxQueryRows = cfg.MaxQueryRows
+	maxQueryRole = cfg.QueryRole
+	queryPolicies = policies
+	return nil
+}
+
+// newQueryPolicies builds the policy of every role, with the allowlists in
+// allow replacing the defaults
+func newQueryPolicies(allow map[string][]string) (map[string]*database.QueryPolicy, error) {
+	policies := make(map[string]*database.QueryPolicy, len(config.Roles))
+	for _, role := range config.Roles {
+		policy, err := database.NewQueryPolicy(role, allow[role])
+		if err != nil {
+			return nil, err
+		}
+		policies[role] = policy
+	}
+	return policies, nil
+}
+
+func mustQueryPolicies(allow map[string][]string) map[string]*database.QueryPolicy {
+	policies, err := newQueryPolicies(allow)
+	if err != nil {
+		panic(err)
+	}
+	return policies
+}
+
+// queryPolicy picks the policy a request runs under: the role it asks for
+// in the X-Query-Role header, which may not exceed DB_VIEWER_QUERY_ROLE, or
+// that role itself
+func queryPolicy(c *gin.Context) (*database.QueryPolicy, bool) {
+	role := strings.ToLower(c.GetHeader(queryRoleHeader))
+	if role == "" {
+		role = maxQueryRole
+	}
+
+	rank := slices.Index(config.Roles, role)
+	switch {
+	case rank < 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown query role %q; use one of %s", role, strings.Join(config.Roles, ", "))})
+		return nil, false
+	case rank > slices.Index(config.Roles, maxQueryRole):
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Queries may run as the %s role at most", maxQueryRole), "reason": reasonNotAllowed})
+		return nil, false
+	}
+	return queryPolicies[role], true
+}
+
+// checkStatements runs every statement of query past the policy, so a
+// rejected one stops the request before anything has run
+func checkStatements(c *gin.Context, policy *database.QueryPolicy, query, id string) bool {
+	for i, stmt := range database.SplitStatements(query) {
+		if _, err := policy.Check(stmt.SQL); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":     err.Error(),
+				"reason":    reasonNotAllowed,
+				"role":      policy.Role,
+				"query_id":  id,
+				"statement": models.StatementError{Index: i, Line: stmt.Line, Statement: stmt.SQL, Error: err.Error()},
+			})
+			return false
+		}
+	}
+	return true
+}
+
+// runningQueries tracks the queries in flight so they can be canceled. They
+// are keyed by database as well as ID, so a client can only cancel the
+// queries of its own session or workspace.
+var runningQueries = &queryRegistry{queries: make(map[queryKey]context.CancelCauseFunc)}
+
+type queryKey struct {
+	db *sql.DB
+	id string
+}
+
+type queryRegistry struct {
+	mu      sync.Mutex
+	queries map[queryKey]context.CancelCauseFunc
+}
+
+// start derives the context a query runs under: it ends when the request
+// does, when the timeout passes or when the query is canceled by ID. The
+// returned func must be called once the query is done.
+func (r *queryRegistry) start(parent context.Context, db *sql.DB, id string, timeout time.Duration) (context.Context, func(), bool) {
+	key := queryKey{db: db, id: id}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.queries[key]; ok {
+		return nil, nil, false
+	}
+
+	ctx, cancel := context.WithCancelCause(parent)
+	ctx, stop := context.WithTimeout(ctx, timeout)
+	r.queries[key] = cancel
+
+	return ctx, func() {
+		r.mu.Lock()
+		delete(r.queries, key)
+		r.mu.Unlock()
+		stop()
+		cancel(nil)
+	}, true
+}
+
+// cancel stops the query with the given ID, reporting whether it was running
+func (r *queryRegistry) cancel(db *sql.DB, id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cancel, ok := r.queries[queryKey{db: db, id: id}]
+	if ok {
+		cancel(errQueryCanceled)
+	}
+	return ok
+}
+
+// HandleQuery runs SQL
+// @Summary      Run SQL Query
+// @Description  Executes a raw SQL query against the in-memory database. The query is stopped when the request ends, when its timeout passes or when it is canceled through POST /query/{id}/cancel. At most DB_VIEWER_MAX_QUERY_ROWS rows (or max_rows) are returned, with truncated set when there were more. Rows are arrays ordered like columns, which carry each column's declared type; statements that return no rows report rows_affected. With stream set (or Accept: application/x-ndjson) the result is written as NDJSON while it is read: a {"query_id", "columns"} line, one array per row and a closing {"row_count", "truncated", "rows_affected", "elapsed_ms"} or {"error", "reason"} line. Without script the query must hold exactly one statement. With script set every statement runs, optionally in one transaction, and each gets its own result; the first failing statement stops the script and is reported with its index and line. params binds ? placeholders from an array or :name, @name and $name placeholders from an object; scripts only take named params. Every statement is checked against the policy of the role (the X-Query-Role header, at most DB_VIEWER_QUERY_ROLE) before any runs; a rejected one fails the request with 403 and reason "not_allowed".
+// @Tags         QueryExecuter
+// @Accept       json
+// @Produce      json
+// @Produce      application/x-ndjson
+// @Param        request body models.QueryRequest true "SQL Query"
+// @Param        X-Query-Role header string false "Role to run as: reader, editor or admin"
+// @Success      200  {object}  models.QueryResult
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      408  {object}  map[string]interface{}
+// @Failure      403  {object}  map[string]interface{}
+// @Failure      409  {object}  map[string]interface{}
+// @Failure      499  {object}  map[string]interface{}
+// @Router       /query [post]
+func HandleQuery(c *gin.Context) {
+	db := getDB(c)
+
+	var req models.QueryRequest
+
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	id := req.QueryID
+	if id == "" {
+		id = newQueryID()
+	} else if !queryIDRegex.MatchString(id) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query_id must be 1 to 64 letters, digits, '-' or '_'"})
+		return
+	}
+
+	timeout := queryTimeout
+	switch {
+	case req.TimeoutMs < 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "timeout_ms must not be negative"})
+		return
+	case req.TimeoutMs > 0:
+		timeout = min(time.Duration(req.TimeoutMs)*time.Millisecond, maxQueryTimeout)
+	}
+
+	stream := req.Stream || c.NegotiateFormat(gin.MIMEJSON, ndjsonMIME) == ndjsonMIME
+	if stream && req.Script {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Scripts cannot be streamed"})
+		return
+	}
+	if req.Transaction && !req.Script {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Transaction only applies to scripts"})
+		return
+	}
+
+	args, err := database.BindParams(req.Params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Every statement of a script numbers its ? placeholders from 1 again
+	if req.Script && !database.NamedParams(args) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Scripts only take named params"})
+		return
+	}
+	if req.MaxRows < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_rows must not be negative"})
+		return
+	}
+	// Streamed rows never pile up in memory, so only the caller limits them
+	limit := req.MaxRows
+	if !stream && (limit == 0 || limit > maxQueryRows) {
+		limit = maxQueryRows
+	}
+
+	// Without script only one statement runs, and it runs on its own: the
+	// driver would otherwise run whatever follows it too
+	stmt := req.Query
+	if !req.Script {
+		if stmt, err = database.SingleStatement(req.Query); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error() + "; set script to run several", "query_id": id})
+			return
+		}
+	}
+
+	policy, ok := queryPolicy(c)
+	if !ok || !checkStatements(c, policy, req.Query, id) {
+		return
+	}
+
+	ctx, done, ok := runningQueries.start(c.Request.Context(), db, id, timeout)
+	if !ok {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("query %q is already running", id), "query_id": id})
+		return
+	}
+	defer done()
+
+	// Statements run on a pinned connection so rows_affected reads the
+	// changes of this statement and not of one run on another connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		respondQueryError(c, ctx, id, timeout, err)
+		return
+	}
+	defer conn.Close()
+	// A statement that opens a transaction, like BEGIN or SAVEPOINT, or a
+	// script that stops between its own BEGIN and COMMIT, must not hand the
+	// connection back to the pool with the transaction still open
+	defer conn.ExecContext(context.WithoutCancel(ctx), "ROLLBACK")
+
+	// Statement kinds are told apart by their text; query_only makes SQLite
+	// itself refuse writes for roles that should not make any
+	if policy.ReadOnly() {
+		if _, err := conn.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+			respondQueryError(c, ctx, id, timeout, err)
+			return
+		}
+		defer conn.ExecContext(context.WithoutCancel(ctx), "PRAGMA query_only = OFF")
+	}
+
+	if req.Script {
+		runScript(c, ctx, conn, req.Query, args, req.Transaction, id, timeout, limit)
+		return
+	}
+	if stream {
+		streamQuery(c, ctx, conn, stmt, args, id, timeout, limit)
+		return
+	}
+
+	result, err := runStatement(ctx, conn, stmt, args, limit, nil, nil)
+	if err != nil {
+		respondQueryError(c, ctx, id, timeout, err)
+		return
+	}
+
+	result.QueryID = id
+	c.JSON(http.StatusOK, result)
+}
+
+// streamQuery writes the result as NDJSON while it is read. The status is
+// sent with the first line, so a query that fails halfway reports its error
+// on the last line instead.
+func streamQuery(c *gin.Context, ctx context.Context, q database.Queryer, query string, args []interface{}, id string, timeout time.Duration, limit int) {
+	enc := json.NewEncoder(c.Writer)
+	started := false
+
+	writeColumns := func(columns []models.QueryColumn) error {
+		c.Header("Content-Type", ndjsonMIME)
+		c.Status(http.StatusOK)
+		started = true
+
+		if err := enc.Encode(gin.H{"query_id": id, "columns": columns}); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	}
+
+	count := 0
+	writeRow := func(row []interface{}) error {
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+		count++
+		if count%streamFlushRows == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	}
+
+	result, err := runStatement(ctx, q, query, args, limit, writeColumns, writeRow)
+	switch {
+	case err != nil && !started:
+		respondQueryError(c, ctx, id, timeout, err)
+		return
+	case err != nil:
+		_, body := queryError(ctx, id, timeout, err)
+		enc.Encode(body)
+	default:
+		summary := gin.H{"row_count": result.RowCount, "truncated": result.Truncated, "elapsed_ms": result.ElapsedMs}
+		if result.RowsAffected != nil {
+			summary["rows_affected"] = *result.RowsAffected
+		}
+		enc.Encode(summary)
+	}
+	c.Writer.Flush()
+}
+
+// runScript runs the statements of a script in order and stops at the first
+// that fails. In a transaction the failure undoes the whole script;
+// otherwise the statements before it stay applied.
+func runScript(c *gin.Context, ctx context.Context, conn *sql.Conn, script string, args []interface{}, inTx bool, id string, timeout time.Duration, limit int) {
+	stmts := database.SplitStatements(script)
+	if len(stmts) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The script holds no statements", "query_id": id})
+		return
+	}
+
+	var q database.Queryer = conn
+	if inTx {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			respondQueryError(c, ctx, id, timeout, err)
+			return
+		}
+		defer tx.Rollback()
+		q = tx
+	}
+
+	start := time.Now()
+	results := []models.StatementResult{}
+	for i, stmt := range stmts {
+		entry := models.StatementResult{Index: i, Line: stmt.Line, SQL: stmt.SQL}
+		if inTx && database.IsTransactionControl(stmt.SQL) {
+			entry.Skipped = true
+			entry.Columns, entry.Rows = []models.QueryColumn{}, [][]interface{}{}
+			results = append(results, entry)
+			continue
+		}
+
+		result, err := runStatement(ctx, q, stmt.SQL, args, limit, nil, nil)
+		if err != nil {
+			status, body := queryError(ctx, id, timeout, err)
+			body["statement"] = models.StatementError{Index: i, Line: stmt.Line, Statement: stmt.SQL, Error: err.Error()}
+			body["statements"] = results
+			body["rolled_back"] = inTx
+			c.JSON(status, body)
+			return
+		}
+		entry.QueryResult = result
+		results = append(results, entry)
+	}
+
+	if tx, ok := q.(*sql.Tx); ok {
+		if err := tx.Commit(); err != nil {
+			respondQueryError(c, ctx, id, timeout, err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, models.ScriptResult{
+		QueryID:     id,
+		Transaction: inTx,
+		Statements:  results,
+		ElapsedMs:   float64(time.Since(start).Microseconds()) / 1000,
+	})
+}
+
+// runStatement runs one statement on q with args bound to its placeholders.
+// q must hold a single connection (a *sql.Conn or *sql.Tx) for
+// rows_affected to be right. Rows go to onRow when it is set and are
+// collected in the result otherwise; onColumns, if set, is told the columns
+// before the first row. A positive limit stops reading after that many rows,
+// marking the result truncated if more were left.
+func runStatement(ctx context.Context, q database.Queryer, query string, args []interface{}, limit int, onColumns func([]models.QueryColumn) error, onRow func([]interface{}) error) (models.QueryResult, error) {
+	var result models.QueryResult
+
+	var before int64
+	if err := q.QueryRowContext(ctx, "SELECT total_changes()").Scan(&before); err != nil {
+		return result, err
+	}
+
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return result, err
+	}
+	result.Columns = queryColumns(types)
+	if onColumns != nil {
+		if err := onColumns(result.Columns); err != nil {
+			return result, err
+		}
+	}
+
+	if onRow == nil {
+		result.Rows = [][]interface{}{}
+		onRow = func(row []interface{}) error {
+			result.Rows = append(result.Rows, row)
+			return nil
+		}
+	}
+	result.RowCount, result.Truncated, err = readRows(rows, len(result.Columns), limit, onRow)
+	if err != nil {
+		return result, err
+	}
+	if err := rows.Close(); err != nil {
+		return result, err
+	}
+
+	if len(result.Columns) == 0 {
+		affected, err := rowsAffected(ctx, q, before)
+		if err != nil {
+			return result, err
+		}
+		result.RowsAffected = &affected
+	}
+
+	result.ElapsedMs = float64(time.Since(start).Microseconds()) / 1000
+	return result, nil
+}
+
+// readRows scans rows into slices ordered like the columns and hands each to
+// emit, stopping after limit rows when it is positive. It returns how many
+// rows were read and whether more were left.
+func readRows(rows *sql.Rows, count, limit int, emit func([]interface{}) error) (int, bool, error) {
+	values := make([]interface{}, count)
+	valuePtrs := make([]interface{}, count)
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+
+	n := 0
+	for rows.Next() {
+		if limit > 0 && n == limit {
+			return n, true, nil
+		}
+
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return n, false, err
+		}
+
+		row := make([]interface{}, count)
+		for i, val := range values {
+			if b, ok := val.([]byte); ok {
+				row[i] = string(b)
+			} else {
+				row[i] = val
+			}
+		}
+		if err := emit(row); err != nil {
+			return n, false, err
+		}
+		n++
+	}
+	return n, false, rows.Err()
+}
+
+func queryColumns(types []*sql.ColumnType) []models.QueryColumn {
+	columns := make([]models.QueryColumn, len(types))
+	for i, t := range types {
+		columns[i] = models.QueryColumn{Name: t.Name(), Type: t.DatabaseTypeName()}
+		if nullable, ok := t.Nullable(); ok {
+			columns[i].Nullable = &nullable
+		}
+	}
+	return columns
+}
+
+// rowsAffected counts the rows changed by the statement just run on q.
+// changes() keeps its value across statements that change nothing, such as
+// DDL, so it only counts when total_changes() has moved on from before.
+func rowsAffected(ctx context.Context, q database.Queryer, before int64) (int64, error) {
+	var changes, total int64
+	if err := q.QueryRowContext(ctx, "SELECT changes(), total_changes()").Scan(&changes, &total); err != nil {
+		return 0, err
+	}
+	if total == before {
+		return 0, nil
+	}
+	return changes, nil
+}
+
+// HandleCancelQuery stops a running query
+// @Summary      Cancel Query
+// @Description  Cancels a query started with the same query_id by this session or workspace; the query's own request fails with reason "canceled"
+// @Tags         QueryExecuter
+// @Produce      json
+// @Param        id path string true "Query ID"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      404  {object}  map[string]interface{}
+// @Router       /query/{id}/cancel [post]
+func HandleCancelQuery(c *gin.Context) {
+	id := c.Param("id")
+	if !runningQueries.cancel(getDB(c), id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("query %q is not running", id)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Query cancellation requested", "query_id": id})
+}
+
+// HandleExplainQuery shows how SQLite would run a statement
+// @Summary      Explain Query
+// @Description  Runs EXPLAIN QUERY PLAN on a single statement, which is planned but not run, and returns the plan as a tree. Each node carries its op (scan, search, temp_btree, subquery, ...), the table and index it uses and the constraint an index is searched with. warnings points at the nodes likely to be slow: full table scans, automatic indexes, temporary B-trees for ORDER BY, GROUP BY or DISTINCT, and correlated subqueries. params binds placeholders as in POST /query. Only statements the role may run can be explained, and PRAGMA statements never can.
+// @Tags         QueryExecuter
+// @Accept       json
+// @Produce      json
+// @Param        request body models.ExplainRequest true "SQL Query"
+// @Param        X-Query-Role header string false "Role to run as: reader, editor or admin"
+// @Success      200  {object}  models.QueryPlan
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      403  {object}  map[string]interface{}
+// @Failure      408  {object}  map[string]interface{}
+// @Router       /query/explain [post]
+func HandleExplainQuery(c *gin.Context) {
+	db := getDB(c)
+
+	var req models.ExplainRequest
+
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	stmt, err := database.SingleStatement(req.Query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error() + "; only one can be explained"})
+		return
+	}
+
+	policy, ok := queryPolicy(c)
+	if !ok {
+		return
+	}
+	// The statement is only planned, but SQLite applies pragmas while
+	// planning, so it must be one the role may run and never a PRAGMA
+	kind, err := policy.Check(stmt)
+	if err == nil && (kind == database.KindPragma || kind == database.KindPragmaSet) {
+		err = fmt.Errorf("%w: PRAGMA statements cannot be explained", database.ErrNotAllowed)
+	}
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "reason": reasonNotAllowed, "role": policy.Role})
+		return
+	}
+
+	args, err := database.BindParams(req.Params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), queryTimeout)
+	defer cancel()
+
+	plan, err := database.ExplainQueryPlan(ctx, db, stmt, args...)
+	if err != nil {
+		respondQueryError(c, ctx, "", queryTimeout, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+func respondQueryError(c *gin.Context, ctx context.Context, id string, timeout time.Duration, err error) {
+	status, body := queryError(ctx, id, timeout, err)
+	c.JSON(status, body)
+}
+
+// queryError tells a query that ran out of time or was canceled apart from
+// one SQLite rejected. The driver reports both of the former as an
+// interrupt, so the context decides.
+func queryError(ctx context.Context, id string, timeout time.Duration, err error) (int, gin.H) {
+	status, body := http.StatusBadRequest, gin.H{"error": err.Error(), "reason": reasonSQLError}
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		status = http.StatusRequestTimeout
+		body = gin.H{"error": fmt.Sprintf("query exceeded the %s timeout", timeout), "reason": reasonTimeout}
+	case ctx.Err() != nil:
+		status = statusClientClosedRequest
+		body = gin.H{"error": errQueryCanceled.Error(), "reason": reasonCanceled}
+	}
+	if id != "" {
+		body["query_id"] = id
+	}
+	return status, body
+}
+
+func newQueryID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"db-viewer/database"
+	"db-viewer/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultMinConfidence hides the weakest suggestions unless asked for. It
+// is above what a source whose name says nothing about the target can
+// score, so links found from values alone only show up when asked for.
+const defaultMinConfidence = 0.7
+
+// HandleSuggestRelationships proposes foreign keys from overlapping values
+// @Summary      Suggest relationships
+// @Description  Compares the distinct values of columns across tables and proposes foreign keys to columns holding unique values, from type-compatible columns of other tables, with the share of source values found in the target (inclusion). The confidence weighs that overlap by the number of distinct source values and the share of the target they cover, and adds how well the column name matches the target table and whether the target is a primary key; a column whose name says nothing about the target scores at most 0.65. The values read and compared are capped; truncated is set when pairs had to be skipped. Rejected suggestions are left out unless include_rejected is set.
+// @Tags         Relationships
+// @Produce      json
+// @Param        min_confidence   query number false "Lowest confidence returned" default(0.7)
+// @Param        include_rejected query bool   false "Also return rejected suggestions"
+// @Success      200  {object}  map[string]interface{}
+// @Router       /relationships/suggest [get]
+func HandleSuggestRelationships(c *gin.Context) {
+	db := getDB(c)
+
+	minConfidence := defaultMinConfidence
+	if v := c.Query("min_confidence"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || f > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_confidence must be between 0 and 1"})
+			return
+		}
+		minConfidence = f
+	}
+	includeRejected, _ := strconv.ParseBool(c.Query("include_rejected"))
+
+	suggestions, truncated, err := database.SuggestRelationships(c.Request.Context(), db, minConfidence, includeRejected)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"suggestions": suggestions, "truncated": truncated})
+}
+
+// HandleAcceptRelationship accepts a suggested relationship
+// @Summary      Accept relationship
+// @Description  Records a suggested relationship as accepted, so it shows up in /db-info. It does not declare a foreign key; use /constraints/{tableName} for that.
+// @Tags         Relationships
+// @Accept       json
+// @Produce      json
+// @Param        request body models.RelationshipDecision true "Relationship"
+// @Success      200  {object}  map[string]interface{}
+// @Router       /relationships/suggest/accept [post]
+func HandleAcceptRelationship(c *gin.Context) {
+	decideRelationship(c, database.SuggestionAccepted)
+}
+
+// HandleRejectRelationship rejects a suggested relationship
+// @Summary      Reject relationship
+// @Description  Records a suggested relationship as rejected, so it is neither suggested again nor inferred from column names in /db-info
+// @Tags         Relationships
+// @Accept       json
+// @Produce      json
+// @Param        request body models.RelationshipDecision true "Relationship"
+// @Success      200  {object}  map[string]interface{}
+// @Router       /relationships/suggest/reject [post]
+func HandleRejectRelationship(c *gin.Context) {
+	decideRelationship(c, database.SuggestionRejected)
+}
+
+func decideRelationship(c *gin.Context, status string) {
+	db := getDB(c)
+
+	var req models.RelationshipDecision
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	var err error
+	if req.SourceTable, err = database.ResolveTable(db, req.SourceTable); err != nil {
+		respondIdentError(c, err)
+		return
+	}
+	if req.SourceColumn, err = database.ResolveColumn(db, req.SourceTable, req.SourceColumn); err != nil {
+		respondIdentError(c, err)
+		return
+	}
+	if req.TargetTable, err = database.ResolveTable(db, req.TargetTable); err != nil {
+		respondIdentError(c, err)
+		return
+	}
+	if req.TargetColumn, err = database.ResolveColumn(db, req.TargetTable, req.TargetColumn); err != nil {
+		respondIdentError(c, err)
+		return
+	}
+
+	if err := database.DecideRelationship(c.Request.Context(), db, req, status); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Relationship " + status, "relationship": req})
+}
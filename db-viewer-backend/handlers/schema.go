@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"db-viewer/database"
+	"db-viewer/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSampleRows caps the preview rows /schema returns per table
+const maxSampleRows = 100
+
+// HandleGetSchema describes the database without reading its rows
+// @Summary      Get schema
+// @Description  Returns every table with its columns, types, primary key, indexes and row count, plus the relationships between tables, without row data. sample adds up to that many leading rows of each table as a preview.
+// @Tags         Tables
+// @Produce      json
+// @Param        sample query int false "Preview rows per table (at most 100)" default(0)
+// @Success      200  {object}  map[string]interface{}
+// @Router       /schema [get]
+func HandleGetSchema(c *gin.Context) {
+	db := getDB(c)
+	ctx := c.Request.Context()
+
+	sample := 0
+	if v := c.Query("sample"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxSampleRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sample must be between 0 and 100"})
+			return
+		}
+		sample = n
+	}
+
+	tableNames, err := database.TableNames(ctx, db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	tables := make([]*models.TableSchema, 0, len(tableNames))
+	for _, name := range tableNames {
+		table, err := database.DescribeTable(ctx, db, name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if sample > 0 {
+			page, err := database.ReadPage(ctx, db, name, database.PageRequest{Limit: sample})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			table.Sample = page.Rows
+		}
+		tables = append(tables, table)
+	}
+
+	relationships, err := database.Relationships(ctx, db, tableNames)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tables":        tables,
+		"relationships": relationships,
+	})
+}
@@ -0,0 +1,561 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"db-viewer/database"
+	"db-viewer/importer"
+	"db-viewer/jobs"
+	"db-viewer/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// importJobs runs the uploads submitted with async=true
+var importJobs = jobs.NewManager(time.Hour)
+
+// uploadOptions are the import settings a client can pass as form fields
+type uploadOptions struct {
+	Import      importer.Options
+	CSV         importer.CSVOptions
+	SplitArrays bool
+}
+
+func parseUploadOptions(c *gin.Context) (uploadOptions, error) {
+	var opts uploadOptions
+
+	opts.Import.Mode = strings.ToLower(c.DefaultPostForm("mode", importer.ModeCreate))
+	if !importer.ValidMode(opts.Import.Mode) {
+		return opts, fmt.Errorf("invalid mode %q (expected create, replace, append or upsert)", opts.Import.Mode)
+	}
+	opts.Import.KeyColumn = strings.TrimSpace(c.PostForm("key_column"))
+	if opts.Import.Mode == importer.ModeUpsert && opts.Import.KeyColumn == "" {
+		return opts, errors.New("mode upsert needs a key_column")
+	}
+
+	if v := c.PostForm("schema"); v != "" {
+		var schema models.SchemaOverride
+		if err := json.Unmarshal([]byte(v), &schema); err != nil {
+			return opts, fmt.Errorf("invalid schema: %v", err)
+		}
+		opts.Import.Schema = &schema
+	}
+
+	if v := c.PostForm("constraints"); v != "" {
+		constraints, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid constraints %q", v)
+		}
+		opts.Import.Constraints = constraints
+	}
+
+	if v := c.PostForm("dry_run"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid dry_run %q", v)
+		}
+		opts.Import.DryRun = dryRun
+	}
+	if v := c.PostForm("preview_rows"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return opts, fmt.Errorf("invalid preview_rows %q", v)
+		}
+		opts.Import.PreviewRows = n
+	}
+
+	if v := c.PostForm("delimiter"); v != "" {
+		delimiter, err := parseDelimiter(v)
+		if err != nil {
+			return opts, err
+		}
+		opts.CSV.Delimiter = delimiter
+	}
+	opts.CSV.Encoding = strings.TrimSpace(c.PostForm("encoding"))
+	if v := c.PostForm("lazy_quotes"); v != "" {
+		lazy, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid lazy_quotes %q", v)
+		}
+		opts.CSV.LazyQuotes = lazy
+	}
+	opts.Import.Ragged = strings.ToLower(c.DefaultPostForm("ragged", importer.RaggedReject))
+	if !importer.ValidRagged(opts.Import.Ragged) {
+		return opts, fmt.Errorf("invalid ragged %q (expected reject, pad, truncate or fit)", opts.Import.Ragged)
+	}
+
+	if v := c.PostForm("split_arrays"); v != "" {
+		split, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid split_arrays %q", v)
+		}
+		opts.SplitArrays = split
+	}
+	return opts, nil
+}
+
+// parseDelimiter accepts a single character, or "tab" or `\t` for a tab
+func parseDelimiter(v string) (rune, error) {
+	switch strings.ToLower(v) {
+	case "tab", `\t`:
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(v)
+	if size != len(v) || r == utf8.RuneError || r == '"' || r == '\r' || r == '\n' {
+		return 0, fmt.Errorf("invalid delimiter %q", v)
+	}
+	return r, nil
+}
+
+// HandleFileUpload uploads a CSV, XLSX, JSON, SQLite or SQL file
+// @Summary      Upload CSV / XLSX / JSON / SQLite / SQL
+// @Description  Uploads a file and creates tables in SQLite: one per CSV file, one per XLSX sheet (named <file>_<sheet>), or one per JSON/NDJSON file with nested objects flattened into parent_child columns. SQLite databases (.sqlite/.db) are copied in table by table, and SQL scripts (.sql) run in one transaction with failed statements reported. Both are held to the policy of the query role (see POST /query): every statement of a script, and the CREATE statement of every object in a database, must pass it, and a database can only be uploaded by a role that may run CREATE and INSERT; otherwise the upload fails with 403. With split_arrays=true, arrays of objects in JSON become child tables linked by a <parent>_id column (parent_<parent>_id when the child objects have a field of that name); the parent objects must either all have an "id" or none, and a child object using the linking column fails the upload with 400. With async=true the import runs as a background job polled via /jobs/{id}.
+// @Description  schema is a JSON object that pins column types, renames or skips columns and sets the primary key, e.g. {"columns": {"zip": {"type": "VARCHAR"}, "Full Name": {"rename": "name"}, "tmp": {"skip": true}}, "primary_key": ["id"]}. It applies to every sheet of a workbook and to the root table of a JSON upload. With dry_run=true nothing is written: the response holds the inferred schema and the first preview_rows parsed rows of each table.
+// @Description  CSV files are sniffed for their delimiter (comma, semicolon, tab or pipe), encoding (UTF-8 with or without BOM, UTF-16 with BOM, otherwise Windows-1252) and stray quotes; delimiter, encoding and lazy_quotes override the detection. ragged decides what happens to rows with the wrong number of fields: reject (default), pad, truncate or fit (pad and truncate).
+// @Description  With constraints=true the tables the upload creates get a PRIMARY KEY on an "id" column holding unique values and FOREIGN KEY constraints from <name>_id columns to the primary key of the table <name> or <name>s; rows that break them are rejected. Foreign keys are enforced; SQLite databases and SQL scripts keep their own, and rows breaking them are listed under violations.
+// @Description  mode decides what happens to an existing table: create (default) fails with 409, replace swaps in the new data once it is loaded, append inserts into it and upsert updates the rows matching key_column and inserts the rest. For append and upsert the file's headers must match the table's columns (case-insensitively); a mismatch returns 400 with a "mapping" report.
+// @Tags         DataFileUpload
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        file         formData file true  "CSV, XLSX, JSON, NDJSON, SQLite or SQL File"
+// @Param        async        formData bool false "Run the import as a background job"
+// @Param        split_arrays formData bool false "Split arrays of JSON objects into child tables"
+// @Param        mode         formData string false "create, replace, append or upsert" default(create)
+// @Param        key_column   formData string false "Column matched on in upsert mode"
+// @Param        schema       formData string false "JSON schema override"
+// @Param        delimiter    formData string false "CSV delimiter, e.g. ; or tab"
+// @Param        encoding     formData string false "CSV encoding, e.g. utf-8 or windows-1252"
+// @Param        lazy_quotes  formData bool false "Accept stray quotes in CSV fields"
+// @Param        ragged       formData string false "reject, pad, truncate or fit" default(reject)
+// @Param        constraints  formData bool false "Declare the primary and foreign keys the column names suggest"
+// @Param        dry_run      formData bool false "Return the inferred schema and a preview without importing"
+// @Param        preview_rows formData int false "Rows returned by a dry run" default(20)
+// @Param        X-Query-Role header string false "Role SQL scripts and SQLite databases are checked against: reader, editor or admin"
+// @Success      200  {object}  map[string]interface{}
+// @Success      202  {object}  map[string]interface{}
+// @Failure      403  {object}  map[string]interface{}
+// @Router       /upload [post]
+func HandleFileUpload(c *gin.Context) {
+	db := getDB(c)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+
+	opts, err := parseUploadOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch strings.ToLower(filepath.Ext(fileHeader.Filename)) {
+	case ".sql":
+		if !checkSQLUpload(c, fileHeader) {
+			return
+		}
+	case ".sqlite", ".sqlite3", ".db":
+		if !checkSQLiteUpload(c, db, fileHeader) {
+			return
+		}
+	}
+
+	if async, _ := strconv.ParseBool(c.PostForm("async")); async && !opts.Import.DryRun {
+		startImportJob(c, db, fileHeader, opts)
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to open file"})
+		return
+	}
+	defer file.Close()
+
+	result, err := importFile(c.Request.Context(), db, fileHeader.Filename, file, opts)
+	if err != nil {
+		var inputErr *importer.InputError
+		var mappingErr *importer.MappingError
+		switch {
+		case errors.As(err, &inputErr):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case errors.As(err, &mappingErr):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "mapping": mappingErr, "result": result})
+			return
+		case errors.Is(err, importer.ErrTableExists):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "result": result})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import file: " + err.Error(), "result": result})
+		return
+	}
+
+	imported := 0
+	for _, table := range result.Tables {
+		if table.Error == "" {
+			imported++
+		}
+	}
+	response := gin.H{
+		"message": fmt.Sprintf("%d table(s) imported successfully", imported),
+		"tables":  result.Tables,
+		"skipped": result.Skipped,
+	}
+	if opts.Import.DryRun {
+		response["message"] = "Dry run: nothing was imported"
+		response["dry_run"] = true
+	}
+	if len(result.FailedStatements) > 0 {
+		response["failed_statements"] = result.FailedStatements
+	}
+	if len(result.Violations) > 0 {
+		response["violations"] = result.Violations
+	}
+	if len(result.Tables) == 1 {
+		response["tableName"] = result.Tables[0].Table
+		response["columns"] = result.Tables[0].Columns
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// checkSQLUpload runs every statement of an uploaded SQL script past the
+// policy of the request's query role, so a script cannot do what /query
+// would refuse
+func checkSQLUpload(c *gin.Context, fileHeader *multipart.FileHeader) bool {
+	policy, ok := queryPolicy(c)
+	if !ok {
+		return false
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to open file"})
+		return false
+	}
+	defer file.Close()
+
+	script, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to read file"})
+		return false
+	}
+
+	for i, stmt := range database.SplitStatements(string(script)) {
+		// The import runs the script in a transaction of its own and
+		// ignores the script's BEGIN and COMMIT
+		if database.IsTransactionControl(stmt.SQL) {
+			continue
+		}
+		if _, err := policy.Check(stmt.SQL); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":     err.Error(),
+				"reason":    reasonNotAllowed,
+				"role":      policy.Role,
+				"statement": models.StatementError{Index: i, Line: stmt.Line, Statement: stmt.SQL, Error: err.Error()},
+			})
+			return false
+		}
+	}
+	return true
+}
+
+// checkSQLiteUpload holds an uploaded database to the policy of the
+// request's query role: the import creates its objects and inserts their
+// rows, so the role must be allowed both, and every object's CREATE
+// statement must pass as it would through /query
+func checkSQLiteUpload(c *gin.Context, db *sql.DB, fileHeader *multipart.FileHeader) bool {
+	policy, ok := queryPolicy(c)
+	if !ok {
+		return false
+	}
+
+	for _, kind := range []string{database.KindCreate, database.KindInsert} {
+		if !policy.Allows(kind) {
+			err := fmt.Errorf("%w: SQLite database uploads need %s statements, which are not allowed for the %s role", database.ErrNotAllowed, strings.ToUpper(kind), policy.Role)
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "reason": reasonNotAllowed, "role": policy.Role})
+			return false
+		}
+	}
+
+	tmp, err := saveUpload(fileHeader)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to store upload: " + err.Error()})
+		return false
+	}
+	defer os.Remove(tmp.Name())
+	tmp.Close()
+
+	stmts, err := importer.SQLiteSchema(c.Request.Context(), db, tmp.Name())
+	if err != nil {
+		var inputErr *importer.InputError
+		if errors.As(err, &inputErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read database: " + err.Error()})
+		}
+		return false
+	}
+
+	for i, stmt := range stmts {
+		if _, err := policy.Check(stmt); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":     err.Error(),
+				"reason":    reasonNotAllowed,
+				"role":      policy.Role,
+				"statement": models.StatementError{Index: i, Statement: stmt, Error: err.Error()},
+			})
+			return false
+		}
+	}
+	return true
+}
+
+// startImportJob copies the upload to a temporary file, which outlives the
+// request unlike the multipart form, and imports it in the background.
+func startImportJob(c *gin.Context, db *sql.DB, fileHeader *multipart.FileHeader, opts uploadOptions) {
+	tmp, err := saveUpload(fileHeader)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to store upload: " + err.Error()})
+		return
+	}
+
+	release := extendLease(c)
+	job := importJobs.Start(db, fileHeader.Size, func(ctx context.Context, job *jobs.Job) (interface{}, error) {
+		defer release()
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+
+		opts.Import.OnProgress = job.ReportRows
+		result, err := importFile(ctx, db, fileHeader.Filename, job.Reader(tmp), opts)
+		if result == nil {
+			return nil, err
+		}
+		return result, err
+	})
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Import started",
+		"job_id":  job.ID(),
+		"file":    fileHeader.Filename,
+	})
+}
+
+// importFile parses an uploaded file, picking the format from its extension,
+// and imports it into one table per CSV file, worksheet or JSON (child) table.
+func importFile(ctx context.Context, db *sql.DB, fileName string, r io.Reader, opts uploadOptions) (*models.UploadResult, error) {
+	baseName, err := database.CleanIdent(strings.Split(fileName, ".")[0])
+	if err != nil {
+		return nil, &importer.InputError{Err: err}
+	}
+
+	result := &models.UploadResult{Tables: []models.ImportResult{}, Skipped: []models.SkippedSource{}}
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".xlsx", ".xlsm":
+		return result, importWorkbook(ctx, db, baseName, r, opts, result)
+	case ".json":
+		return result, importJSON(ctx, db, baseName, r, importer.JSONStream, opts, result)
+	case ".ndjson", ".jsonl":
+		return result, importJSON(ctx, db, baseName, r, importer.JSONLines, opts, result)
+	case ".sqlite", ".sqlite3", ".db":
+		if err := checkTabularOnly(opts, "SQLite databases"); err != nil {
+			return nil, err
+		}
+		return result, importSQLiteFile(ctx, db, r, result)
+	case ".sql":
+		if err := checkTabularOnly(opts, "SQL scripts"); err != nil {
+			return nil, err
+		}
+		script, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return result, importer.ImportSQLScript(ctx, db, string(script), result)
+	default:
+		source, err := importer.NewCSVSource(r, opts.CSV)
+		if err != nil {
+			return nil, err
+		}
+		table, err := importer.Import(ctx, db, baseName, source, opts.Import)
+		if table != nil {
+			result.Tables = append(result.Tables, *table)
+		}
+		return result, err
+	}
+}
+
+// checkTabularOnly rejects the options that only make sense for uploads
+// parsed into rows and columns
+func checkTabularOnly(opts uploadOptions, kind string) error {
+	switch {
+	case opts.Import.Mode != importer.ModeCreate:
+		return &importer.InputError{Err: fmt.Errorf("mode %s is not supported for %s", opts.Import.Mode, kind)}
+	case opts.Import.Schema != nil:
+		return &importer.InputError{Err: fmt.Errorf("schema overrides are not supported for %s", kind)}
+	case opts.Import.DryRun:
+		return &importer.InputError{Err: fmt.Errorf("dry runs are not supported for %s", kind)}
+	case opts.Import.Constraints:
+		return &importer.InputError{Err: fmt.Errorf("constraints are not guessed for %s; they keep their own", kind)}
+	}
+	return nil
+}
+
+// importWorkbook imports every sheet of an XLSX workbook into <file>_<sheet>.
+// Each sheet is imported on its own, so one that fails does not undo the
+// others: it is reported in result.Tables with its error when it wrote to
+// its table, and in result.Skipped otherwise. The upload only fails when no
+// sheet could be imported, or when it is canceled.
+func importWorkbook(ctx context.Context, db *sql.DB, baseName string, r io.Reader, opts uploadOptions, result *models.UploadResult) error {
+	workbook, err := importer.OpenWorkbook(r)
+	if err != nil {
+		return err
+	}
+	defer workbook.Close()
+
+	var firstErr error
+	imported := 0
+	for _, sheet := range workbook.SheetNames() {
+		tableName, err := database.CleanIdent(baseName + "_" + sheet)
+		if err != nil {
+			result.Skipped = append(result.Skipped, models.SkippedSource{Name: sheet, Reason: err.Error()})
+			continue
+		}
+
+		source, err := workbook.Sheet(sheet)
+		if err != nil {
+			result.Skipped = append(result.Skipped, models.SkippedSource{Name: sheet, Reason: err.Error()})
+			continue
+		}
+
+		table, err := importer.Import(ctx, db, tableName, source, opts.Import)
+		if table != nil {
+			table.Sheet = sheet
+		}
+		switch {
+		case err == nil:
+			imported++
+			result.Tables = append(result.Tables, *table)
+			continue
+		case ctx.Err() != nil:
+			if table != nil {
+				table.Error = err.Error()
+				result.Tables = append(result.Tables, *table)
+			}
+			return fmt.Errorf("sheet %q: %w", sheet, err)
+		case table != nil && opts.Import.Mode != importer.ModeReplace:
+			// A failed replace keeps the old table, so only the other modes
+			// leave rows behind
+			table.Error = err.Error()
+			result.Tables = append(result.Tables, *table)
+		default:
+			result.Skipped = append(result.Skipped, models.SkippedSource{Name: sheet, Reason: err.Error()})
+		}
+		if firstErr == nil {
+			firstErr = fmt.Errorf("sheet %q: %w", sheet, err)
+		}
+	}
+
+	if imported == 0 && firstErr != nil {
+		return firstErr
+	}
+	return nil
+}
+
+// importJSON imports JSON objects into tableName, then recursively imports
+// the child tables split off from arrays of objects
+func importJSON(ctx context.Context, db *sql.DB, tableName string, r io.Reader, layout string, opts uploadOptions, result *models.UploadResult) error {
+	source, err := importer.NewJSONSource(r, tableName, importer.JSONOptions{
+		Layout:      layout,
+		SplitArrays: opts.SplitArrays,
+		SampleSize:  opts.Import.SampleSize,
+	})
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	table, err := importer.Import(ctx, db, tableName, source, opts.Import)
+	if table != nil {
+		result.Tables = append(result.Tables, *table)
+	}
+	if err != nil || opts.Import.DryRun {
+		return err
+	}
+
+	// The schema override describes the root table only
+	opts.Import.Schema = nil
+	for _, child := range source.ChildTables() {
+		childName, err := database.CleanIdent(child.Name)
+		if err != nil {
+			result.Skipped = append(result.Skipped, models.SkippedSource{Name: child.Name, Reason: err.Error()})
+			continue
+		}
+
+		rows, err := child.Open()
+		if err != nil {
+			return err
+		}
+		if err := importJSON(ctx, db, childName, rows, importer.JSONLines, opts, result); err != nil {
+			return fmt.Errorf("child table %q: %w", childName, err)
+		}
+	}
+	return nil
+}
+
+// importSQLiteFile spools an uploaded database to disk so it can be ATTACHed
+func importSQLiteFile(ctx context.Context, db *sql.DB, r io.Reader, result *models.UploadResult) error {
+	tmp, err := os.CreateTemp("", "db-viewer-import-*.db")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, r)
+	tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return importer.ImportSQLite(ctx, db, tmp.Name(), result)
+}
+
+// saveUpload copies an uploaded file to a temporary file positioned at its start
+func saveUpload(fileHeader *multipart.FileHeader) (*os.File, error) {
+	src, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp("", "db-viewer-upload-*")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return tmp, nil
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"db-viewer/database"
+	"db-viewer/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleListWorkspaces lists the workspaces on disk
+// @Summary      List Workspaces
+// @Description  Lists every file-backed workspace
+// @Tags         Workspaces
+// @Produce      json
+// @Success      200  {array}   models.WorkspaceInfo
+// @Router       /workspaces [get]
+func HandleListWorkspaces(c *gin.Context) {
+	if !requireWorkspaces(c) {
+		return
+	}
+
+	workspaces, err := database.Workspaces.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, workspaces)
+}
+
+// HandleCreateWorkspace creates an empty workspace
+// @Summary      Create Workspace
+// @Description  Creates a new, empty SQLite file for a workspace
+// @Tags         Workspaces
+// @Accept       json
+// @Produce      json
+// @Param        request body models.CreateWorkspaceRequest true "Workspace"
+// @Success      201  {object}  map[string]interface{}
+// @Router       /workspaces [post]
+func HandleCreateWorkspace(c *gin.Context) {
+	if !requireWorkspaces(c) {
+		return
+	}
+
+	var req models.CreateWorkspaceRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if err := database.Workspaces.Create(req.Name); err != nil {
+		respondWorkspaceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Workspace created", "name": req.Name})
+}
+
+// HandleOpenWorkspace makes a workspace the default for this client
+// @Summary      Open Workspace
+// @Description  Opens a workspace and remembers it in a cookie for subsequent requests
+// @Tags         Workspaces
+// @Produce      json
+// @Param        name path string true "Workspace name"
+// @Success      200  {object}  map[string]interface{}
+// @Router       /workspaces/{name}/open [post]
+func HandleOpenWorkspace(c *gin.Context) {
+	if !requireWorkspaces(c) {
+		return
+	}
+
+	name := c.Param("name")
+	lease, err := database.Workspaces.Open(name)
+	if err != nil {
+		respondWorkspaceError(c, err)
+		return
+	}
+	lease.Release()
+
+	c.SetCookie(workspaceCookie, name, 0, "/", "", false, true)
+	c.JSON(http.StatusOK, gin.H{"message": "Workspace opened", "name": name})
+}
+
+// HandleRenameWorkspace renames a workspace
+// @Summary      Rename Workspace
+// @Description  Renames a workspace and its file on disk; fails with 409 while a request or import is running on it
+// @Tags         Workspaces
+// @Accept       json
+// @Produce      json
+// @Param        name    path string                        true "Workspace name"
+// @Param        request body models.RenameWorkspaceRequest true "New name"
+// @Success      200  {object}  map[string]interface{}
+// @Router       /workspaces/{name} [put]
+func HandleRenameWorkspace(c *gin.Context) {
+	if !requireWorkspaces(c) {
+		return
+	}
+
+	var req models.RenameWorkspaceRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	name := c.Param("name")
+	if name == database.DefaultWorkspace {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The default workspace cannot be renamed"})
+		return
+	}
+
+	if err := database.Workspaces.Rename(name, req.NewName); err != nil {
+		respondWorkspaceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Workspace renamed", "name": req.NewName})
+}
+
+// HandleDeleteWorkspace deletes a workspace
+// @Summary      Delete Workspace
+// @Description  Closes a workspace and removes its file from disk; fails with 409 while a request or import is running on it
+// @Tags         Workspaces
+// @Produce      json
+// @Param        name path string true "Workspace name"
+// @Success      200  {object}  map[string]interface{}
+// @Router       /workspaces/{name} [delete]
+func HandleDeleteWorkspace(c *gin.Context) {
+	if !requireWorkspaces(c) {
+		return
+	}
+
+	name := c.Param("name")
+	if name == database.DefaultWorkspace {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The default workspace cannot be deleted"})
+		return
+	}
+
+	if err := database.Workspaces.Delete(name); err != nil {
+		respondWorkspaceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Workspace deleted"})
+}
+
+func requireWorkspaces(c *gin.Context) bool {
+	if database.Workspaces == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Workspaces require DB_VIEWER_STORAGE=file"})
+		return false
+	}
+	return true
+}
+
+func respondWorkspaceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, database.ErrInvalidWorkspace):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrWorkspaceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrWorkspaceExists), errors.Is(err, database.ErrWorkspaceBusy):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,94 @@
+package importer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	"db-viewer/database"
+)
+
+// guessConstraints fills in the keys database.GuessKeys suggests for a
+// table the import creates, judging an "id" column by its sampled values.
+// A primary key set by the schema is kept.
+func (r *importRun) guessConstraints(ctx context.Context, db *sql.DB, table string, sample [][]string) error {
+	var unique func(string) (bool, error)
+	if len(r.primaryKey) == 0 {
+		unique = func(col string) (bool, error) {
+			i := slices.Index(r.result.Columns, col)
+			return uniqueValues(columnValues(sample, i)), nil
+		}
+	}
+
+	guess, err := database.GuessKeys(ctx, db, table, r.result.Columns, unique)
+	if err != nil {
+		return err
+	}
+	if len(guess.PrimaryKey) > 0 {
+		r.primaryKey = guess.PrimaryKey
+	}
+	for _, fk := range guess.ForeignKeys {
+		if fk.TargetColumn == "" {
+			r.result.Warnings = append(r.result.Warnings, fmt.Sprintf("column %q looks like a reference to %q, which has no single-column primary key to point at", fk.Column, fk.TargetTable))
+			continue
+		}
+		r.foreignKeys = append(r.foreignKeys, fk)
+	}
+
+	r.result.PrimaryKey = r.primaryKey
+	r.result.ForeignKeys = r.foreignKeys
+	return nil
+}
+
+// uniqueValues reports whether values are all non-empty and distinct
+func uniqueValues(values []string) bool {
+	seen := make(map[string]bool, len(values))
+	for _, v := range values {
+		if v == "" || seen[v] {
+			return false
+		}
+		seen[v] = true
+	}
+	return len(values) > 0
+}
+
+// foreignKeyReason names the reference a row breaks when its insert fails a
+// FOREIGN KEY constraint, which SQLite's error does not say
+func (r *importRun) foreignKeyReason(ctx context.Context, tx *sql.Tx, args []interface{}, err error) string {
+	if len(r.foreignKeys) == 0 || !strings.Contains(err.Error(), "FOREIGN KEY constraint failed") {
+		return err.Error()
+	}
+	for _, fk := range r.foreignKeys {
+		for i, col := range r.result.Columns {
+			if col != fk.Column || args[i] == nil {
+				continue
+			}
+			var found int
+			query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = ?", database.QuoteIdent(fk.TargetTable), database.QuoteIdent(fk.TargetColumn))
+			if tx.QueryRowContext(ctx, query, args[i]).Scan(&found) == nil && found == 0 {
+				return fmt.Sprintf("%s %v refers to a missing %s row", fk.Column, args[i], fk.TargetTable)
+			}
+		}
+	}
+	return err.Error()
+}
+
+// danglingReferences warns about rows of other tables that refer to rows a
+// replaced table no longer has
+func (r *importRun) danglingReferences(ctx context.Context, db *sql.DB, table string) error {
+	violations, err := database.CheckForeignKeys(ctx, db, "")
+	if err != nil {
+		return err
+	}
+	warned := make(map[string]bool)
+	for _, v := range violations {
+		if !strings.EqualFold(v.TargetTable, table) || warned[v.Table] {
+			continue
+		}
+		warned[v.Table] = true
+		r.result.Warnings = append(r.result.Warnings, fmt.Sprintf("rows of %q refer to rows %q no longer has", v.Table, table))
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"db-viewer/models"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// sniffSize is how much of a CSV upload is looked at to guess its dialect
+const sniffSize = 64 * 1024
+
+// sniffDelimiters are the delimiters tried, in order of preference on a tie
+var sniffDelimiters = []rune{',', ';', '\t', '|'}
+
+// CSVOptions overrides the dialect sniffed from a CSV upload. Zero values
+// leave the setting to detection.
+type CSVOptions struct {
+	Delimiter rune
+	// Encoding is a WHATWG encoding label such as "utf-8" or "windows-1252"
+	Encoding   string
+	LazyQuotes bool
+}
+
+type csvSource struct {
+	reader  *csv.Reader
+	header  []string
+	dialect models.CSVDialect
+}
+
+// NewCSVSource detects the encoding and delimiter of r, reads its header row
+// and returns a Source for the rest. Byte order marks are stripped; input
+// that is not valid UTF-8 is decoded as Windows-1252 unless opts names an
+// encoding.
+func NewCSVSource(r io.Reader, opts CSVOptions) (Source, error) {
+	decoded, encName, err := decodeCSV(r, opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReaderSize(decoded, sniffSize)
+	sample, _ := br.Peek(sniffSize)
+	sample = completeLines(sample)
+
+	dialect := models.CSVDialect{Encoding: encName, LazyQuotes: opts.LazyQuotes}
+	delimiter := opts.Delimiter
+	if delimiter == 0 {
+		delimiter = sniffDelimiter(sample)
+	}
+	dialect.Delimiter = string(delimiter)
+	if !dialect.LazyQuotes && hasBareQuotes(sample, delimiter) {
+		dialect.LazyQuotes = true
+	}
+
+	reader := csv.NewReader(br)
+	reader.Comma = delimiter
+	reader.LazyQuotes = dialect.LazyQuotes
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, &InputError{errors.New("CSV is empty")}
+	}
+	if err != nil {
+		return nil, &InputError{fmt.Errorf("failed to parse CSV header: %w", err)}
+	}
+
+	return &csvSource{reader: reader, header: header, dialect: dialect}, nil
+}
+
+func (s *csvSource) Header() []string {
+	return s.header
+}
+
+func (s *csvSource) Next() (Record, error) {
+	fields, err := s.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return Record{}, &RowError{Line: parseErr.StartLine, Reason: parseErr.Err.Error()}
+		}
+		return Record{}, err
+	}
+
+	line, _ := s.reader.FieldPos(0)
+	return Record{Line: line, Fields: fields}, nil
+}
+
+// Dialect reports the delimiter, encoding and quoting the file is read with
+func (s *csvSource) Dialect() models.CSVDialect {
+	return s.dialect
+}
+
+// decodeCSV wraps r in a decoder to UTF-8 for the named or detected encoding
+// and strips any byte order mark
+func decodeCSV(r io.Reader, name string) (io.Reader, string, error) {
+	br := bufio.NewReaderSize(r, sniffSize)
+	head, _ := br.Peek(sniffSize)
+
+	var enc encoding.Encoding
+	var encName string
+	switch {
+	case name != "":
+		named, err := htmlindex.Get(name)
+		if err != nil {
+			return nil, "", &InputError{fmt.Errorf("unsupported encoding %q", name)}
+		}
+		enc = named
+		if encName, err = htmlindex.Name(named); err != nil {
+			encName = name
+		}
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		enc, encName = unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM), "utf-16le"
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		enc, encName = unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM), "utf-16be"
+	case validUTF8Prefix(head):
+		enc, encName = unicode.UTF8, "utf-8"
+	default:
+		enc, encName = charmap.Windows1252, "windows-1252"
+	}
+
+	// UTF8BOM drops a leading byte order mark, which plain UTF8 keeps
+	if enc == unicode.UTF8 {
+		enc = unicode.UTF8BOM
+	}
+	return enc.NewDecoder().Reader(br), encName, nil
+}
+
+// validUTF8Prefix reports whether b is valid UTF-8, ignoring a multi-byte
+// character cut off at its end
+func validUTF8Prefix(b []byte) bool {
+	if utf8.Valid(b) {
+		return true
+	}
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			return !utf8.FullRune(b[i:]) && utf8.Valid(b[:i])
+		}
+	}
+	return false
+}
+
+// completeLines drops a trailing partial line from a sample
+func completeLines(sample []byte) []byte {
+	if i := bytes.LastIndexByte(sample, '\n'); i >= 0 {
+		return sample[:i+1]
+	}
+	return sample
+}
+
+// sniffDelimiter picks the candidate delimiter that splits the sampled lines
+// into the same number of fields most consistently. Delimiters inside double
+// quotes are not counted.
+func sniffDelimiter(sample []byte) rune {
+	lines := sampleLines(sample, 20)
+	if len(lines) == 0 {
+		return ','
+	}
+
+	best, bestScore := ',', 0
+	for _, delim := range sniffDelimiters {
+		first := countOutsideQuotes(lines[0], delim)
+		if first == 0 {
+			continue
+		}
+		// Lines whose count matches the header's, weighted by the count so
+		// a delimiter that splits into more columns wins a tie
+		consistent := 0
+		for _, line := range lines {
+			if countOutsideQuotes(line, delim) == first {
+				consistent++
+			}
+		}
+		if score := consistent*1000 + first; score > bestScore {
+			best, bestScore = delim, score
+		}
+	}
+	return best
+}
+
+// sampleLines returns up to n non-blank lines, joining lines that continue
+// a quoted field
+func sampleLines(sample []byte, n int) []string {
+	var lines []string
+	var current strings.Builder
+	inQuotes := false
+	for _, line := range strings.SplitAfter(string(sample), "\n") {
+		current.WriteString(line)
+		if strings.Count(line, `"`)%2 == 1 {
+			inQuotes = !inQuotes
+		}
+		if inQuotes {
+			continue
+		}
+		if text := strings.TrimRight(current.String(), "\r\n"); strings.TrimSpace(text) != "" {
+			lines = append(lines, text)
+		}
+		current.Reset()
+		if len(lines) == n {
+			break
+		}
+	}
+	return lines
+}
+
+func countOutsideQuotes(line string, delim rune) int {
+	count := 0
+	inQuotes := false
+	for _, ch := range line {
+		switch {
+		case ch == '"':
+			inQuotes = !inQuotes
+		case ch == delim && !inQuotes:
+			count++
+		}
+	}
+	return count
+}
+
+// hasBareQuotes reports whether the sample uses quotes the strict CSV parser
+// rejects, such as a quote in the middle of an unquoted field
+func hasBareQuotes(sample []byte, delim rune) bool {
+	reader := csv.NewReader(bytes.NewReader(sample))
+	reader.Comma = delim
+	reader.FieldsPerRecord = -1
+	for {
+		_, err := reader.Read()
+		if err == nil {
+			continue
+		}
+		var parseErr *csv.ParseError
+		return errors.As(err, &parseErr) && parseErr.Err == csv.ErrBareQuote
+	}
+}
@@ -0,0 +1,175 @@
+package importer
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"db-viewer/models"
+)
+
+func TestNewCSVSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    CSVOptions
+		dialect models.CSVDialect
+		header  []string
+		rows    [][]string
+	}{
+		{
+			name:    "comma",
+			input:   "id,name\n1,Ann\n2,Bob\n",
+			dialect: models.CSVDialect{Delimiter: ",", Encoding: "utf-8"},
+			header:  []string{"id", "name"},
+			rows:    [][]string{{"1", "Ann"}, {"2", "Bob"}},
+		},
+		{
+			name:    "semicolon with decimal commas",
+			input:   "name;price;qty\r\nA;1,5;2\r\nB;2,25;3\r\n",
+			dialect: models.CSVDialect{Delimiter: ";", Encoding: "utf-8"},
+			header:  []string{"name", "price", "qty"},
+			rows:    [][]string{{"A", "1,5", "2"}, {"B", "2,25", "3"}},
+		},
+		{
+			name:    "tab",
+			input:   "a\tb, c\td\n1\t2, 3\t4\n",
+			dialect: models.CSVDialect{Delimiter: "\t", Encoding: "utf-8"},
+			header:  []string{"a", "b, c", "d"},
+			rows:    [][]string{{"1", "2, 3", "4"}},
+		},
+		{
+			name:    "pipe",
+			input:   "a|b\n1|2\n",
+			dialect: models.CSVDialect{Delimiter: "|", Encoding: "utf-8"},
+			header:  []string{"a", "b"},
+			rows:    [][]string{{"1", "2"}},
+		},
+		{
+			name:    "semicolons inside quotes",
+			input:   "name,note\n\"Smith; John\",\"a;b;c\"\n\"Doe; Jane\",\"x;y;z\"\n",
+			dialect: models.CSVDialect{Delimiter: ",", Encoding: "utf-8"},
+			header:  []string{"name", "note"},
+			rows:    [][]string{{"Smith; John", "a;b;c"}, {"Doe; Jane", "x;y;z"}},
+		},
+		{
+			name:    "commas and a line break inside quotes",
+			input:   "\"x,y\";z\n\"1,2,3\";\"multi\nline, text\"\n\"4,5\";6\n",
+			dialect: models.CSVDialect{Delimiter: ";", Encoding: "utf-8"},
+			header:  []string{"x,y", "z"},
+			rows:    [][]string{{"1,2,3", "multi\nline, text"}, {"4,5", "6"}},
+		},
+		{
+			name:    "no header row",
+			input:   "1;2020-01-01;x\n2;2020-01-02;y\n",
+			dialect: models.CSVDialect{Delimiter: ";", Encoding: "utf-8"},
+			header:  []string{"1", "2020-01-01", "x"},
+			rows:    [][]string{{"2", "2020-01-02", "y"}},
+		},
+		{
+			name:    "single column without a header",
+			input:   "alpha\nbeta\n",
+			dialect: models.CSVDialect{Delimiter: ",", Encoding: "utf-8"},
+			header:  []string{"alpha"},
+			rows:    [][]string{{"beta"}},
+		},
+		{
+			name:    "UTF-8 byte order mark",
+			input:   "\xEF\xBB\xBFid,name\n1,José\n",
+			dialect: models.CSVDialect{Delimiter: ",", Encoding: "utf-8"},
+			header:  []string{"id", "name"},
+			rows:    [][]string{{"1", "José"}},
+		},
+		{
+			name:    "Windows-1252",
+			input:   "id;name\n1;caf\xe9 \x80\n",
+			dialect: models.CSVDialect{Delimiter: ";", Encoding: "windows-1252"},
+			header:  []string{"id", "name"},
+			rows:    [][]string{{"1", "café €"}},
+		},
+		{
+			name:    "UTF-16 with byte order mark",
+			input:   "\xFF\xFEa\x00\t\x00b\x00\n\x001\x00\t\x00\xe9\x00\n\x00",
+			dialect: models.CSVDialect{Delimiter: "\t", Encoding: "utf-16le"},
+			header:  []string{"a", "b"},
+			rows:    [][]string{{"1", "é"}},
+		},
+		{
+			name:    "stray quotes",
+			input:   "item,size\nTV,55\" screen\n",
+			dialect: models.CSVDialect{Delimiter: ",", Encoding: "utf-8", LazyQuotes: true},
+			header:  []string{"item", "size"},
+			rows:    [][]string{{"TV", "55\" screen"}},
+		},
+		{
+			name:    "overrides",
+			input:   "a;b,c\n1;2,3\n",
+			opts:    CSVOptions{Delimiter: ',', Encoding: "latin1"},
+			dialect: models.CSVDialect{Delimiter: ",", Encoding: "windows-1252"},
+			header:  []string{"a;b", "c"},
+			rows:    [][]string{{"1;2", "3"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := NewCSVSource(strings.NewReader(tt.input), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := src.(*csvSource).Dialect(); got != tt.dialect {
+				t.Errorf("dialect %+v, want %+v", got, tt.dialect)
+			}
+			if got := src.Header(); !reflect.DeepEqual(got, tt.header) {
+				t.Errorf("header %q, want %q", got, tt.header)
+			}
+			var rows [][]string
+			for {
+				rec, err := src.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				rows = append(rows, rec.Fields)
+			}
+			if !reflect.DeepEqual(rows, tt.rows) {
+				t.Errorf("rows %q, want %q", rows, tt.rows)
+			}
+		})
+	}
+}
+
+func TestNewCSVSourceRejected(t *testing.T) {
+	var inputErr *InputError
+	for _, tt := range []struct {
+		input string
+		opts  CSVOptions
+	}{
+		{input: ""},
+		{input: "\xEF\xBB\xBF"},
+		{input: "a,b\n", opts: CSVOptions{Encoding: "klingon"}},
+		{input: "\"a,b\n1,2\n"},
+	} {
+		if _, err := NewCSVSource(strings.NewReader(tt.input), tt.opts); !errors.As(err, &inputErr) {
+			t.Errorf("NewCSVSource(%q) = %v, want an InputError", tt.input, err)
+		}
+	}
+}
+
+func TestCSVSourceRowErrors(t *testing.T) {
+	src, err := NewCSVSource(strings.NewReader("a,b\n1,2\n3,\"4\n"), CSVOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec, err := src.Next(); err != nil || rec.Line != 2 {
+		t.Fatalf("first row %+v, %v", rec, err)
+	}
+	var rowErr *RowError
+	if _, err := src.Next(); !errors.As(err, &rowErr) || rowErr.Line != 3 {
+		t.Errorf("unterminated quote = %v, want a RowError on line 3", err)
+	}
+}
@@ -0,0 +1,580 @@
+package importer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"db-viewer/database"
+	"db-viewer/models"
+)
+
+const (
+	// DefaultSampleSize is how many leading rows are used to infer column types
+	DefaultSampleSize = 1000
+	// DefaultBatchSize is how many rows are inserted per transaction
+	DefaultBatchSize = 5000
+	// DefaultPreviewRows is how many parsed rows a dry run returns
+	DefaultPreviewRows = 20
+	// maxRejectedReported caps the rejected lines listed in a result; the count stays exact
+	maxRejectedReported = 100
+)
+
+// Options tunes a single import
+type Options struct {
+	SampleSize int
+	BatchSize  int
+	// Mode is one of ModeCreate (the default), ModeReplace, ModeAppend or ModeUpsert
+	Mode string
+	// KeyColumn is the column matched on in ModeUpsert
+	KeyColumn string
+	// Schema, when set, skips, renames and pins the types of columns and
+	// sets the primary key of the table
+	Schema *models.SchemaOverride
+	// Ragged is what to do with rows that have more or fewer fields than the
+	// header: RaggedReject (the default), RaggedPad, RaggedTruncate or RaggedFit
+	Ragged string
+	// DryRun infers the schema and parses the first PreviewRows rows without
+	// touching the database
+	DryRun      bool
+	PreviewRows int
+	// Constraints declares the primary and foreign keys the column names
+	// suggest on the tables the import creates (see guessConstraints)
+	Constraints bool
+	// OnProgress, when set, is called after every committed batch with the
+	// number of rows inserted and rejected since the previous call.
+	OnProgress func(inserted, rejected int64)
+}
+
+func (o Options) withDefaults() Options {
+	if o.SampleSize <= 0 {
+		o.SampleSize = DefaultSampleSize
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = DefaultBatchSize
+	}
+	if o.Mode == "" {
+		o.Mode = ModeCreate
+	}
+	if o.Ragged == "" {
+		o.Ragged = RaggedReject
+	}
+	if o.PreviewRows <= 0 {
+		o.PreviewRows = DefaultPreviewRows
+	}
+	if o.PreviewRows > o.SampleSize {
+		o.PreviewRows = o.SampleSize
+	}
+	return o
+}
+
+// Import loads the header and records of src into table. Depending on
+// opts.Mode it creates the table (with column types inferred from the first
+// SampleSize rows), replaces it, or appends/upserts into the existing one
+// after mapping the header onto its columns. Rows are written in
+// transactions of BatchSize as they are read, so the file is never held in
+// memory as a whole.
+func Import(ctx context.Context, db *sql.DB, table string, src Source, opts Options) (*models.ImportResult, error) {
+	opts = opts.withDefaults()
+	if !ValidMode(opts.Mode) {
+		return nil, &InputError{fmt.Errorf("unknown import mode %q", opts.Mode)}
+	}
+	if !ValidRagged(opts.Ragged) {
+		return nil, &InputError{fmt.Errorf("unknown ragged row policy %q", opts.Ragged)}
+	}
+
+	headers := database.CleanColumnNames(src.Header())
+	plan, err := planColumns(headers, opts.Schema)
+	if err != nil {
+		return nil, err
+	}
+	if plan.pinsSchema() && opts.Mode != ModeCreate && opts.Mode != ModeReplace {
+		return nil, &InputError{fmt.Errorf("schema types and primary keys only apply in create and replace mode")}
+	}
+	if opts.Constraints && opts.Mode != ModeCreate && opts.Mode != ModeReplace {
+		return nil, &InputError{fmt.Errorf("constraints only apply in create and replace mode")}
+	}
+
+	run := &importRun{
+		src:        src,
+		width:      len(headers),
+		plan:       plan,
+		primaryKey: plan.primaryKey,
+		ragged:     opts.Ragged,
+		onProgress: opts.OnProgress,
+		result: &models.ImportResult{
+			Table:      table,
+			Mode:       opts.Mode,
+			Columns:    plan.names,
+			PrimaryKey: plan.primaryKey,
+			Rejected:   []models.RejectedRow{},
+		},
+	}
+	if d, ok := src.(interface{ Dialect() models.CSVDialect }); ok {
+		dialect := d.Dialect()
+		run.result.Dialect = &dialect
+	}
+
+	// 1. Check the target table against the mode
+	existing, err := existingTable(db, table)
+	if err != nil {
+		return nil, err
+	}
+	target := table
+	switch opts.Mode {
+	case ModeCreate:
+		if existing != "" {
+			return nil, fmt.Errorf("%w: %q (use mode replace, append or upsert)", ErrTableExists, existing)
+		}
+	case ModeReplace:
+		// Load into a scratch table and swap it in at the end, so a failed
+		// or canceled import leaves the old table untouched
+		target = database.ScratchTable("replacing")
+	case ModeAppend, ModeUpsert:
+		if existing == "" {
+			return nil, &MappingError{Table: table, UnknownColumns: []string{}, MissingColumns: []string{}, Reason: "table does not exist"}
+		}
+		columns, types, err := mapColumns(ctx, db, existing, plan.names)
+		if err != nil {
+			return nil, err
+		}
+		target = existing
+		run.result.Table = existing
+		run.result.Columns = columns
+		run.types = types
+		if opts.Mode == ModeUpsert {
+			key, err := keyIndex(existing, columns, opts.KeyColumn)
+			if err != nil {
+				return nil, err
+			}
+			run.key = key
+			run.updateSQL = buildUpdateSQL(existing, columns, key)
+		}
+	}
+
+	// 2. Sample the leading rows and infer types from them
+	var sampled []Record
+	var sample [][]string
+	for len(sampled) < opts.SampleSize {
+		rec, err := run.read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		sampled = append(sampled, rec)
+		sample = append(sample, rec.Fields)
+	}
+	run.pending = sampled
+
+	// 3. Settle the column types: inferred (or pinned) for a new table,
+	// declared by an existing one
+	run.table = target
+	if opts.Mode == ModeCreate || opts.Mode == ModeReplace {
+		run.owned = true
+		run.types = guessColumnTypes(plan.names, sample)
+		for i, pinned := range plan.pinned {
+			if pinned != "" {
+				run.types[i] = declaredColumnType(pinned, run.types[i].NotNull, columnValues(sample, i))
+			}
+		}
+		if opts.Constraints {
+			if err := run.guessConstraints(ctx, db, table, sample); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		for i, t := range run.types {
+			run.types[i] = declaredColumnType(t.SQL, t.NotNull, columnValues(sample, i))
+		}
+	}
+	run.describe()
+
+	if opts.DryRun {
+		run.preview(sampled, opts.PreviewRows)
+		run.warn()
+		return run.result, nil
+	}
+
+	// 4. Create the table, unless loading into an existing one
+	if run.owned {
+		if opts.Mode == ModeReplace {
+			defer db.ExecContext(context.Background(), "DROP TABLE IF EXISTS "+database.QuoteIdent(target))
+		}
+		createTableSQL := buildSmartCreateTableSQL(target, plan.names, run.types, run.primaryKey, run.foreignKeys)
+		if _, err := db.ExecContext(ctx, createTableSQL); err != nil {
+			return nil, fmt.Errorf("failed to create table: %w", err)
+		}
+	}
+
+	// 5. Stream the rows in batches
+	run.insertSQL = buildInsertSQL(target, run.result.Columns)
+	batch := make([]Record, 0, opts.BatchSize)
+	for {
+		rec, err := run.read()
+		if err != nil && err != io.EOF {
+			return run.result, err
+		}
+		if err == nil {
+			batch = append(batch, rec)
+		}
+
+		if len(batch) == opts.BatchSize || (err == io.EOF && len(batch) > 0) {
+			if err := run.writeBatch(ctx, db, batch); err != nil {
+				return run.result, err
+			}
+			batch = batch[:0]
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if opts.Mode == ModeReplace {
+		if err := swapTable(ctx, db, target, existing, table); err != nil {
+			return run.result, err
+		}
+		if existing != "" {
+			if err := run.danglingReferences(ctx, db, table); err != nil {
+				return run.result, err
+			}
+		}
+	}
+	run.report(0)
+	run.warn()
+	return run.result, nil
+}
+
+// swapTable drops the table being replaced, if any, and renames the freshly
+// loaded scratch table into its place in one transaction. Foreign keys are
+// off meanwhile, so tables referencing the old one keep referencing the new.
+func swapTable(ctx context.Context, db *sql.DB, scratch, existing, table string) error {
+	return database.WithoutForeignKeys(ctx, db, func(conn *sql.Conn) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if existing != "" {
+			if _, err := tx.ExecContext(ctx, "DROP TABLE "+database.QuoteIdent(existing)); err != nil {
+				return fmt.Errorf("failed to drop %q: %w", existing, err)
+			}
+		}
+		if err := database.RenameTable(ctx, tx, scratch, table); err != nil {
+			return fmt.Errorf("failed to replace %q: %w", table, err)
+		}
+		return tx.Commit()
+	})
+}
+
+// importRun carries the state of one Import call
+type importRun struct {
+	src     Source
+	width   int
+	plan    *columnPlan
+	ragged  string
+	pending []Record
+	result  *models.ImportResult
+
+	// table is the table written to, and types its columns in header order.
+	// owned is set when the import created the table.
+	table string
+	types []columnType
+	owned bool
+
+	// primaryKey and foreignKeys are the constraints of an owned table
+	primaryKey  []string
+	foreignKeys []models.ForeignKey
+
+	insertSQL string
+	// updateSQL and key are set in upsert mode; key indexes the key column
+	updateSQL string
+	key       int
+
+	// padded and truncated count the ragged rows that were fitted to the header
+	padded    int64
+	truncated int64
+
+	onProgress       func(inserted, rejected int64)
+	reportedRejected int64
+}
+
+// read returns the next well-formed record, replaying sampled records first
+// and rejecting the ones that cannot be inserted as-is.
+func (r *importRun) read() (Record, error) {
+	for {
+		if len(r.pending) > 0 {
+			rec := r.pending[0]
+			r.pending = r.pending[1:]
+			return rec, nil
+		}
+
+		rec, err := r.src.Next()
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			r.reject(rowErr.Line, rowErr.Reason)
+			continue
+		}
+		if err != nil {
+			return Record{}, err
+		}
+
+		if len(rec.Fields) != r.width {
+			fields, ok := r.fit(rec.Fields)
+			if !ok {
+				r.reject(rec.Line, fmt.Sprintf("expected %d fields, got %d", r.width, len(rec.Fields)))
+				continue
+			}
+			rec.Fields = fields
+		}
+		rec.Fields = r.plan.project(rec.Fields)
+		return rec, nil
+	}
+}
+
+// fit pads or truncates a ragged row to the header width, as far as the
+// ragged row policy allows
+func (r *importRun) fit(fields []string) ([]string, bool) {
+	switch {
+	case len(fields) < r.width && (r.ragged == RaggedPad || r.ragged == RaggedFit):
+		r.padded++
+		return append(fields, make([]string, r.width-len(fields))...), true
+	case len(fields) > r.width && (r.ragged == RaggedTruncate || r.ragged == RaggedFit):
+		r.truncated++
+		return fields[:r.width], true
+	}
+	return nil, false
+}
+
+// warn adds the warnings of the source and of fitted ragged rows to the result
+func (r *importRun) warn() {
+	var warnings []string
+	if w, ok := r.src.(interface{ Warnings() []string }); ok {
+		warnings = append(warnings, w.Warnings()...)
+	}
+	if r.padded > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d row(s) had fewer fields than the header and were padded with empty values", r.padded))
+	}
+	if r.truncated > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d row(s) had more fields than the header; the extra fields were dropped", r.truncated))
+	}
+	r.result.Warnings = append(warnings, r.result.Warnings...)
+}
+
+func (r *importRun) reject(line int, reason string) {
+	r.result.RowsRejected++
+	if len(r.result.Rejected) < maxRejectedReported {
+		r.result.Rejected = append(r.result.Rejected, models.RejectedRow{Line: line, Reason: reason})
+	}
+}
+
+// report forwards progress since the previous call to the OnProgress callback
+func (r *importRun) report(inserted int64) {
+	if r.onProgress == nil {
+		return
+	}
+	rejected := r.result.RowsRejected - r.reportedRejected
+	if inserted == 0 && rejected == 0 {
+		return
+	}
+	r.onProgress(inserted, rejected)
+	r.reportedRejected = r.result.RowsRejected
+}
+
+// writeBatch writes records in one transaction, inserting them or, in upsert
+// mode, updating the row with the same key first. A record SQLite refuses is
+// rejected on its own without failing the rest of the batch.
+func (r *importRun) writeBatch(ctx context.Context, db *sql.DB, batch []Record) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if r.owned {
+		if err := r.relaxNotNull(ctx, tx, batch); err != nil {
+			return err
+		}
+	}
+
+	insert, err := tx.PrepareContext(ctx, r.insertSQL)
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+
+	var update *sql.Stmt
+	if r.updateSQL != "" {
+		if update, err = tx.PrepareContext(ctx, r.updateSQL); err != nil {
+			return err
+		}
+		defer update.Close()
+	}
+
+	var inserted, updated int64
+	args := make([]interface{}, len(r.types))
+	for _, rec := range batch {
+		for i, v := range rec.Fields {
+			args[i] = r.types[i].convert(v)
+		}
+
+		if update != nil {
+			matched, err := r.update(ctx, update, args)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				r.reject(rec.Line, err.Error())
+				continue
+			}
+			if matched {
+				updated++
+				continue
+			}
+		}
+
+		if _, err := insert.ExecContext(ctx, args...); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			r.reject(rec.Line, r.foreignKeyReason(ctx, tx, args, err))
+			continue
+		}
+		inserted++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	r.result.RowsInserted += inserted
+	r.result.RowsUpdated += updated
+	r.report(inserted + updated)
+	return nil
+}
+
+// update applies a row's values to the rows matching its key and reports
+// whether any did
+func (r *importRun) update(ctx context.Context, stmt *sql.Stmt, values []interface{}) (bool, error) {
+	args := make([]interface{}, 0, len(values))
+	for i, v := range values {
+		if i != r.key {
+			args = append(args, v)
+		}
+	}
+	args = append(args, values[r.key])
+
+	res, err := stmt.ExecContext(ctx, args...)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// relaxNotNull drops NOT NULL from the columns that turn out to hold empty
+// values past the sampled rows. SQLite cannot change a column's constraints
+// in place, so the table is rebuilt within the batch's transaction.
+func (r *importRun) relaxNotNull(ctx context.Context, tx *sql.Tx, batch []Record) error {
+	var relaxed []string
+	for i, t := range r.types {
+		if !t.NotNull {
+			continue
+		}
+		for _, rec := range batch {
+			if rec.Fields[i] == "" {
+				r.types[i].NotNull = false
+				relaxed = append(relaxed, r.result.Columns[i])
+				break
+			}
+		}
+	}
+	if len(relaxed) == 0 {
+		return nil
+	}
+
+	scratch := database.ScratchTable("rebuilding")
+	stmts := []string{
+		buildSmartCreateTableSQL(scratch, r.result.Columns, r.types, r.primaryKey, r.foreignKeys),
+		fmt.Sprintf("INSERT INTO %s SELECT * FROM %s", database.QuoteIdent(scratch), database.QuoteIdent(r.table)),
+		"DROP TABLE " + database.QuoteIdent(r.table),
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to drop NOT NULL from %s: %w", strings.Join(relaxed, ", "), err)
+		}
+	}
+	if err := database.RenameTable(ctx, tx, scratch, r.table); err != nil {
+		return fmt.Errorf("failed to drop NOT NULL from %s: %w", strings.Join(relaxed, ", "), err)
+	}
+
+	for _, col := range relaxed {
+		r.result.Warnings = append(r.result.Warnings, fmt.Sprintf("column %q has empty values past the sampled rows, so it is no longer NOT NULL", col))
+	}
+	r.describe()
+	return nil
+}
+
+// preview puts the first n sampled rows, converted as they would be stored,
+// into the result of a dry run
+func (r *importRun) preview(sampled []Record, n int) {
+	r.result.Preview = [][]interface{}{}
+	for _, rec := range sampled {
+		if len(r.result.Preview) == n {
+			break
+		}
+		row := make([]interface{}, len(rec.Fields))
+		for i, v := range rec.Fields {
+			row[i] = r.types[i].convert(v)
+		}
+		r.result.Preview = append(r.result.Preview, row)
+	}
+}
+
+// describe copies the column types into the result
+func (r *importRun) describe() {
+	r.result.Types = make([]string, len(r.types))
+	r.result.Schema = make([]models.ColumnInfo, len(r.types))
+	for i, t := range r.types {
+		r.result.Types[i] = t.SQL
+		r.result.Schema[i] = models.ColumnInfo{Name: r.result.Columns[i], Type: t.SQL, NotNull: t.NotNull}
+	}
+}
+
+// buildSmartCreateTableSQL constructs the SQL with REAL types (INT, BOOL) instead of just TEXT.
+// Headers are expected to have been through database.CleanColumnNames already.
+func buildSmartCreateTableSQL(tableName string, headers []string, types []columnType, primaryKey []string, foreignKeys []models.ForeignKey) string {
+	var cols []string
+	for i, h := range headers {
+		col := fmt.Sprintf("%s %s", database.QuoteIdent(h), types[i].SQL) // Use the guessed type
+		if types[i].NotNull {
+			col += " NOT NULL"
+		}
+		cols = append(cols, col)
+	}
+	if len(primaryKey) > 0 {
+		keys := make([]string, len(primaryKey))
+		for i, k := range primaryKey {
+			keys[i] = database.QuoteIdent(k)
+		}
+		cols = append(cols, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(keys, ", ")))
+	}
+	for _, fk := range foreignKeys {
+		cols = append(cols, fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s (%s)", database.QuoteIdent(fk.Column), database.QuoteIdent(fk.TargetTable), database.QuoteIdent(fk.TargetColumn)))
+	}
+	return fmt.Sprintf("CREATE TABLE %s (%s);", database.QuoteIdent(tableName), strings.Join(cols, ", "))
+}
+
+func buildInsertSQL(tableName string, headers []string) string {
+	cols := make([]string, len(headers))
+	placeholders := make([]string, len(headers))
+	for i, h := range headers {
+		cols[i] = database.QuoteIdent(h)
+		placeholders[i] = "?"
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", database.QuoteIdent(tableName), strings.Join(cols, ", "), strings.Join(placeholders, ","))
+}
@@ -0,0 +1,55 @@
+package importer
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// TestImportRelaxNotNull has a column that only turns out to hold empty
+// values after the sample, in a table a view already reads from: the rebuild
+// must drop NOT NULL without tripping over the view
+func TestImportRelaxNotNull(t *testing.T) {
+	db := openTestDB(t, ":memory:")
+	src, err := NewCSVSource(strings.NewReader("id,name\n1,a\n2,b\n3,c\n4,\n"), CSVOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var viewErr error
+	opts := Options{
+		SampleSize: 2,
+		BatchSize:  2,
+		OnProgress: func(inserted, rejected int64) {
+			if viewErr == nil {
+				_, viewErr = db.Exec("CREATE VIEW IF NOT EXISTS v AS SELECT name FROM t")
+			}
+		},
+	}
+	result, err := Import(context.Background(), db, "t", src, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if viewErr != nil {
+		t.Fatal(viewErr)
+	}
+
+	if result.RowsInserted != 4 || result.RowsRejected != 0 {
+		t.Errorf("inserted %d, rejected %d; want 4 and 0", result.RowsInserted, result.RowsRejected)
+	}
+	if len(result.Warnings) != 1 {
+		t.Errorf("warnings %q, want one about name", result.Warnings)
+	}
+
+	var notNull bool
+	if err := db.QueryRow(`SELECT "notnull" FROM pragma_table_info('t') WHERE name = 'name'`).Scan(&notNull); err != nil {
+		t.Fatal(err)
+	}
+	if notNull {
+		t.Error("name is still NOT NULL")
+	}
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM v").Scan(&n); err != nil || n != 4 {
+		t.Errorf("the view reads %d rows, %v; want 4", n, err)
+	}
+}
@@ -0,0 +1,225 @@
+package importer
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	intRegex   = regexp.MustCompile(`^-?\d+$`)
+	floatRegex = regexp.MustCompile(`^-?\d*\.\d+$`)
+)
+
+// dateLayouts are the unambiguous date formats recognised
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006/01/02",
+	"2.1.2006",
+	"2 Jan 2006",
+	"Jan 2, 2006",
+	"January 2, 2006",
+	"02-Jan-2006",
+}
+
+// datetimeLayouts are the unambiguous timestamp formats recognised.
+// Fractional seconds are accepted after the seconds field of any of them.
+var datetimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2.1.2006 15:04:05",
+	"2.1.2006 15:04",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// Slashed dates are read month-first unless a value of the column only
+// makes sense day-first (e.g. 13/05/2024).
+var (
+	monthFirstLayouts = []string{"1/2/2006 15:04:05", "1/2/2006 15:04", "1/2/2006"}
+	dayFirstLayouts   = []string{"2/1/2006 15:04:05", "2/1/2006 15:04", "2/1/2006"}
+)
+
+// layoutCandidates lists the sets of layouts a DATE or DATETIME column may
+// use, in order of preference. DATETIME columns accept bare dates too.
+func layoutCandidates(datetime bool) [][]string {
+	var base []string
+	if datetime {
+		base = append(base, datetimeLayouts...)
+	}
+	base = append(base, dateLayouts...)
+
+	var candidates [][]string
+	for _, slashed := range [][]string{monthFirstLayouts, dayFirstLayouts} {
+		set := append([]string(nil), base...)
+		for _, layout := range slashed {
+			if datetime || !strings.Contains(layout, ":") {
+				set = append(set, layout)
+			}
+		}
+		candidates = append(candidates, set)
+	}
+	return candidates
+}
+
+// columnType is the type of a column and how raw values are converted
+// before they are inserted into it
+type columnType struct {
+	SQL string
+	// NotNull is set when no sampled value was empty
+	NotNull bool
+	// layouts parse the values of DATE and DATETIME columns
+	layouts []string
+}
+
+// convert turns a raw value into what is stored: empty values become NULL
+// and dates and timestamps are normalised to ISO-8601. Values that do not
+// fit the column's layouts are stored as they are.
+func (t columnType) convert(v string) interface{} {
+	if v == "" {
+		return nil
+	}
+	if len(t.layouts) == 0 {
+		return v
+	}
+	ts, layout, ok := parseTime(t.layouts, v)
+	if !ok {
+		return v
+	}
+	if strings.EqualFold(t.SQL, "DATE") {
+		return ts.Format("2006-01-02")
+	}
+	if hasZone(layout) {
+		return ts.UTC().Format("2006-01-02T15:04:05.999999999Z07:00")
+	}
+	return ts.Format("2006-01-02T15:04:05.999999999")
+}
+
+func hasZone(layout string) bool {
+	return strings.Contains(layout, "Z07") || strings.Contains(layout, "-0700") || strings.Contains(layout, "MST")
+}
+
+// parseTime parses v with the first of layouts that fits it
+func parseTime(layouts []string, v string) (time.Time, string, bool) {
+	for _, layout := range layouts {
+		if ts, err := time.Parse(layout, v); err == nil {
+			return ts, layout, true
+		}
+	}
+	return time.Time{}, "", false
+}
+
+// matchLayouts returns the first candidate set that parses every value
+func matchLayouts(candidates [][]string, values []string) []string {
+	for _, layouts := range candidates {
+		fits := true
+		for _, v := range values {
+			if _, _, ok := parseTime(layouts, v); !ok {
+				fits = false
+				break
+			}
+		}
+		if fits {
+			return layouts
+		}
+	}
+	return nil
+}
+
+// inferColumnType picks the narrowest SQL type that fits every non-empty value
+func inferColumnType(values []string) columnType {
+	isInt := true
+	isFloat := true
+	isBool := true
+	hasData := false
+	hasEmpty := false
+
+	var nonEmpty []string
+	for _, v := range values {
+		if v == "" {
+			hasEmpty = true
+			continue
+		}
+		hasData = true
+		nonEmpty = append(nonEmpty, v)
+		if !intRegex.MatchString(v) {
+			isInt = false
+		}
+		if !floatRegex.MatchString(v) && !intRegex.MatchString(v) {
+			isFloat = false
+		}
+		lowerV := strings.ToLower(v)
+		if lowerV != "true" && lowerV != "false" && lowerV != "0" && lowerV != "1" && lowerV != "yes" && lowerV != "no" {
+			isBool = false
+		}
+	}
+
+	t := columnType{SQL: "VARCHAR", NotNull: hasData && !hasEmpty}
+	switch {
+	case !hasData:
+	case isBool:
+		t.SQL = "BOOL"
+	case isInt:
+		t.SQL = "INT"
+	case isFloat:
+		t.SQL = "DECIMAL"
+	default:
+		if layouts := matchLayouts(layoutCandidates(false), nonEmpty); layouts != nil {
+			t.SQL, t.layouts = "DATE", layouts
+		} else if layouts := matchLayouts(layoutCandidates(true), nonEmpty); layouts != nil {
+			t.SQL, t.layouts = "DATETIME", layouts
+		}
+	}
+	return t
+}
+
+// declaredColumnType describes an existing column of declared type decl,
+// picking the date layout of DATE and DATETIME/TIMESTAMP columns from the
+// sampled values
+func declaredColumnType(decl string, notNull bool, values []string) columnType {
+	t := columnType{SQL: decl, NotNull: notNull}
+
+	var nonEmpty []string
+	for _, v := range values {
+		if v != "" {
+			nonEmpty = append(nonEmpty, v)
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return t
+	}
+
+	upper := strings.ToUpper(decl)
+	switch {
+	case strings.Contains(upper, "DATETIME") || strings.Contains(upper, "TIMESTAMP"):
+		t.layouts = matchLayouts(layoutCandidates(true), nonEmpty)
+	case strings.Contains(upper, "DATE"):
+		t.layouts = matchLayouts(layoutCandidates(false), nonEmpty)
+	}
+	return t
+}
+
+// columnValues extracts column i from the sampled rows
+func columnValues(rows [][]string, i int) []string {
+	var values []string
+	for _, row := range rows {
+		if i < len(row) {
+			values = append(values, row[i])
+		}
+	}
+	return values
+}
+
+// guessColumnTypes infers one type per header from the sampled rows
+func guessColumnTypes(headers []string, rows [][]string) []columnType {
+	colTypes := make([]columnType, len(headers))
+	for i := range headers {
+		colTypes[i] = inferColumnType(columnValues(rows, i))
+	}
+	return colTypes
+}
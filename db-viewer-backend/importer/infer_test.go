@@ -0,0 +1,89 @@
+package importer
+
+import "testing"
+
+func TestInferColumnType(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		sql     string
+		notNull bool
+	}{
+		{"integers", []string{"1", "-20", "300"}, "INT", true},
+		{"integers and reals", []string{"1", "2.5", "-.75"}, "DECIMAL", true},
+		{"integers, reals and text", []string{"1", "2.5", "n/a"}, "VARCHAR", true},
+		{"exponents are text", []string{"1e5", "2"}, "VARCHAR", true},
+		{"trailing point is text", []string{"1.", "2"}, "VARCHAR", true},
+		{"integers with gaps", []string{"1", "", "3"}, "INT", false},
+		{"booleans", []string{"true", "No", "YES", "false"}, "BOOL", true},
+		{"zeros and ones", []string{"0", "1", "1"}, "BOOL", true},
+		{"zeros, ones and twos", []string{"0", "1", "2"}, "INT", true},
+		{"all empty", []string{"", ""}, "VARCHAR", false},
+		{"no values", nil, "VARCHAR", false},
+		{"ISO dates", []string{"2024-03-01", "", "2024-12-31"}, "DATE", false},
+		{"day-first dates", []string{"05/03/2024", "13/03/2024"}, "DATE", true},
+		{"named months", []string{"1 Mar 2024", "Mar 2, 2024"}, "DATE", true},
+		{"dates and timestamps", []string{"2024-03-01", "2024-03-01T10:00:00Z"}, "DATETIME", true},
+		{"timestamps", []string{"2024-03-01 10:00:00", "2024-03-01 10:00:00.123"}, "DATETIME", true},
+		{"invalid dates", []string{"2024-02-30"}, "VARCHAR", true},
+		{"dates and text", []string{"2024-03-01", "soon"}, "VARCHAR", true},
+	}
+	for _, tt := range tests {
+		got := inferColumnType(tt.values)
+		if got.SQL != tt.sql || got.NotNull != tt.notNull {
+			t.Errorf("%s: inferColumnType(%q) = %s, NotNull %v; want %s, %v", tt.name, tt.values, got.SQL, got.NotNull, tt.sql, tt.notNull)
+		}
+	}
+}
+
+func TestColumnTypeConvert(t *testing.T) {
+	tests := []struct {
+		values []string
+		in     string
+		want   interface{}
+	}{
+		{[]string{"1", "2"}, "", nil},
+		{[]string{"1", "2"}, "2", "2"},
+		{[]string{"2024-03-01"}, "2024-03-01", "2024-03-01"},
+		{[]string{"03/05/2024", "12/31/2024"}, "03/05/2024", "2024-03-05"},
+		{[]string{"03/05/2024", "31/12/2024"}, "03/05/2024", "2024-05-03"},
+		{[]string{"1.3.2024"}, "1.3.2024", "2024-03-01"},
+		{[]string{"2024-03-01 10:00", "2024-03-01"}, "2024-03-01 10:00", "2024-03-01T10:00:00"},
+		{[]string{"2024-03-01T10:00:00.5"}, "2024-03-01T10:00:00.5", "2024-03-01T10:00:00.5"},
+		{[]string{"2024-03-01T12:00:00+02:00"}, "2024-03-01T12:00:00+02:00", "2024-03-01T10:00:00Z"},
+		// Values past the sample that fit none of the layouts are kept
+		{[]string{"2024-03-01"}, "March", "March"},
+	}
+	for _, tt := range tests {
+		if got := inferColumnType(tt.values).convert(tt.in); got != tt.want {
+			t.Errorf("column of %q: convert(%q) = %v, want %v", tt.values, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDeclaredColumnType(t *testing.T) {
+	tests := []struct {
+		decl string
+		in   string
+		want interface{}
+	}{
+		{"TIMESTAMP", "01/02/2024 08:30", "2024-01-02T08:30:00"},
+		{"date", "25/12/2024", "2024-12-25"},
+		{"VARCHAR(10)", "01/02/2024", "01/02/2024"},
+		{"INTEGER", "", nil},
+	}
+	for _, tt := range tests {
+		got := declaredColumnType(tt.decl, false, []string{tt.in, ""}).convert(tt.in)
+		if got != tt.want {
+			t.Errorf("%s column: convert(%q) = %v, want %v", tt.decl, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestGuessColumnTypesRagged(t *testing.T) {
+	// Short rows leave the missing columns out rather than empty
+	types := guessColumnTypes([]string{"a", "b"}, [][]string{{"1", "x"}, {"2"}})
+	if types[0].SQL != "INT" || types[1].SQL != "VARCHAR" || !types[1].NotNull {
+		t.Errorf("guessColumnTypes = %+v", types)
+	}
+}
@@ -0,0 +1,583 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// JSON input layouts
+const (
+	// JSONStream is a top-level array of objects, or objects simply following
+	// one another (which covers a single, possibly pretty-printed, object)
+	JSONStream = "stream"
+	// JSONLines is newline-delimited JSON: one object per line
+	JSONLines = "lines"
+)
+
+// JSONOptions controls how JSON objects are turned into rows
+type JSONOptions struct {
+	// Layout is JSONStream or JSONLines
+	Layout string
+	// SplitArrays moves arrays of objects into child tables named
+	// <table>_<field> instead of storing them as JSON text
+	SplitArrays bool
+	// SampleSize is how many leading objects decide the columns
+	SampleSize int
+}
+
+// ChildTable is a table split off a JSON import; its rows are spooled to a
+// temporary NDJSON file that can be imported with NewJSONSource.
+type ChildTable struct {
+	Name      string
+	ParentKey string
+	file      *os.File
+}
+
+// Open rewinds the spooled rows for reading
+func (t *ChildTable) Open() (io.Reader, error) {
+	if _, err := t.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return t.file, nil
+}
+
+// jsonField and jsonObject keep object keys in document order, which
+// decoding into a map would lose
+type jsonField struct {
+	key   string
+	value interface{}
+}
+
+type jsonObject []jsonField
+
+// pendingRecord is a sampled object waiting to be replayed, or the error it produced
+type pendingRecord struct {
+	line   int
+	values map[string]string
+	err    error
+}
+
+// JSONSource flattens JSON objects into records. Nested objects become
+// parent_child columns; the columns are the union of the keys seen in the
+// first SampleSize objects, and keys that only appear later are dropped with
+// a warning.
+type JSONSource struct {
+	table string
+	opts  JSONOptions
+	next  func() (interface{}, int, error)
+
+	header      []string
+	columnIndex map[string]int
+	ordinal     int
+	// ownIDs is decided by the first object when splitting arrays: whether
+	// the objects carry their own "id" or are numbered by position
+	ownIDs *bool
+
+	pending  []pendingRecord
+	dropped  map[string]bool
+	children map[string]*ChildTable
+	order    []string
+}
+
+// NewJSONSource reads the leading objects of r to settle the columns of table
+func NewJSONSource(r io.Reader, table string, opts JSONOptions) (*JSONSource, error) {
+	if opts.SampleSize <= 0 {
+		opts.SampleSize = DefaultSampleSize
+	}
+
+	s := &JSONSource{
+		table:       table,
+		opts:        opts,
+		columnIndex: make(map[string]int),
+		dropped:     make(map[string]bool),
+		children:    make(map[string]*ChildTable),
+	}
+
+	br := bufio.NewReader(r)
+	if opts.Layout == JSONLines {
+		s.next = lineReader(br)
+	} else {
+		next, err := streamReader(br)
+		if err != nil {
+			return nil, err
+		}
+		s.next = next
+	}
+
+	// Sample the leading objects to find the columns
+	objects := 0
+	for objects < opts.SampleSize {
+		value, line, err := s.next()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			s.pending = append(s.pending, pendingRecord{line: line, err: err})
+			continue
+		}
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+
+		obj, ok := value.(jsonObject)
+		if !ok {
+			s.pending = append(s.pending, pendingRecord{line: line, err: notAnObject(line)})
+			continue
+		}
+		flat, err := s.flatten(obj)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		for _, key := range flat.keys {
+			if _, seen := s.columnIndex[key]; !seen {
+				s.columnIndex[key] = len(s.header)
+				s.header = append(s.header, key)
+			}
+		}
+		s.pending = append(s.pending, pendingRecord{line: line, values: flat.values})
+		objects++
+	}
+
+	if objects == 0 {
+		s.Close()
+		return nil, &InputError{errors.New("JSON contains no objects")}
+	}
+	return s, nil
+}
+
+func (s *JSONSource) Header() []string {
+	return s.header
+}
+
+func (s *JSONSource) Next() (Record, error) {
+	if len(s.pending) > 0 {
+		p := s.pending[0]
+		s.pending = s.pending[1:]
+		if p.err != nil {
+			return Record{}, p.err
+		}
+		return Record{Line: p.line, Fields: s.toFields(p.values)}, nil
+	}
+
+	value, line, err := s.next()
+	if err != nil {
+		return Record{}, err
+	}
+	obj, ok := value.(jsonObject)
+	if !ok {
+		return Record{}, notAnObject(line)
+	}
+
+	flat, err := s.flatten(obj)
+	if err != nil {
+		return Record{}, err
+	}
+	for _, key := range flat.keys {
+		if _, known := s.columnIndex[key]; !known {
+			s.dropped[key] = true
+		}
+	}
+	return Record{Line: line, Fields: s.toFields(flat.values)}, nil
+}
+
+// Warnings lists the keys that were dropped because they were not in the sample
+func (s *JSONSource) Warnings() []string {
+	var warnings []string
+	for key := range s.dropped {
+		warnings = append(warnings, fmt.Sprintf("field %q first appears after the sampled objects and was not imported", key))
+	}
+	sort.Strings(warnings)
+	return warnings
+}
+
+// ChildTables returns the tables split off by SplitArrays, in the order
+// their fields were first seen. Call it once the parent has been imported.
+func (s *JSONSource) ChildTables() []*ChildTable {
+	tables := make([]*ChildTable, 0, len(s.order))
+	for _, field := range s.order {
+		tables = append(tables, s.children[field])
+	}
+	return tables
+}
+
+// Close removes the spooled child rows
+func (s *JSONSource) Close() {
+	for _, child := range s.children {
+		child.file.Close()
+		os.Remove(child.file.Name())
+	}
+}
+
+type flatObject struct {
+	keys   []string
+	values map[string]string
+}
+
+// flatten turns one object into column values, spooling arrays of objects
+// into child tables when SplitArrays is on
+func (s *JSONSource) flatten(obj jsonObject) (flatObject, error) {
+	s.ordinal++
+	flat := flatObject{values: make(map[string]string)}
+
+	var childArrays []jsonField
+	var walk func(prefix string, obj jsonObject)
+	walk = func(prefix string, obj jsonObject) {
+		for _, f := range obj {
+			key := f.key
+			if prefix != "" {
+				key = prefix + "_" + f.key
+			}
+
+			switch v := f.value.(type) {
+			case jsonObject:
+				walk(key, v)
+				continue
+			case []interface{}:
+				if s.opts.SplitArrays && isObjectArray(v) {
+					childArrays = append(childArrays, jsonField{key: key, value: v})
+					continue
+				}
+			}
+
+			if _, seen := flat.values[key]; !seen {
+				flat.keys = append(flat.keys, key)
+			}
+			flat.values[key] = scalarText(f.value)
+		}
+	}
+	walk("", obj)
+
+	if !s.opts.SplitArrays {
+		return flat, nil
+	}
+
+	// Child rows point at their parent through its id, generated from the
+	// object's position when the objects have none of their own. A generated
+	// id could repeat a real one, so either every object has an id or none.
+	_, hasID := flat.values["id"]
+	if s.ownIDs == nil {
+		s.ownIDs = &hasID
+	}
+	switch {
+	case *s.ownIDs && !hasID:
+		return flat, &InputError{fmt.Errorf("object %d has no \"id\" while earlier objects do; split_arrays needs an id on every object or on none", s.ordinal)}
+	case !*s.ownIDs && hasID:
+		return flat, &InputError{fmt.Errorf("object %d has an \"id\" while earlier objects do not; split_arrays needs an id on every object or on none", s.ordinal)}
+	case !hasID:
+		flat.keys = append([]string{"id"}, flat.keys...)
+		flat.values["id"] = fmt.Sprint(s.ordinal)
+	}
+	for _, arr := range childArrays {
+		if err := s.spoolChildren(arr.key, flat.values["id"], arr.value.([]interface{})); err != nil {
+			return flat, err
+		}
+	}
+	return flat, nil
+}
+
+// spoolChildren appends the objects of an array to the child table of field,
+// each tagged with the key of its parent row. The key column is named
+// <table>_id unless the first objects already use that name; an object that
+// uses the name picked fails the import rather than lose its link.
+func (s *JSONSource) spoolChildren(field, parentID string, items []interface{}) error {
+	child, ok := s.children[field]
+	if !ok {
+		file, err := os.CreateTemp("", "db-viewer-child-*.ndjson")
+		if err != nil {
+			return err
+		}
+		child = &ChildTable{
+			Name:      s.table + "_" + field,
+			ParentKey: parentKeyName(strings.TrimSuffix(s.table, "s")+"_id", items),
+			file:      file,
+		}
+		s.children[field] = child
+		s.order = append(s.order, field)
+	}
+
+	for _, item := range items {
+		if hasFlatKey(item.(jsonObject), "", child.ParentKey) {
+			return &InputError{fmt.Errorf("an object in %q has a %q field, which is the column linking it to its parent", field, child.ParentKey)}
+		}
+	}
+
+	w := bufio.NewWriter(child.file)
+	for _, item := range items {
+		obj := append(jsonObject{{key: child.ParentKey, value: parentID}}, item.(jsonObject)...)
+		if _, err := w.Write(encodeObject(obj)); err != nil {
+			return err
+		}
+		w.WriteByte('\n')
+	}
+	return w.Flush()
+}
+
+// parentKeyName picks the first of name, parent_<name>, parent_<name>_2, ...
+// that none of items flattens to
+func parentKeyName(name string, items []interface{}) string {
+	taken := func(key string) bool {
+		for _, item := range items {
+			if hasFlatKey(item.(jsonObject), "", key) {
+				return true
+			}
+		}
+		return false
+	}
+
+	candidate := name
+	for n := 1; taken(candidate); n++ {
+		candidate = "parent_" + name
+		if n > 1 {
+			candidate = fmt.Sprintf("parent_%s_%d", name, n)
+		}
+	}
+	return candidate
+}
+
+// hasFlatKey reports whether obj has a field that flattens to the column key
+func hasFlatKey(obj jsonObject, prefix, key string) bool {
+	for _, f := range obj {
+		name := f.key
+		if prefix != "" {
+			name = prefix + "_" + f.key
+		}
+		if nested, ok := f.value.(jsonObject); ok {
+			if hasFlatKey(nested, name, key) {
+				return true
+			}
+			continue
+		}
+		if name == key {
+			return true
+		}
+	}
+	return false
+}
+
+// toFields lays a flattened object out in header order
+func (s *JSONSource) toFields(values map[string]string) []string {
+	fields := make([]string, len(s.header))
+	for key, value := range values {
+		if i, ok := s.columnIndex[key]; ok {
+			fields[i] = value
+		}
+	}
+	return fields
+}
+
+// lineReader yields one value per non-blank line; a malformed line is a RowError
+func lineReader(br *bufio.Reader) func() (interface{}, int, error) {
+	line := 0
+	return func() (interface{}, int, error) {
+		for {
+			raw, err := br.ReadBytes('\n')
+			if len(raw) == 0 && err != nil {
+				return nil, line, err
+			}
+			line++
+
+			raw = bytes.TrimSpace(raw)
+			if len(raw) == 0 {
+				continue
+			}
+
+			dec := json.NewDecoder(bytes.NewReader(raw))
+			dec.UseNumber()
+			value, decErr := decodeValue(dec)
+			if decErr != nil {
+				return nil, line, &RowError{Line: line, Reason: "invalid JSON: " + decErr.Error()}
+			}
+			return value, line, nil
+		}
+	}
+}
+
+// streamReader yields the elements of a top-level array, or successive
+// top-level values. Values are numbered from 1 in place of line numbers.
+func streamReader(br *bufio.Reader) (func() (interface{}, int, error), error) {
+	dec := json.NewDecoder(br)
+	dec.UseNumber()
+
+	inArray := false
+	first, err := firstNonSpace(br)
+	if err != nil {
+		return nil, &InputError{errors.New("JSON is empty")}
+	}
+	if first == '[' {
+		if _, err := dec.Token(); err != nil {
+			return nil, &InputError{fmt.Errorf("invalid JSON: %w", err)}
+		}
+		inArray = true
+	}
+
+	n := 0
+	done := false
+	return func() (interface{}, int, error) {
+		if done {
+			return nil, n, io.EOF
+		}
+		if inArray && !dec.More() {
+			if _, err := dec.Token(); err != nil {
+				return nil, n, &InputError{fmt.Errorf("invalid JSON: %w", err)}
+			}
+			done = true
+			return nil, n, io.EOF
+		}
+
+		value, err := decodeValue(dec)
+		if err == io.EOF && !inArray {
+			done = true
+			return nil, n, io.EOF
+		}
+		if err != nil {
+			return nil, n, &InputError{fmt.Errorf("invalid JSON after value %d: %w", n, err)}
+		}
+		n++
+		return value, n, nil
+	}, nil
+}
+
+// decodeValue reads one value, keeping object keys in order
+func decodeValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return tok, nil
+	}
+
+	switch delim {
+	case '{':
+		obj := jsonObject{}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, jsonField{key: keyTok.(string), value: value})
+		}
+		_, err = dec.Token()
+		return obj, err
+	case '[':
+		arr := []interface{}{}
+		for dec.More() {
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		_, err = dec.Token()
+		return arr, err
+	}
+	return nil, fmt.Errorf("unexpected %v", delim)
+}
+
+// scalarText renders a leaf value as the text stored in its column. Arrays
+// that are not split off are kept as JSON text; null becomes an empty value.
+func scalarText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	default:
+		return string(encodeValue(v))
+	}
+}
+
+func encodeObject(obj jsonObject) []byte {
+	return encodeValue(obj)
+}
+
+// encodeValue re-encodes a decoded value, keeping object key order
+func encodeValue(value interface{}) []byte {
+	var buf bytes.Buffer
+	switch v := value.(type) {
+	case jsonObject:
+		buf.WriteByte('{')
+		for i, f := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(f.key)
+			buf.Write(key)
+			buf.WriteByte(':')
+			buf.Write(encodeValue(f.value))
+		}
+		buf.WriteByte('}')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.Write(encodeValue(item))
+		}
+		buf.WriteByte(']')
+	default:
+		b, _ := json.Marshal(v)
+		buf.Write(b)
+	}
+	return buf.Bytes()
+}
+
+func isObjectArray(arr []interface{}) bool {
+	if len(arr) == 0 {
+		return false
+	}
+	for _, item := range arr {
+		if _, ok := item.(jsonObject); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func notAnObject(line int) error {
+	return &RowError{Line: line, Reason: "expected a JSON object"}
+}
+
+func firstNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b == 0xEF {
+			// Skip a UTF-8 byte order mark
+			if rest, err := br.Peek(2); err == nil && rest[0] == 0xBB && rest[1] == 0xBF {
+				br.Discard(2)
+				continue
+			}
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			br.UnreadByte()
+			return b, nil
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package importer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"db-viewer/database"
+)
+
+// Import modes decide what happens when the target table already exists
+const (
+	// ModeCreate creates a new table and fails if one by that name exists
+	ModeCreate = "create"
+	// ModeReplace drops the existing table once the new one is fully loaded
+	ModeReplace = "replace"
+	// ModeAppend inserts into an existing table, mapping headers to its columns
+	ModeAppend = "append"
+	// ModeUpsert updates the rows whose key column matches and inserts the rest
+	ModeUpsert = "upsert"
+)
+
+// Ragged row policies decide what happens to rows whose field count does not
+// match the header
+const (
+	// RaggedReject rejects them
+	RaggedReject = "reject"
+	// RaggedPad pads short rows with empty values and rejects long ones
+	RaggedPad = "pad"
+	// RaggedTruncate drops the extra fields of long rows and rejects short ones
+	RaggedTruncate = "truncate"
+	// RaggedFit both pads short rows and truncates long ones
+	RaggedFit = "fit"
+)
+
+// ValidRagged reports whether policy is one of the ragged row policies
+func ValidRagged(policy string) bool {
+	switch policy {
+	case RaggedReject, RaggedPad, RaggedTruncate, RaggedFit:
+		return true
+	}
+	return false
+}
+
+// ErrTableExists is returned in create mode when the target table exists
+var ErrTableExists = errors.New("table already exists")
+
+// ValidMode reports whether mode is one of the import modes
+func ValidMode(mode string) bool {
+	switch mode {
+	case ModeCreate, ModeReplace, ModeAppend, ModeUpsert:
+		return true
+	}
+	return false
+}
+
+// MappingError reports an upload whose header does not line up with the
+// table it is appended or upserted into. Nothing has been written when it is
+// returned.
+type MappingError struct {
+	Table string `json:"table"`
+	// UnknownColumns are file headers the table has no column for
+	UnknownColumns []string `json:"unknown_columns"`
+	// MissingColumns are NOT NULL columns without a default the file does not provide
+	MissingColumns []string `json:"missing_columns"`
+	// Reason explains failures that are not about individual columns
+	Reason string `json:"reason,omitempty"`
+}
+
+func (e *MappingError) Error() string {
+	var parts []string
+	if e.Reason != "" {
+		parts = append(parts, e.Reason)
+	}
+	if len(e.UnknownColumns) > 0 {
+		parts = append(parts, "unknown columns: "+strings.Join(e.UnknownColumns, ", "))
+	}
+	if len(e.MissingColumns) > 0 {
+		parts = append(parts, "missing required columns: "+strings.Join(e.MissingColumns, ", "))
+	}
+	return fmt.Sprintf("columns do not match table %q: %s", e.Table, strings.Join(parts, "; "))
+}
+
+type existingColumn struct {
+	name     string
+	typ      string
+	notNull  bool
+	required bool
+}
+
+// mapColumns matches headers to the columns of an existing table, case-
+// insensitively, and returns the columns' stored names and declared types in
+// header order.
+func mapColumns(ctx context.Context, db *sql.DB, table string, headers []string) ([]string, []columnType, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name, type, \"notnull\", dflt_value IS NOT NULL, pk FROM pragma_table_info(?) ORDER BY cid", table)
+	if err != nil {
+		return nil, nil, err
+	}
+	var existing []existingColumn
+	for rows.Next() {
+		var col existingColumn
+		var hasDefault bool
+		var pk int
+		if err := rows.Scan(&col.name, &col.typ, &col.notNull, &hasDefault, &pk); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		// An INTEGER PRIMARY KEY is the rowid and fills itself in
+		rowid := pk == 1 && strings.EqualFold(col.typ, "INTEGER")
+		col.required = col.notNull && !hasDefault && !rowid
+		existing = append(existing, col)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	byName := make(map[string]existingColumn, len(existing))
+	for _, col := range existing {
+		byName[strings.ToLower(col.name)] = col
+	}
+
+	mapping := &MappingError{Table: table, UnknownColumns: []string{}, MissingColumns: []string{}}
+	names := make([]string, len(headers))
+	types := make([]columnType, len(headers))
+	provided := make(map[string]bool, len(headers))
+	for i, h := range headers {
+		col, ok := byName[strings.ToLower(h)]
+		if !ok {
+			mapping.UnknownColumns = append(mapping.UnknownColumns, h)
+			continue
+		}
+		names[i] = col.name
+		types[i] = columnType{SQL: col.typ, NotNull: col.notNull}
+		provided[strings.ToLower(col.name)] = true
+	}
+	for _, col := range existing {
+		if col.required && !provided[strings.ToLower(col.name)] {
+			mapping.MissingColumns = append(mapping.MissingColumns, col.name)
+		}
+	}
+
+	if len(mapping.UnknownColumns) > 0 || len(mapping.MissingColumns) > 0 {
+		return nil, nil, mapping
+	}
+	return names, types, nil
+}
+
+// keyIndex finds the upsert key among the mapped column names
+func keyIndex(table string, columns []string, key string) (int, error) {
+	if key == "" {
+		return -1, &MappingError{Table: table, UnknownColumns: []string{}, MissingColumns: []string{}, Reason: "upsert needs a key column"}
+	}
+	for i, col := range columns {
+		if strings.EqualFold(col, key) {
+			return i, nil
+		}
+	}
+	return -1, &MappingError{
+		Table:          table,
+		UnknownColumns: []string{},
+		MissingColumns: []string{key},
+		Reason:         fmt.Sprintf("key column %q is not in the file", key),
+	}
+}
+
+// existingTable returns the stored name of table, or "" if there is none
+func existingTable(db *sql.DB, table string) (string, error) {
+	stored, err := database.ResolveTable(db, table)
+	if errors.Is(err, database.ErrUnknownTable) {
+		return "", nil
+	}
+	return stored, err
+}
+
+func buildUpdateSQL(tableName string, columns []string, key int) string {
+	sets := make([]string, 0, len(columns))
+	for i, col := range columns {
+		if i != key {
+			sets = append(sets, database.QuoteIdent(col)+" = ?")
+		}
+	}
+	if len(sets) == 0 {
+		// Only the key was uploaded: match the row without changing it
+		sets = append(sets, database.QuoteIdent(columns[key])+" = "+database.QuoteIdent(columns[key]))
+	}
+	return fmt.Sprintf("UPDATE %s SET %s WHERE %s = ?", database.QuoteIdent(tableName), strings.Join(sets, ", "), database.QuoteIdent(columns[key]))
+}
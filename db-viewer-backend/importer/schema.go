@@ -0,0 +1,163 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"db-viewer/database"
+	"db-viewer/models"
+)
+
+// pinnableTypes are the column types a schema override may pin
+var pinnableTypes = map[string]bool{
+	"VARCHAR": true, "TEXT": true,
+	"INT": true, "INTEGER": true,
+	"DECIMAL": true, "REAL": true,
+	"BOOL": true, "BOOLEAN": true,
+	"DATE": true, "DATETIME": true, "TIMESTAMP": true,
+}
+
+// columnPlan is the outcome of applying a schema override to a header
+type columnPlan struct {
+	// keep holds the source field index of every output column
+	keep  []int
+	names []string
+	// pinned is the type pinned for each output column, or "" to infer it
+	pinned     []string
+	primaryKey []string
+}
+
+// planColumns applies schema to the cleaned headers of an upload: columns
+// are skipped, renamed and have their types pinned, and the primary key is
+// resolved against the final names (or the original headers).
+func planColumns(headers []string, schema *models.SchemaOverride) (*columnPlan, error) {
+	plan := &columnPlan{}
+	if schema == nil {
+		for i, h := range headers {
+			plan.keep = append(plan.keep, i)
+			plan.names = append(plan.names, h)
+			plan.pinned = append(plan.pinned, "")
+		}
+		return plan, nil
+	}
+
+	byHeader := make(map[string]int, len(headers))
+	for i, h := range headers {
+		byHeader[strings.ToLower(h)] = i
+	}
+
+	// Names may be given as written in the file or as cleaned column names
+	lookup := func(name string) (int, bool) {
+		if i, ok := byHeader[strings.ToLower(strings.TrimSpace(name))]; ok {
+			return i, true
+		}
+		cleaned, err := database.CleanIdent(name)
+		if err != nil {
+			return 0, false
+		}
+		i, ok := byHeader[strings.ToLower(cleaned)]
+		return i, ok
+	}
+
+	overrides := make(map[int]models.ColumnOverride, len(schema.Columns))
+	var unknown []string
+	for name, override := range schema.Columns {
+		i, ok := lookup(name)
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		overrides[i] = override
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, &InputError{fmt.Errorf("schema names columns the file does not have: %s", strings.Join(unknown, ", "))}
+	}
+
+	finalName := make(map[int]string, len(headers))
+	seen := make(map[string]bool, len(headers))
+	for i, h := range headers {
+		override := overrides[i]
+		if override.Skip {
+			continue
+		}
+
+		name := h
+		if override.Rename != "" {
+			renamed, err := database.CleanIdent(override.Rename)
+			if err != nil {
+				return nil, &InputError{fmt.Errorf("cannot rename %q: %w", h, err)}
+			}
+			name = renamed
+		}
+		if seen[strings.ToLower(name)] {
+			return nil, &InputError{fmt.Errorf("schema gives more than one column the name %q", name)}
+		}
+		seen[strings.ToLower(name)] = true
+
+		typ := strings.ToUpper(strings.TrimSpace(override.Type))
+		if typ != "" && !pinnableTypes[typ] {
+			return nil, &InputError{fmt.Errorf("unsupported type %q for column %q", override.Type, h)}
+		}
+
+		finalName[i] = name
+		plan.keep = append(plan.keep, i)
+		plan.names = append(plan.names, name)
+		plan.pinned = append(plan.pinned, typ)
+	}
+	if len(plan.keep) == 0 {
+		return nil, &InputError{errors.New("schema skips every column")}
+	}
+
+	for _, key := range schema.PrimaryKey {
+		name, ok := resolveKeyColumn(plan.names, finalName, lookup, key)
+		if !ok {
+			return nil, &InputError{fmt.Errorf("primary key column %q is not in the imported columns", key)}
+		}
+		plan.primaryKey = append(plan.primaryKey, name)
+	}
+	return plan, nil
+}
+
+// resolveKeyColumn finds key among the final column names, falling back to
+// the original header of a renamed column
+func resolveKeyColumn(names []string, finalName map[int]string, lookup func(string) (int, bool), key string) (string, bool) {
+	for _, name := range names {
+		if strings.EqualFold(name, strings.TrimSpace(key)) {
+			return name, true
+		}
+	}
+	if i, ok := lookup(key); ok {
+		name, kept := finalName[i]
+		return name, kept
+	}
+	return "", false
+}
+
+// pinsSchema reports whether the plan changes column types or keys, which
+// only applies to tables the import creates
+func (p *columnPlan) pinsSchema() bool {
+	if len(p.primaryKey) > 0 {
+		return true
+	}
+	for _, typ := range p.pinned {
+		if typ != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// project picks the kept fields out of a record's fields
+func (p *columnPlan) project(fields []string) []string {
+	if len(p.keep) == len(fields) {
+		return fields
+	}
+	out := make([]string, len(p.keep))
+	for i, src := range p.keep {
+		out[i] = fields[src]
+	}
+	return out
+}
@@ -0,0 +1,45 @@
+package importer
+
+import (
+	"fmt"
+)
+
+// Record is a single data row read from an upload
+type Record struct {
+	Line   int
+	Fields []string
+}
+
+// RowError marks a record the source could not parse. The import skips it,
+// reports it and carries on with the next one.
+type RowError struct {
+	Line   int
+	Reason string
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+}
+
+// InputError reports an upload that cannot be parsed at all, as opposed to a
+// failure while writing it to the database.
+type InputError struct {
+	Err error
+}
+
+func (e *InputError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *InputError) Unwrap() error {
+	return e.Err
+}
+
+// Source streams the header and records of an uploaded file
+type Source interface {
+	// Header returns the column names, as written in the file
+	Header() []string
+	// Next returns the next record, a *RowError for a record that should be
+	// skipped, or io.EOF once the input is exhausted.
+	Next() (Record, error)
+}
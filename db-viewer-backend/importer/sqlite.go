@@ -0,0 +1,327 @@
+package importer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"db-viewer/database"
+	"db-viewer/models"
+)
+
+const (
+	// maxStatementReported caps how much of a failed statement is echoed back
+	maxStatementReported = 200
+	sqliteMagic          = "SQLite format 3\x00"
+)
+
+type schemaObject struct {
+	kind      string
+	name      string
+	tableName string
+	sql       string
+}
+
+// ImportSQLite copies the tables (with their rows), indexes, views and
+// triggers of the SQLite database file at path into db, in one transaction.
+// Tables that already exist in db are skipped along with their indexes and
+// triggers; objects SQLite refuses, or whose schema is anything but a single
+// CREATE of their type, are reported as failed statements. Foreign
+// keys are not enforced during the copy, so tables may come in any order;
+// rows that break them afterwards are reported as violations.
+func ImportSQLite(ctx context.Context, db *sql.DB, path string, result *models.UploadResult) error {
+	if err := checkSQLiteHeader(path); err != nil {
+		return err
+	}
+
+	// ATTACH is per connection, so pin one for the whole copy
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), "PRAGMA foreign_keys = ON")
+
+	objects, err := attachUpload(ctx, conn, path)
+	if err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), "DETACH DATABASE upload")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	skipped := make(map[string]bool)
+	for i, obj := range objects {
+		if obj.kind == "table" {
+			var exists int
+			err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM main.sqlite_master WHERE type = 'table' AND name = ? COLLATE NOCASE", obj.name).Scan(&exists)
+			if err != nil {
+				return err
+			}
+			if exists > 0 {
+				skipped[obj.name] = true
+				result.Skipped = append(result.Skipped, models.SkippedSource{Name: obj.name, Reason: "table already exists"})
+				continue
+			}
+		} else if skipped[obj.tableName] {
+			continue
+		}
+
+		stmt, err := schemaStatement(obj)
+		if err == nil {
+			_, err = tx.ExecContext(ctx, stmt)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			result.FailedStatements = append(result.FailedStatements, statementError(i, 0, obj.sql, err))
+			if obj.kind == "table" {
+				skipped[obj.name] = true
+			}
+			continue
+		}
+		if obj.kind != "table" {
+			continue
+		}
+
+		quoted := database.QuoteIdent(obj.name)
+		res, err := tx.ExecContext(ctx, "INSERT INTO main."+quoted+" SELECT * FROM upload."+quoted)
+		if err != nil {
+			return fmt.Errorf("failed to copy rows of %q: %w", obj.name, err)
+		}
+		copied, _ := res.RowsAffected()
+
+		table, err := describeTable(ctx, tx, obj.name)
+		if err != nil {
+			return err
+		}
+		table.RowsInserted = copied
+		result.Tables = append(result.Tables, *table)
+	}
+
+	if err := checkImportedForeignKeys(ctx, tx, result); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SQLiteSchema returns the statements ImportSQLite would run to recreate the
+// tables, indexes, views and triggers of the SQLite database file at path,
+// so they can be checked before the import
+func SQLiteSchema(ctx context.Context, db *sql.DB, path string) ([]string, error) {
+	if err := checkSQLiteHeader(path); err != nil {
+		return nil, err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	objects, err := attachUpload(ctx, conn, path)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.ExecContext(context.Background(), "DETACH DATABASE upload")
+
+	stmts := make([]string, len(objects))
+	for i, obj := range objects {
+		stmts[i] = obj.sql
+	}
+	return stmts, nil
+}
+
+// ImportSQLScript runs the CREATE/INSERT statements of a SQL script in one
+// transaction. Statements that fail are reported and skipped; transaction
+// control statements in the script (BEGIN, COMMIT, ...) are ignored in favour
+// of the surrounding transaction. The tables the script created are reported
+// with their final row counts. As with ImportSQLite, foreign keys are checked
+// once the script has run rather than statement by statement.
+func ImportSQLScript(ctx context.Context, db *sql.DB, script string, result *models.UploadResult) error {
+	return database.WithoutForeignKeys(ctx, db, func(conn *sql.Conn) error {
+		return runSQLScript(ctx, conn, script, result)
+	})
+}
+
+func runSQLScript(ctx context.Context, conn *sql.Conn, script string, result *models.UploadResult) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := database.TableNames(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	for i, stmt := range database.SplitStatements(script) {
+		if database.IsTransactionControl(stmt.SQL) {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, stmt.SQL); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			result.FailedStatements = append(result.FailedStatements, statementError(i, stmt.Line, stmt.SQL, err))
+		}
+	}
+
+	after, err := database.TableNames(ctx, tx)
+	if err != nil {
+		return err
+	}
+	existed := make(map[string]bool, len(before))
+	for _, name := range before {
+		existed[name] = true
+	}
+	for _, name := range after {
+		if existed[name] {
+			continue
+		}
+		table, err := describeTable(ctx, tx, name)
+		if err != nil {
+			return err
+		}
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+database.QuoteIdent(name)).Scan(&table.RowsInserted); err != nil {
+			return err
+		}
+		result.Tables = append(result.Tables, *table)
+	}
+
+	if err := checkImportedForeignKeys(ctx, tx, result); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkImportedForeignKeys reports the rows of the imported tables that
+// break their foreign keys
+func checkImportedForeignKeys(ctx context.Context, tx *sql.Tx, result *models.UploadResult) error {
+	for _, table := range result.Tables {
+		violations, err := database.CheckForeignKeys(ctx, tx, table.Table)
+		if err != nil {
+			return err
+		}
+		result.Violations = append(result.Violations, violations...)
+	}
+	return nil
+}
+
+// attachUpload attaches the database file at path to conn as "upload" and
+// lists its schema. The caller detaches it once it succeeds.
+func attachUpload(ctx context.Context, conn *sql.Conn, path string) ([]schemaObject, error) {
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS upload", path); err != nil {
+		return nil, &InputError{fmt.Errorf("failed to attach database: %w", err)}
+	}
+
+	objects, err := attachedObjects(ctx, conn)
+	if err != nil {
+		conn.ExecContext(context.Background(), "DETACH DATABASE upload")
+		return nil, &InputError{fmt.Errorf("not a readable SQLite database: %w", err)}
+	}
+	return objects, nil
+}
+
+// attachedObjects lists the schema of the attached upload, tables first so
+// indexes, views and triggers find what they depend on
+func attachedObjects(ctx context.Context, conn *sql.Conn) ([]schemaObject, error) {
+	rows, err := conn.QueryContext(ctx, `
+		SELECT type, name, tbl_name, sql FROM upload.sqlite_master
+		WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite\_%' ESCAPE '\' AND tbl_name NOT LIKE '\_dbviewer\_%' ESCAPE '\'
+		ORDER BY CASE type WHEN 'table' THEN 0 WHEN 'index' THEN 1 WHEN 'view' THEN 2 ELSE 3 END, rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var objects []schemaObject
+	for rows.Next() {
+		var obj schemaObject
+		if err := rows.Scan(&obj.kind, &obj.name, &obj.tableName, &obj.sql); err != nil {
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+	return objects, rows.Err()
+}
+
+// schemaStatement returns the statement that recreates obj. The schema of
+// an upload is text a crafted file may fill with anything, and the driver
+// runs every statement it is given, so it must be exactly one CREATE of the
+// object's type.
+func schemaStatement(obj schemaObject) (string, error) {
+	stmt, err := database.SingleStatement(obj.sql)
+	if err != nil {
+		return "", err
+	}
+	if database.CreatedType(stmt) != obj.kind {
+		return "", fmt.Errorf("not a CREATE %s statement", strings.ToUpper(obj.kind))
+	}
+	return stmt, nil
+}
+
+// describeTable reports the columns, declared types and foreign keys of an
+// existing table
+func describeTable(ctx context.Context, tx *sql.Tx, name string) (*models.ImportResult, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT name, type, \"notnull\" FROM main.pragma_table_info(?) ORDER BY cid", name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	table := &models.ImportResult{Table: name, Columns: []string{}, Types: []string{}, Rejected: []models.RejectedRow{}}
+	for rows.Next() {
+		var col models.ColumnInfo
+		if err := rows.Scan(&col.Name, &col.Type, &col.NotNull); err != nil {
+			return nil, err
+		}
+		table.Columns = append(table.Columns, col.Name)
+		table.Types = append(table.Types, col.Type)
+		table.Schema = append(table.Schema, col)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	table.ForeignKeys, err = database.ForeignKeys(ctx, tx, name)
+	return table, err
+}
+
+func statementError(index, line int, stmt string, err error) models.StatementError {
+	if len(stmt) > maxStatementReported {
+		stmt = stmt[:maxStatementReported] + "..."
+	}
+	return models.StatementError{Index: index, Line: line, Statement: stmt, Error: err.Error()}
+}
+
+// checkSQLiteHeader rejects files that do not start with the SQLite magic
+// string; ATTACH alone accepts anything and only fails on first use.
+func checkSQLiteHeader(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	header := make([]byte, len(sqliteMagic))
+	if _, err := io.ReadFull(f, header); err != nil || string(header) != sqliteMagic {
+		return &InputError{errors.New("file is not a SQLite database")}
+	}
+	return nil
+}
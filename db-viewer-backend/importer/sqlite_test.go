@@ -0,0 +1,75 @@
+package importer
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"db-viewer/models"
+)
+
+func openTestDB(t *testing.T, dsn string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// TestImportSQLiteCraftedSchema imports a file whose schema text was edited
+// to carry statements after the CREATE: none of them may run
+func TestImportSQLiteCraftedSchema(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "upload.db")
+	evil := filepath.Join(dir, "evil.db")
+
+	upload := openTestDB(t, path)
+	for _, stmt := range []string{
+		"CREATE TABLE t (a)",
+		"INSERT INTO t VALUES (1), (2)",
+		"CREATE TABLE u (a)",
+		"CREATE VIEW v AS SELECT a FROM t",
+		"CREATE INDEX i ON t (a)",
+		"PRAGMA writable_schema = ON",
+		"UPDATE sqlite_master SET sql = 'CREATE TABLE u (a); ATTACH DATABASE ''" + evil + "'' AS evil' WHERE name = 'u'",
+		"UPDATE sqlite_master SET sql = 'CREATE VIEW v AS SELECT a FROM t; CREATE TABLE x (a)' WHERE name = 'v'",
+		"UPDATE sqlite_master SET sql = 'CREATE INDEX i ON t (a) -- fine' WHERE name = 'i'",
+		"PRAGMA writable_schema = OFF",
+	} {
+		if _, err := upload.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	upload.Close()
+
+	db := openTestDB(t, ":memory:")
+	var result models.UploadResult
+	if err := ImportSQLite(context.Background(), db, path, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Tables) != 1 || result.Tables[0].Table != "t" || result.Tables[0].RowsInserted != 2 {
+		t.Errorf("imported %+v, want table t with 2 rows", result.Tables)
+	}
+	if len(result.FailedStatements) != 2 {
+		t.Errorf("failed statements %+v, want u and v", result.FailedStatements)
+	}
+
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM pragma_database_list WHERE name = 'evil'").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Error("the statement after CREATE TABLE u attached a database")
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name IN ('u', 'x', 'i')").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("%d of u, x and i were created, want only index i", n)
+	}
+}
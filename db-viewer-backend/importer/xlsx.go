@@ -0,0 +1,103 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ErrEmptySheet is returned for a worksheet without a header row
+var ErrEmptySheet = errors.New("sheet is empty")
+
+// Workbook is an opened XLSX file whose sheets can be imported one by one
+type Workbook struct {
+	file *excelize.File
+}
+
+// OpenWorkbook parses an XLSX workbook
+func OpenWorkbook(r io.Reader) (*Workbook, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, &InputError{fmt.Errorf("failed to open workbook: %w", err)}
+	}
+	return &Workbook{file: f}, nil
+}
+
+// SheetNames lists the worksheets in workbook order
+func (w *Workbook) SheetNames() []string {
+	return w.file.GetSheetList()
+}
+
+// Sheet returns a Source over one worksheet. Its first non-empty row is the
+// header; blank rows are skipped and rows that omit trailing empty cells are
+// padded to the header width.
+func (w *Workbook) Sheet(name string) (Source, error) {
+	rows, err := w.file.Rows(name)
+	if err != nil {
+		return nil, err
+	}
+
+	src := &xlsxSource{rows: rows}
+	rec, err := src.Next()
+	if err == io.EOF {
+		rows.Close()
+		return nil, ErrEmptySheet
+	}
+	if err != nil {
+		rows.Close()
+		return nil, err
+	}
+
+	src.header = rec.Fields
+	return src, nil
+}
+
+// Close releases the workbook
+func (w *Workbook) Close() error {
+	return w.file.Close()
+}
+
+type xlsxSource struct {
+	rows   *excelize.Rows
+	header []string
+	line   int
+}
+
+func (s *xlsxSource) Header() []string {
+	return s.header
+}
+
+func (s *xlsxSource) Next() (Record, error) {
+	for s.rows.Next() {
+		s.line++
+		cols, err := s.rows.Columns()
+		if err != nil {
+			return Record{}, &RowError{Line: s.line, Reason: err.Error()}
+		}
+		if isBlank(cols) {
+			continue
+		}
+
+		for s.header != nil && len(cols) < len(s.header) {
+			cols = append(cols, "")
+		}
+		return Record{Line: s.line, Fields: cols}, nil
+	}
+
+	if err := s.rows.Error(); err != nil {
+		return Record{}, err
+	}
+	s.rows.Close()
+	return Record{}, io.EOF
+}
+
+func isBlank(fields []string) bool {
+	for _, f := range fields {
+		if f != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,209 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"db-viewer/models"
+)
+
+// Job states
+const (
+	StateRunning   = "running"
+	StateCompleted = "completed"
+	StateFailed    = "failed"
+	StateCanceled  = "canceled"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobFinished = errors.New("job has already finished")
+)
+
+// Task is the work a job runs in the background. The context is cancelled
+// when the job is; whatever the task returns becomes the job's result.
+type Task func(ctx context.Context, job *Job) (interface{}, error)
+
+// Job tracks a single background task
+type Job struct {
+	id         string
+	owner      any
+	totalBytes int64
+	createdAt  time.Time
+	cancel     context.CancelFunc
+
+	rowsProcessed atomic.Int64
+	rowsRejected  atomic.Int64
+	bytesRead     atomic.Int64
+
+	mu         sync.Mutex
+	state      string
+	err        string
+	result     interface{}
+	finishedAt time.Time
+}
+
+// ID returns the identifier clients poll the job by
+func (j *Job) ID() string {
+	return j.id
+}
+
+// ReportRows adds to the processed and rejected row counters
+func (j *Job) ReportRows(processed, rejected int64) {
+	j.rowsProcessed.Add(processed)
+	j.rowsRejected.Add(rejected)
+}
+
+// Reader wraps r so every byte read from it is counted towards the job
+func (j *Job) Reader(r io.Reader) io.Reader {
+	return &countingReader{r: r, n: &j.bytesRead}
+}
+
+// Status returns a snapshot of the job for the API
+func (j *Job) Status() models.JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	status := models.JobStatus{
+		ID:            j.id,
+		State:         j.state,
+		RowsProcessed: j.rowsProcessed.Load(),
+		RowsRejected:  j.rowsRejected.Load(),
+		BytesRead:     j.bytesRead.Load(),
+		TotalBytes:    j.totalBytes,
+		Error:         j.err,
+		Result:        j.result,
+		CreatedAt:     j.createdAt,
+	}
+	if !j.finishedAt.IsZero() {
+		finished := j.finishedAt
+		status.FinishedAt = &finished
+	}
+	return status
+}
+
+func (j *Job) finish(ctx context.Context, result interface{}, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.result = result
+	j.finishedAt = time.Now()
+	switch {
+	case ctx.Err() != nil:
+		j.state = StateCanceled
+		j.err = "job was canceled"
+	case err != nil:
+		j.state = StateFailed
+		j.err = err.Error()
+	default:
+		j.state = StateCompleted
+	}
+}
+
+// Manager runs jobs and keeps finished ones around for the retention period
+type Manager struct {
+	retention time.Duration
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// NewManager creates a manager that forgets finished jobs after retention
+func NewManager(retention time.Duration) *Manager {
+	return &Manager{retention: retention, jobs: make(map[string]*Job)}
+}
+
+// Start runs task in the background on behalf of owner, which must be
+// comparable; only the same owner can look the job up or cancel it.
+// totalBytes is the size of the input, if known, so clients can compute a
+// percentage from the bytes read.
+func (m *Manager) Start(owner any, totalBytes int64, task Task) *Job {
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &Job{
+		id:         newJobID(),
+		owner:      owner,
+		totalBytes: totalBytes,
+		createdAt:  time.Now(),
+		cancel:     cancel,
+		state:      StateRunning,
+	}
+
+	m.mu.Lock()
+	m.pruneLocked()
+	m.jobs[job.id] = job
+	m.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		result, err := task(ctx, job)
+		job.finish(ctx, result, err)
+	}()
+
+	return job
+}
+
+// Get looks a job of owner up by ID; the jobs of other owners are not found
+func (m *Manager) Get(owner any, id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok || job.owner != owner {
+		return nil, ErrJobNotFound
+	}
+	return job, nil
+}
+
+// Cancel stops a running job of owner
+func (m *Manager) Cancel(owner any, id string) error {
+	job, err := m.Get(owner, id)
+	if err != nil {
+		return err
+	}
+
+	job.mu.Lock()
+	running := job.state == StateRunning
+	job.mu.Unlock()
+	if !running {
+		return ErrJobFinished
+	}
+
+	job.cancel()
+	return nil
+}
+
+// pruneLocked drops finished jobs older than the retention period; mu must be held
+func (m *Manager) pruneLocked() {
+	cutoff := time.Now().Add(-m.retention)
+	for id, job := range m.jobs {
+		job.mu.Lock()
+		expired := !job.finishedAt.IsZero() && job.finishedAt.Before(cutoff)
+		job.mu.Unlock()
+		if expired {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 
+	"db-viewer/config"   // REPLACE WITH YOUR MODULE NAME
 	"db-viewer/database" // REPLACE WITH YOUR MODULE NAME
 	"db-viewer/handlers" // REPLACE WITH YOUR MODULE NAME
 
@@ -22,28 +23,56 @@ import (
 // @BasePath        /
 func main() {
 	// 1. Initialize Database
-	database.InitDB()
-	defer database.DB.Close()
+	cfg := config.Load()
+	database.InitDB(cfg)
+	if err := handlers.Configure(cfg); err != nil {
+		log.Fatal(err)
+	}
+	defer database.CloseDB()
 
 	// 2. Setup Router
 	r := gin.Default()
-	r.Use(cors.Default())
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowAllOrigins = true
+	corsConfig.AddAllowHeaders("X-Workspace", "X-Session-ID", "X-Query-Role")
+	corsConfig.AddExposeHeaders("X-Session-ID")
+	r.Use(cors.New(corsConfig))
 
 	// 3. Swagger
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	// 4. Register Routes (Pointing to the handlers package)
-	r.POST("/upload", handlers.HandleFileUpload)
-	r.POST("/query", handlers.HandleQuery)
-	r.GET("/db-info", handlers.HandleGetDBInfo)
-	r.POST("/alter-table", handlers.HandleAddColumn)
-	r.GET("/export/:tableName", handlers.HandleExportCSV)
-	r.POST("/update-cell", handlers.HandleUpdateCell)
-	r.GET("/table-data/:tableName", handlers.HandleGetTableData)
-	r.POST("/insert-row", handlers.HandleInsertRow)
-	r.POST("/delete-row", handlers.HandleDeleteRow)
-
-	fmt.Println("Application running on http://localhost:8080")
+	// 4. Sessions (memory storage mode) and workspaces (file storage mode)
+	r.DELETE("/session", handlers.HandleEndSession)
+	r.GET("/workspaces", handlers.HandleListWorkspaces)
+	r.POST("/workspaces", handlers.HandleCreateWorkspace)
+	r.POST("/workspaces/:name/open", handlers.HandleOpenWorkspace)
+	r.PUT("/workspaces/:name", handlers.HandleRenameWorkspace)
+	r.DELETE("/workspaces/:name", handlers.HandleDeleteWorkspace)
+
+	// 5. Register Routes (Pointing to the handlers package)
+	api := r.Group("/", handlers.UseDatabase())
+	api.POST("/upload", handlers.HandleFileUpload)
+	api.POST("/query", handlers.HandleQuery)
+	api.POST("/query/:id/cancel", handlers.HandleCancelQuery)
+	api.POST("/query/explain", handlers.HandleExplainQuery)
+	api.GET("/db-info", handlers.HandleGetDBInfo)
+	api.GET("/schema", handlers.HandleGetSchema)
+	api.POST("/alter-table", handlers.HandleAddColumn)
+	api.GET("/export/:tableName", handlers.HandleExportCSV)
+	api.POST("/update-cell", handlers.HandleUpdateCell)
+	api.GET("/table-data/:tableName", handlers.HandleGetTableData)
+	api.POST("/insert-row", handlers.HandleInsertRow)
+	api.POST("/delete-row", handlers.HandleDeleteRow)
+	api.POST("/constraints/:tableName", handlers.HandleApplyConstraints)
+	api.GET("/relationships/suggest", handlers.HandleSuggestRelationships)
+	api.POST("/relationships/suggest/accept", handlers.HandleAcceptRelationship)
+	api.POST("/relationships/suggest/reject", handlers.HandleRejectRelationship)
+
+	// 6. Background import jobs, visible only to the session or workspace that started them
+	api.GET("/jobs/:id", handlers.HandleGetJob)
+	api.POST("/jobs/:id/cancel", handlers.HandleCancelJob)
+
+	fmt.Printf("Application running on http://localhost:8080 (storage: %s)\n", cfg.Storage)
 	if err := r.Run(":8080"); err != nil {
 		log.Fatal(err)
 	}
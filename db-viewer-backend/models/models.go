@@ -1,23 +1,226 @@
 package models
 
-// ColumnInfo represents metadata for a single column
+import (
+	"encoding/json"
+	"time"
+)
+
+// ColumnInfo represents metadata for a single column. Default is the SQL
+// text of the column's default, when it has one.
 type ColumnInfo struct {
-	Name string `json:"name"`
-	Type string `json:"type"`
+	Name    string  `json:"name"`
+	Type    string  `json:"type"`
+	NotNull bool    `json:"not_null"`
+	Default *string `json:"default,omitempty"`
+}
+
+// IndexInfo describes an index. Origin is "c" for CREATE INDEX, "u" for a
+// UNIQUE constraint and "pk" for a PRIMARY KEY.
+type IndexInfo struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Unique  bool     `json:"unique"`
+	Origin  string   `json:"origin"`
+}
+
+// TableSchema describes a table without its rows; Sample holds the first
+// rows when a preview was asked for
+type TableSchema struct {
+	Name       string                   `json:"name"`
+	Columns    []ColumnInfo             `json:"columns"`
+	PrimaryKey []string                 `json:"primary_key"`
+	Indexes    []IndexInfo              `json:"indexes"`
+	RowCount   int64                    `json:"row_count"`
+	Sample     []map[string]interface{} `json:"sample,omitempty"`
 }
 
 // TableInfo represents the full schema and data of a table
 type TableInfo struct {
-	Name    string                   `json:"name"`
-	Columns []ColumnInfo             `json:"columns"`
-	Rows    []map[string]interface{} `json:"rows"`
+	Name    string       `json:"name"`
+	Columns []ColumnInfo `json:"columns"`
+	// PrimaryKey lists the key columns, or "rowid" for a table without a
+	// declared primary key (its rows then carry a rowid field)
+	PrimaryKey []string                 `json:"primary_key"`
+	Rows       []map[string]interface{} `json:"rows"`
+}
+
+// Filter is a condition on the rows of a table: either Column compared
+// with Value by Op (eq, ne, lt, lte, gt, gte, like, in, is_null, not_null),
+// or all of And, or any of Or
+type Filter struct {
+	Column string      `json:"column,omitempty"`
+	Op     string      `json:"op,omitempty"`
+	Value  interface{} `json:"value,omitempty"`
+	And    []Filter    `json:"and,omitempty"`
+	Or     []Filter    `json:"or,omitempty"`
+}
+
+// TablePage is one page of a table's rows. Total counts the rows matching
+// the filter; NextCursor, set while rows remain, fetches the next page.
+type TablePage struct {
+	Columns    []string                 `json:"columns"`
+	Rows       []map[string]interface{} `json:"rows"`
+	Total      int64                    `json:"total"`
+	Limit      int                      `json:"limit"`
+	Offset     int                      `json:"offset"`
+	NextCursor string                   `json:"next_cursor,omitempty"`
+}
+
+// QueryColumn describes a column of a query result. Type is the declared
+// type of the table column a value comes from, empty for expressions;
+// Nullable is left out when the driver cannot tell.
+type QueryColumn struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Nullable *bool  `json:"nullable,omitempty"`
+}
+
+// QueryResult is the outcome of a statement run through /query. Rows hold
+// values in the order of Columns. RowsAffected is only set for statements
+// that return no columns.
+type QueryResult struct {
+	QueryID      string          `json:"query_id,omitempty"`
+	Columns      []QueryColumn   `json:"columns"`
+	Rows         [][]interface{} `json:"rows"`
+	RowCount     int             `json:"row_count"`
+	Truncated    bool            `json:"truncated"`
+	RowsAffected *int64          `json:"rows_affected,omitempty"`
+	ElapsedMs    float64         `json:"elapsed_ms"`
+}
+
+// StatementResult is the outcome of one statement of a script run through
+// /query. Index counts from 0 and Line from 1; Skipped marks the script's own
+// BEGIN/COMMIT when it runs inside a transaction.
+type StatementResult struct {
+	Index   int    `json:"index"`
+	Line    int    `json:"line"`
+	SQL     string `json:"sql"`
+	Skipped bool   `json:"skipped,omitempty"`
+	QueryResult
+}
+
+// ScriptResult is the outcome of a script run through /query
+type ScriptResult struct {
+	QueryID     string            `json:"query_id"`
+	Transaction bool              `json:"transaction"`
+	Statements  []StatementResult `json:"statements"`
+	ElapsedMs   float64           `json:"elapsed_ms"`
 }
 
-// Relationship represents a foreign key link
+// PlanNode is one step of a query plan as EXPLAIN QUERY PLAN reports it.
+// Op is scan, search, temp_btree, subquery, coroutine, materialize,
+// compound, multi_index, bloom_filter, constant_row or other. Name is the
+// table, alias or subquery the step reads as SQLite prints it, and Table
+// the table behind it when there is one.
+type PlanNode struct {
+	ID         int        `json:"id"`
+	Detail     string     `json:"detail"`
+	Op         string     `json:"op"`
+	Name       string     `json:"name,omitempty"`
+	Table      string     `json:"table,omitempty"`
+	Index      string     `json:"index,omitempty"`
+	Covering   bool       `json:"covering,omitempty"`
+	Automatic  bool       `json:"automatic,omitempty"`
+	Constraint string     `json:"constraint,omitempty"`
+	Purpose    string     `json:"purpose,omitempty"`
+	Correlated bool       `json:"correlated,omitempty"`
+	Children   []PlanNode `json:"children"`
+}
+
+// PlanWarning points at a step of a query plan that is likely to be slow.
+// Kind is full_scan, automatic_index, temp_btree or correlated_subquery.
+type PlanWarning struct {
+	Kind    string `json:"kind"`
+	NodeID  int    `json:"node_id"`
+	Table   string `json:"table,omitempty"`
+	Message string `json:"message"`
+}
+
+// QueryPlan is the parsed EXPLAIN QUERY PLAN of a statement
+type QueryPlan struct {
+	Plan     []PlanNode    `json:"plan"`
+	Warnings []PlanWarning `json:"warnings"`
+}
+
+// ExplainRequest is the payload for explaining a statement. Params bind its
+// placeholders as in QueryRequest.
+type ExplainRequest struct {
+	Query  string          `json:"query" example:"SELECT * FROM orders WHERE customer_id = 7"`
+	Params json.RawMessage `json:"params,omitempty" swaggertype:"object"`
+}
+
+// Relationship represents a foreign key link. Kind is "declared" for a
+// FOREIGN KEY constraint and "inferred" for a link guessed from column
+// names; only declared links have OnDelete and OnUpdate actions.
 type Relationship struct {
 	SourceTable  string `json:"source_table"`
 	TargetTable  string `json:"target_table"`
 	SourceColumn string `json:"source_column"`
+	TargetColumn string `json:"target_column,omitempty"`
+	Kind         string `json:"kind"`
+	OnDelete     string `json:"on_delete,omitempty"`
+	OnUpdate     string `json:"on_update,omitempty"`
+}
+
+// RelationshipSuggestion is a foreign key proposed from the values of two
+// columns: Inclusion is the share of the source's distinct values found in
+// the target, Confidence weighs it with the names and the target's key.
+// Status is "pending", "accepted" or "rejected".
+type RelationshipSuggestion struct {
+	SourceTable    string  `json:"source_table"`
+	SourceColumn   string  `json:"source_column"`
+	TargetTable    string  `json:"target_table"`
+	TargetColumn   string  `json:"target_column"`
+	Confidence     float64 `json:"confidence"`
+	Inclusion      float64 `json:"inclusion"`
+	SourceDistinct int64   `json:"source_distinct"`
+	TargetDistinct int64   `json:"target_distinct"`
+	Status         string  `json:"status"`
+}
+
+// RelationshipDecision is the payload for accepting or rejecting a
+// suggested relationship
+type RelationshipDecision struct {
+	SourceTable  string `json:"source_table"`
+	SourceColumn string `json:"source_column"`
+	TargetTable  string `json:"target_table"`
+	TargetColumn string `json:"target_column"`
+}
+
+// ForeignKey is a single-column FOREIGN KEY ... REFERENCES constraint
+type ForeignKey struct {
+	Column       string `json:"column"`
+	TargetTable  string `json:"target_table"`
+	TargetColumn string `json:"target_column,omitempty"`
+}
+
+// ConstraintViolation reports rows that break a PRIMARY KEY or FOREIGN KEY
+// constraint. Duplicate keys are reported once per key value with a count;
+// foreign key violations once per row.
+type ConstraintViolation struct {
+	Table       string        `json:"table"`
+	Kind        string        `json:"kind"`
+	RowID       int64         `json:"rowid,omitempty"`
+	Columns     []string      `json:"columns"`
+	Values      []interface{} `json:"values"`
+	TargetTable string        `json:"target_table,omitempty"`
+	Count       int64         `json:"count,omitempty"`
+}
+
+// ConstraintsRequest is the payload for declaring constraints on a table.
+// Omitted fields are filled in from the naming conventions (an "id" column
+// as primary key, <table>_id columns as foreign keys).
+type ConstraintsRequest struct {
+	PrimaryKey  []string     `json:"primary_key,omitempty"`
+	ForeignKeys []ForeignKey `json:"foreign_keys,omitempty"`
+}
+
+// ConstraintsResult describes the constraints a table was rebuilt with
+type ConstraintsResult struct {
+	Table       string                `json:"table"`
+	PrimaryKey  []string              `json:"primary_key"`
+	ForeignKeys []ForeignKey          `json:"foreign_keys"`
+	Violations  []ConstraintViolation `json:"violations"`
 }
 
 // AddColumnRequest is the payload for adding a column
@@ -27,26 +230,155 @@ type AddColumnRequest struct {
 	ColumnType string `json:"column_type"`
 }
 
-// UpdateCellRequest is the payload for editing a cell
+// UpdateCellRequest is the payload for editing a cell. The row is picked by
+// Key, which maps every primary key column to its value; RecordID is
+// accepted instead for tables with a single-column key.
 type UpdateCellRequest struct {
-	TableName  string `json:"table_name"`
-	RecordID   string `json:"record_id"`
-	ColumnName string `json:"column_name"`
-	NewValue   string `json:"new_value"`
+	TableName  string                 `json:"table_name"`
+	Key        map[string]interface{} `json:"key,omitempty"`
+	RecordID   string                 `json:"record_id"`
+	ColumnName string                 `json:"column_name"`
+	NewValue   string                 `json:"new_value"`
 }
 
 // QueryRequest defines the body for SQL queries
 type QueryRequest struct {
 	Query string `json:"query" example:"SELECT * FROM users"`
+	// QueryID names the query so it can be canceled while it runs; one is generated if empty
+	QueryID string `json:"query_id,omitempty" example:"3f2a9c1e"`
+	// TimeoutMs overrides the server's default query timeout, up to its maximum
+	TimeoutMs int `json:"timeout_ms,omitempty" example:"5000"`
+	// MaxRows lowers the number of rows returned; the server's own cap still applies unless streaming
+	MaxRows int `json:"max_rows,omitempty" example:"500"`
+	// Stream writes the result as NDJSON, one row per line, as rows are read
+	Stream bool `json:"stream,omitempty"`
+	// Script runs every statement of Query instead of only the first
+	Script bool `json:"script,omitempty"`
+	// Transaction runs a script in a single transaction that a failing statement rolls back
+	Transaction bool `json:"transaction,omitempty"`
+	// Params binds placeholders: an array by position (?, ?NNN), an object by
+	// name (:name, @name, $name). Values are JSON scalars or typed values such
+	// as {"type": "blob", "value": "aGk="}.
+	Params json.RawMessage `json:"params,omitempty" swaggertype:"object"`
 }
 
-// InsertRowRequest is the payload for creating an empty row
+// InsertRowRequest is the payload for creating a row. Columns left out of
+// Values get their defaults; a single integer key is generated if missing.
 type InsertRowRequest struct {
-	TableName string `json:"table_name"`
+	TableName string                 `json:"table_name"`
+	Values    map[string]interface{} `json:"values,omitempty"`
 }
 
-// DeleteRowRequest is the payload for deleting a row
+// DeleteRowRequest is the payload for deleting a row, picked like in UpdateCellRequest
 type DeleteRowRequest struct {
-	TableName string `json:"table_name"`
-	RecordID  string `json:"record_id"`
+	TableName string                 `json:"table_name"`
+	Key       map[string]interface{} `json:"key,omitempty"`
+	RecordID  string                 `json:"record_id"`
+}
+
+// RejectedRow describes an input line that could not be imported
+type RejectedRow struct {
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+}
+
+// ImportResult summarizes a finished import into one table
+type ImportResult struct {
+	Table        string        `json:"table"`
+	Sheet        string        `json:"sheet,omitempty"`
+	Mode         string        `json:"mode,omitempty"`
+	Columns      []string      `json:"columns"`
+	Types        []string      `json:"types"`
+	Schema       []ColumnInfo  `json:"schema,omitempty"`
+	PrimaryKey   []string      `json:"primary_key,omitempty"`
+	ForeignKeys  []ForeignKey  `json:"foreign_keys,omitempty"`
+	RowsInserted int64         `json:"rows_inserted"`
+	RowsUpdated  int64         `json:"rows_updated,omitempty"`
+	RowsRejected int64         `json:"rows_rejected"`
+	Rejected     []RejectedRow `json:"rejected"`
+	Warnings     []string      `json:"warnings,omitempty"`
+	// Preview holds the first parsed rows of a dry run, in column order
+	Preview [][]interface{} `json:"preview,omitempty"`
+	// Dialect is how a CSV upload was read
+	Dialect *CSVDialect `json:"dialect,omitempty"`
+	// Error is why the import of a worksheet stopped partway; the rows of
+	// batches written before it are kept
+	Error string `json:"error,omitempty"`
+}
+
+// CSVDialect describes how a CSV file was parsed
+type CSVDialect struct {
+	Delimiter  string `json:"delimiter"`
+	Encoding   string `json:"encoding"`
+	LazyQuotes bool   `json:"lazy_quotes"`
+}
+
+// SchemaOverride adjusts the inferred schema of an upload. Columns are keyed
+// by their header in the file.
+type SchemaOverride struct {
+	Columns    map[string]ColumnOverride `json:"columns"`
+	PrimaryKey []string                  `json:"primary_key"`
+}
+
+// ColumnOverride pins the type of, renames or skips one uploaded column
+type ColumnOverride struct {
+	Type   string `json:"type,omitempty"`
+	Rename string `json:"rename,omitempty"`
+	Skip   bool   `json:"skip,omitempty"`
+}
+
+// SkippedSource names part of an upload (e.g. a worksheet) that was not imported
+type SkippedSource struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// StatementError reports a statement of a SQL script that failed
+type StatementError struct {
+	Index     int    `json:"index"`
+	Line      int    `json:"line"`
+	Statement string `json:"statement"`
+	Error     string `json:"error"`
+}
+
+// UploadResult summarizes every table created from one uploaded file
+type UploadResult struct {
+	Tables           []ImportResult   `json:"tables"`
+	Skipped          []SkippedSource  `json:"skipped"`
+	FailedStatements []StatementError `json:"failed_statements,omitempty"`
+	// Violations lists rows of copied databases and scripts that break
+	// their foreign keys
+	Violations []ConstraintViolation `json:"violations,omitempty"`
+}
+
+// JobStatus reports the progress of a background job
+type JobStatus struct {
+	ID            string      `json:"id"`
+	State         string      `json:"state"`
+	RowsProcessed int64       `json:"rows_processed"`
+	RowsRejected  int64       `json:"rows_rejected"`
+	BytesRead     int64       `json:"bytes_read"`
+	TotalBytes    int64       `json:"total_bytes"`
+	Error         string      `json:"error,omitempty"`
+	Result        interface{} `json:"result,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+	FinishedAt    *time.Time  `json:"finished_at,omitempty"`
+}
+
+// WorkspaceInfo describes a file-backed workspace
+type WorkspaceInfo struct {
+	Name       string    `json:"name"`
+	SizeBytes  int64     `json:"size_bytes"`
+	ModifiedAt time.Time `json:"modified_at"`
+	Open       bool      `json:"open"`
+}
+
+// CreateWorkspaceRequest is the payload for creating a workspace
+type CreateWorkspaceRequest struct {
+	Name string `json:"name" example:"sales"`
+}
+
+// RenameWorkspaceRequest is the payload for renaming a workspace
+type RenameWorkspaceRequest struct {
+	NewName string `json:"new_name" example:"sales_2024"`
 }
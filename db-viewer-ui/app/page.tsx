@@ -16,7 +16,7 @@ import { DataEditor } from "@/components/DataEditor";
 
 export default function Home() {
     const [editingTable, setEditingTable] = useState<string | null>(null);
-    const { query, setQuery, results, error, runQuery } = useQuery();
+    const { query, setQuery, result, error, isLoading, runQuery, cancelQuery } = useQuery();
     const [theme, setTheme] = useState<'dark' | 'light' | 'system'>('dark');
     const [isSidebarOpen, setIsSidebarOpen] = useState(true);
     const [errorModal, setErrorModal] = useState<{isOpen: boolean, message: string}>({
@@ -85,15 +85,18 @@ export default function Home() {
                         query={query} 
                         setQuery={setQuery} 
                         runQuery={runQuery} 
+                        cancelQuery={cancelQuery}
+                        isLoading={isLoading}
                     />
 
-                    <ResultsTable data={results} error={error} />
+                    <ResultsTable result={result} error={error} />
                 </div>
             </div>
             {/* --- DATA EDITOR MODAL --- */}
             {editingTable && (
                 <DataEditor 
                     tableName={editingTable} 
+                    primaryKey={nodes.find(n => n.id === editingTable)?.data.primaryKey}
                     onClose={() => setEditingTable(null)} 
                 />
              )}
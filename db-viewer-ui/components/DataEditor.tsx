@@ -1,16 +1,25 @@
 import React, { useState, useEffect } from 'react';
 import { X, Loader2, Plus, Trash2, AlertTriangle } from 'lucide-react'; // <--- Import AlertTriangle
-import { dbService } from '@/services/api';
+import { dbService, RowKey } from '@/services/api';
 
 interface DataEditorProps {
     tableName: string | null;
+    primaryKey?: string[];
     onClose: () => void;
 }
 
-export const DataEditor = ({ tableName, onClose }: DataEditorProps) => {
+export const DataEditor = ({ tableName, primaryKey = ['id'], onClose }: DataEditorProps) => {
+    // Rows are addressed by their primary key values
+    const keyOf = (row: any): RowKey =>
+        Object.fromEntries(primaryKey.map(col => [col, row[col]]));
+    const rowIdOf = (row: any) => JSON.stringify(primaryKey.map(col => row[col]));
+
     const [data, setData] = useState<any[]>([]);
     const [columns, setColumns] = useState<string[]>([]);
     const [loading, setLoading] = useState(false);
+    const [total, setTotal] = useState(0);
+    const [nextCursor, setNextCursor] = useState<string | undefined>();
+    const [loadingMore, setLoadingMore] = useState(false);
     
     const [editingCell, setEditingCell] = useState<{rowId: any, col: string} | null>(null);
     const [editValue, setEditValue] = useState("");
@@ -31,11 +40,11 @@ export const DataEditor = ({ tableName, onClose }: DataEditorProps) => {
         if (!tableName) return;
         setLoading(true);
         try {
-            const rows = await dbService.getTableData(tableName);
-            setData(rows || []);
-            if (rows && rows.length > 0) {
-                setColumns(Object.keys(rows[0]));
-            }
+            const page = await dbService.getTableData(tableName);
+            setData(page.rows || []);
+            setColumns(page.columns || []);
+            setTotal(page.total);
+            setNextCursor(page.next_cursor);
         } catch (e) {
             console.error(e);
         } finally {
@@ -43,6 +52,22 @@ export const DataEditor = ({ tableName, onClose }: DataEditorProps) => {
         }
     };
 
+    // Fetch the page after the last loaded row
+    const loadMore = async () => {
+        if (!tableName || !nextCursor) return;
+        setLoadingMore(true);
+        try {
+            const page = await dbService.getTableData(tableName, { cursor: nextCursor });
+            setData(prev => [...prev, ...(page.rows || [])]);
+            setTotal(page.total);
+            setNextCursor(page.next_cursor);
+        } catch (e) {
+            console.error(e);
+        } finally {
+            setLoadingMore(false);
+        }
+    };
+
     // Trigger the Delete Modal
     const promptDeleteRow = (id: any) => {
         setDeleteConfirm({ isOpen: true, rowId: id });
@@ -54,11 +79,13 @@ export const DataEditor = ({ tableName, onClose }: DataEditorProps) => {
         if (!id) return;
 
         // Optimistic UI Update
-        setData(prev => prev.filter(row => row.id !== id));
+        const row = data.find(r => rowIdOf(r) === id);
+        if (!row) return;
+        setData(prev => prev.filter(r => rowIdOf(r) !== id));
         setDeleteConfirm({ isOpen: false, rowId: null }); // Close modal immediately
 
         try {
-            await dbService.deleteRow(tableName!, id);
+            await dbService.deleteRow(tableName!, keyOf(row));
         } catch (err) {
             alert("Failed to delete row");
             loadData();
@@ -69,16 +96,16 @@ export const DataEditor = ({ tableName, onClose }: DataEditorProps) => {
         if (!editingCell || !tableName) return;
 
         const newData = [...data];
-        const rowIndex = newData.findIndex(r => r.id == editingCell.rowId);
-        if (rowIndex !== -1) {
-            newData[rowIndex][editingCell.col] = editValue;
-            setData(newData);
-        }
+        const rowIndex = newData.findIndex(r => rowIdOf(r) === editingCell.rowId);
+        if (rowIndex === -1) return;
+        const rowKey = keyOf(newData[rowIndex]);
+        newData[rowIndex][editingCell.col] = editValue;
+        setData(newData);
 
         try {
             await dbService.updateCell({
                 tableName,
-                recordId: editingCell.rowId,
+                rowKey,
                 columnName: editingCell.col,
                 newValue: editValue
             });
@@ -141,14 +168,14 @@ export const DataEditor = ({ tableName, onClose }: DataEditorProps) => {
                                 {data.map((row, i) => (
                                     <tr key={i} className="hover:bg-slate-900 transition-colors group">
                                         {columns.map(col => {
-                                            const isEditing = editingCell?.rowId === row.id && editingCell?.col === col;
+                                            const isEditing = editingCell?.rowId === rowIdOf(row) && editingCell?.col === col;
                                             return (
                                                 <td 
                                                     key={col} 
                                                     className="p-2 border-b border-slate-800 text-slate-300 cursor-pointer hover:bg-slate-800"
                                                     onClick={() => {
-                                                        if (col !== 'id' && !isEditing) {
-                                                            setEditingCell({ rowId: row.id, col });
+                                                        if (!primaryKey.includes(col) && !isEditing) {
+                                                            setEditingCell({ rowId: rowIdOf(row), col });
                                                             setEditValue(row[col] || "");
                                                         }
                                                     }}
@@ -174,7 +201,7 @@ export const DataEditor = ({ tableName, onClose }: DataEditorProps) => {
                                             <button 
                                                 onClick={(e) => {
                                                     e.stopPropagation(); 
-                                                    promptDeleteRow(row.id); // <--- Changed to prompt
+                                                    promptDeleteRow(rowIdOf(row)); // <--- Changed to prompt
                                                 }}
                                                 className="text-slate-600 hover:text-red-500 hover:bg-red-500/10 p-1.5 rounded transition-all opacity-0 group-hover:opacity-100"
                                                 title="Delete Row"
@@ -187,12 +214,22 @@ export const DataEditor = ({ tableName, onClose }: DataEditorProps) => {
                             </tbody>
                         </table>
                     )}
+                    {!loading && nextCursor && (
+                        <button
+                            onClick={loadMore}
+                            disabled={loadingMore}
+                            className="mt-3 w-full flex justify-center items-center gap-2 text-sm text-slate-400 hover:text-white py-2 rounded border border-slate-800 hover:bg-slate-900 transition-colors"
+                        >
+                            {loadingMore ? <Loader2 size={14} className="animate-spin" /> : null}
+                            Load more
+                        </button>
+                    )}
                 </div>
                 
                 {/* Footer */}
                 <div className="p-3 bg-slate-800 border-t border-slate-700 rounded-b-lg flex justify-between items-center">
                     <span className="text-xs text-slate-500">
-                        Click cells to edit. Enter to save.
+                        Click cells to edit. Enter to save. Showing {data.length} of {total} rows.
                     </span>
                     <button 
                         onClick={handleAddRow}
@@ -17,7 +17,7 @@ export const Header = ({ onUpload, onRefresh, isUploading }: HeaderProps) => {
                     type="file"
                     id="csvUpload"
                     className="hidden"
-                    accept=".csv"
+                    accept=".csv,.xlsx,.json,.ndjson,.jsonl,.sqlite,.sqlite3,.db,.sql"
                     onChange={(e) => e.target.files?.[0] && onUpload(e.target.files[0])}
                 />
                 <label
@@ -1,16 +1,35 @@
 import { AlertCircle } from 'lucide-react'; // Optional: Add icon for error
+import { QueryResponse } from '@/types';
 
 interface ResultsTableProps {
-    data: any[];
+    result: QueryResponse | null;
     error: string;
 }
 
-export const ResultsTable = ({ data, error }: ResultsTableProps) => {
+const formatValue = (val: any) => {
+    if (val === null) return <span className="text-slate-600 italic">NULL</span>;
+    if (typeof val === 'boolean') return val ? 'true' : 'false';
+    return val;
+};
+
+export const ResultsTable = ({ result, error }: ResultsTableProps) => {
+    const hasRows = !!result && result.columns.length > 0;
+
     return (
         // CHANGED: 'h-1/2' -> 'flex-1'
         <div className="flex-1 flex flex-col overflow-hidden bg-slate-950 min-h-0">
-            <div className="p-2 bg-slate-900 border-b border-slate-800">
+            <div className="p-2 bg-slate-900 border-b border-slate-800 flex justify-between items-center">
                 <span className="text-xs font-bold text-slate-400">RESULTS</span>
+                {!error && result && (
+                    <span className={`text-xs ${result.truncated ? 'text-amber-400' : 'text-slate-500'}`}>
+                        {result.truncated
+                            ? `Showing the first ${result.row_count} rows`
+                            : hasRows
+                                ? `${result.row_count} rows`
+                                : `${result.rows_affected ?? 0} rows affected`}
+                        {` · ${result.elapsed_ms.toFixed(1)} ms`}
+                    </span>
+                )}
             </div>
             <div className="flex-1 overflow-auto p-4">
                 {error && (
@@ -18,23 +37,27 @@ export const ResultsTable = ({ data, error }: ResultsTableProps) => {
                         <AlertCircle size={16} /> Error: {error}
                     </div>
                 )}
-                {!error && data.length > 0 ? (
+                {!error && result && hasRows ? (
                     <table className="w-full text-left text-sm border-collapse">
                         <thead>
                             <tr>
-                                {Object.keys(data[0]).map((key) => (
-                                    <th key={key} className="border-b border-slate-700 p-2 text-slate-400 font-medium sticky top-0 bg-slate-950">
-                                        {key}
+                                {result.columns.map((col, i) => (
+                                    <th key={i} className="border-b border-slate-700 p-2 text-slate-400 font-medium sticky top-0 bg-slate-950">
+                                        {col.name}
+                                        {col.type && <span className="ml-2 text-[10px] text-slate-600">{col.type}</span>}
                                     </th>
                                 ))}
                             </tr>
                         </thead>
                         <tbody>
-                            {data.map((row, i) => (
+                            {result.rows.map((row, i) => (
                                 <tr key={i} className="hover:bg-slate-900/50 transition-colors">
-                                    {Object.values(row).map((val: any, j) => (
-                                        <td key={j} className="border-b border-slate-800 p-2 text-slate-300">
-                                            {val}
+                                    {row.map((val: any, j) => (
+                                        <td
+                                            key={j}
+                                            className={`border-b border-slate-800 p-2 text-slate-300 ${typeof val === 'number' ? 'text-right font-mono' : ''}`}
+                                        >
+                                            {formatValue(val)}
                                         </td>
                                     ))}
                                 </tr>
@@ -42,9 +65,9 @@ export const ResultsTable = ({ data, error }: ResultsTableProps) => {
                         </tbody>
                     </table>
                 ) : (
-                    !error && <div className="text-slate-600 text-center mt-10">Run a query to see results</div>
+                    !error && !result && <div className="text-slate-600 text-center mt-10">Run a query to see results</div>
                 )}
             </div>
         </div>
     );
-};
\ No newline at end of file
+};
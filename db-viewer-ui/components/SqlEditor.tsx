@@ -2,15 +2,17 @@
 
 import React, { useState } from 'react';
 import Editor from "@monaco-editor/react";
-import { Play, ChevronDown, ChevronUp } from "lucide-react";
+import { Play, Square, ChevronDown, ChevronUp } from "lucide-react";
 
 interface SqlEditorProps {
     query: string;
     setQuery: (query: string) => void;
     runQuery: () => void;
+    cancelQuery: () => void;
+    isLoading: boolean;
 }
 
-export const SqlEditor = ({ query, setQuery, runQuery }: SqlEditorProps) => {
+export const SqlEditor = ({ query, setQuery, runQuery, cancelQuery, isLoading }: SqlEditorProps) => {
     const [isCollapsed, setIsCollapsed] = useState(false);
 
     return (
@@ -29,15 +31,27 @@ export const SqlEditor = ({ query, setQuery, runQuery }: SqlEditorProps) => {
                     <span className="text-xs font-bold">SQL EDITOR</span>
                 </div>
                 
-                <button
-                    onClick={runQuery}
-                    disabled={isCollapsed}
-                    className={`flex items-center gap-2 bg-green-600 hover:bg-green-700 text-white px-3 py-1 rounded text-xs transition-all duration-200 ${
-                        isCollapsed ? 'opacity-0 pointer-events-none' : 'opacity-100'
-                    }`}
-                >
-                    <Play size={12} /> Run Query
-                </button>
+                {isLoading ? (
+                    <button
+                        onClick={cancelQuery}
+                        disabled={isCollapsed}
+                        className={`flex items-center gap-2 bg-red-600 hover:bg-red-700 text-white px-3 py-1 rounded text-xs transition-all duration-200 ${
+                            isCollapsed ? 'opacity-0 pointer-events-none' : 'opacity-100'
+                        }`}
+                    >
+                        <Square size={12} /> Cancel
+                    </button>
+                ) : (
+                    <button
+                        onClick={runQuery}
+                        disabled={isCollapsed}
+                        className={`flex items-center gap-2 bg-green-600 hover:bg-green-700 text-white px-3 py-1 rounded text-xs transition-all duration-200 ${
+                            isCollapsed ? 'opacity-0 pointer-events-none' : 'opacity-100'
+                        }`}
+                    >
+                        <Play size={12} /> Run Query
+                    </button>
+                )}
             </div>
 
             {/* Monaco Editor */}
@@ -11,6 +11,7 @@ interface ColumnData {
 interface TableNodeData {
   label: string;
   columns: ColumnData[];
+  rowCount?: number;
   onRefresh: () => void;
   onEdit: (tableName: string) => void;
 }
@@ -43,9 +44,13 @@ const TableNode = ({ data }: { data: TableNodeData }) => {
     }
   };
 
-  const handleDownload = (e: React.MouseEvent) => {
+  const handleDownload = async (e: React.MouseEvent) => {
       e.stopPropagation();
-      window.open(dbService.getDownloadUrl(data.label), '_blank');
+      try {
+          await dbService.downloadTable(data.label);
+      } catch (err) {
+          alert("Failed to download table");
+      }
   };
 
   return (
@@ -56,7 +61,7 @@ const TableNode = ({ data }: { data: TableNodeData }) => {
       <div className="bg-indigo-600 dark:bg-indigo-700 px-2 py-1.5 flex items-center justify-between">
         <div className="flex items-center gap-1.5 overflow-hidden">
           <Database size={10} className="text-white shrink-0" />
-          <span className="font-bold text-white text-[10px] truncate leading-tight" title={data.label}>
+          <span className="font-bold text-white text-[10px] truncate leading-tight" title={data.rowCount === undefined ? data.label : `${data.label} (${data.rowCount} rows)`}>
             {data.label}
           </span>
         </div>
@@ -1,32 +1,54 @@
-import { useState } from 'react';
+import { useRef, useState } from 'react';
 import { dbService } from '@/services/api';
+import { QueryResponse } from '@/types';
+
+const newQueryId = () => Math.random().toString(16).slice(2) + Date.now().toString(16);
 
 export const useQuery = () => {
     const [query, setQuery] = useState("SELECT * FROM users LIMIT 10;");
-    const [results, setResults] = useState<any[]>([]);
+    const [result, setResult] = useState<QueryResponse | null>(null);
     const [error, setError] = useState("");
     const [isLoading, setIsLoading] = useState(false);
+    const runningId = useRef<string | null>(null);
 
     const runQuery = async () => {
+        const queryId = newQueryId();
+        runningId.current = queryId;
         setIsLoading(true);
         setError("");
-        setResults([]);
+        setResult(null);
         try {
-            const response = await dbService.runQuery(query);
-            setResults(response.data || []);
+            // The editor runs everything in it; show the last result set, or
+            // the last statement when none returned rows
+            const { statements } = await dbService.runScript(query, queryId);
+            const ran = statements.filter((s) => !s.skipped);
+            setResult([...ran].reverse().find((s) => s.columns.length > 0) || ran[ran.length - 1] || null);
         } catch (err: any) {
-            setError(err.response?.data?.error || "Query execution failed");
+            const data = err.response?.data;
+            const message = data?.error || "Query execution failed";
+            setError(data?.statement ? `Line ${data.statement.line}: ${message}` : message);
         } finally {
+            runningId.current = null;
             setIsLoading(false);
         }
     };
 
+    const cancelQuery = async () => {
+        if (!runningId.current) return;
+        try {
+            await dbService.cancelQuery(runningId.current);
+        } catch {
+            // The query finished before the cancellation arrived
+        }
+    };
+
     return {
         query,
         setQuery,
-        results,
+        result,
         error,
         isLoading,
-        runQuery
+        runQuery,
+        cancelQuery
     };
-};
\ No newline at end of file
+};
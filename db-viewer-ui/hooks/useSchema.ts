@@ -34,18 +34,27 @@ export const useSchema = (onEditTable: (name: string) => void,
         data: {
           label: tbl.name,
           columns: tbl.columns,
+          primaryKey: tbl.primary_key,
+          rowCount: tbl.row_count,
           onRefresh: refreshSchema,
           onEdit: onEditTable
         },
       }));
 
-      // Transform API relationships into Edges
+      // Transform API relationships into Edges; inferred links are dashed
       const newEdges: Edge[] = relationships.map((rel, i) => ({
         id: `e-${i}`,
         source: rel.source_table,
         target: rel.target_table,
-        animated: true,
-        style: { stroke: "#6366f1", strokeWidth: 2 },
+        label: rel.target_column
+          ? `${rel.source_column} → ${rel.target_column}`
+          : rel.source_column,
+        animated: rel.kind === "inferred",
+        style: {
+          stroke: rel.kind === "declared" ? "#6366f1" : "#a5b4fc",
+          strokeWidth: 2,
+          strokeDasharray: rel.kind === "declared" ? undefined : "6 4",
+        },
       }));
 
       setNodes(newNodes);
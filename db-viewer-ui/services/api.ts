@@ -1,5 +1,5 @@
 import axios from 'axios';
-import { SchemaResponse, QueryResponse } from '@/types';
+import { SchemaResponse, QueryResponse, ScriptResponse, QueryPlan, TablePage, TableDataParams } from '@/types';
 
 const API_URL = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080';
 
@@ -7,15 +7,40 @@ const api = axios.create({
     baseURL: API_URL,
 });
 
+// The backend keeps one in-memory database per session; remember ours so
+// every request (and page reload) lands on the same database.
+const SESSION_KEY = 'db-viewer-session';
+
+api.interceptors.request.use((config) => {
+    if (typeof window !== 'undefined') {
+        const sessionId = window.localStorage.getItem(SESSION_KEY);
+        if (sessionId) {
+            config.headers.set('X-Session-ID', sessionId);
+        }
+    }
+    return config;
+});
+
+api.interceptors.response.use((response) => {
+    const sessionId = response.headers['x-session-id'];
+    if (sessionId && typeof window !== 'undefined') {
+        window.localStorage.setItem(SESSION_KEY, sessionId);
+    }
+    return response;
+});
+
 interface AddColumnParams {
     tableName: string;
     columnName: string;
     columnType: string;
 }
 
+// RowKey maps every primary key column of a table to the row's value
+export type RowKey = Record<string, any>;
+
 interface UpdateCellParams {
     tableName: string;
-    recordId: string | number;
+    rowKey: RowKey;
     columnName: string;
     newValue: string;
 }
@@ -36,26 +61,55 @@ export const dbService = {
         });
     },
 
-    // Get Schema & Relationships
+    // Get Schema & Relationships, without row data
     getSchema: async (): Promise<SchemaResponse> => {
-        const res = await api.get<SchemaResponse>(`/db-info?_t=${new Date().getTime()}`);
+        const res = await api.get<SchemaResponse>(`/schema?_t=${new Date().getTime()}`);
+        return res.data;
+    },
+
+    // Run SQL Query; queryId lets cancelQuery stop it while it runs
+    runQuery: async (query: string, queryId?: string, params?: unknown[] | Record<string, unknown>): Promise<QueryResponse> => {
+        const res = await api.post<QueryResponse>('/query', { query, query_id: queryId, params });
+        return res.data;
+    },
+
+    // Run every statement of a script, each with its own result
+    runScript: async (script: string, queryId?: string, transaction = false): Promise<ScriptResponse> => {
+        const res = await api.post<ScriptResponse>('/query', { query: script, query_id: queryId, script: true, transaction });
         return res.data;
     },
 
-    // Run SQL Query
-    runQuery: async (query: string): Promise<QueryResponse> => {
-        const res = await api.post<QueryResponse>('/query', { query });
+    cancelQuery: async (queryId: string) => {
+        await api.post(`/query/${queryId}/cancel`);
+    },
+
+    // Show how SQLite would run a statement, without running it
+    explainQuery: async (query: string, params?: unknown[] | Record<string, unknown>): Promise<QueryPlan> => {
+        const res = await api.post<QueryPlan>('/query/explain', { query, params });
         return res.data;
     },
 
-    getDownloadUrl: (tableName: string) => {
+    // Fetch a table as CSV and save it. The export goes through axios so the
+    // session travels in its header rather than in a URL.
+    downloadTable: async (tableName: string) => {
         // We append ?t=TIMESTAMP to bust the cache
-        return `${API_URL}/export/${tableName}?t=${new Date().getTime()}`;
+        const res = await api.get<Blob>(`/export/${tableName}`, {
+            params: { t: new Date().getTime() },
+            responseType: 'blob',
+        });
+        const url = URL.createObjectURL(res.data);
+        const link = document.createElement('a');
+        link.href = url;
+        link.download = `${tableName}.csv`;
+        link.click();
+        setTimeout(() => URL.revokeObjectURL(url), 0);
     },
 
     // Get fresh data for a single table
-    getTableData: async (tableName: string) => {
-        const res = await api.get<any[]>(`/table-data/${tableName}?_t=${new Date().getTime()}`);
+    getTableData: async (tableName: string, params: TableDataParams = {}): Promise<TablePage> => {
+        const res = await api.get<TablePage>(`/table-data/${tableName}`, {
+            params: { ...params, _t: new Date().getTime() }
+        });
         return res.data;
     },
 
@@ -63,7 +117,7 @@ export const dbService = {
     updateCell: async (params: UpdateCellParams) => {
         return api.post('/update-cell', {
             table_name: params.tableName,
-            record_id: String(params.recordId),
+            key: params.rowKey,
             column_name: params.columnName,
             new_value: params.newValue
         });
@@ -74,10 +128,10 @@ export const dbService = {
         return api.post('/insert-row', { table_name: tableName });
     },
 
-    deleteRow: async (tableName: string, recordId: string | number) => {
+    deleteRow: async (tableName: string, rowKey: RowKey) => {
         return api.post('/delete-row', { 
             table_name: tableName, 
-            record_id: String(recordId) 
+            key: rowKey 
         });
     },
 };
\ No newline at end of file
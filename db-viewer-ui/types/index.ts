@@ -1,11 +1,31 @@
 export interface ColumnInfo {
     name: string;
     type: string;
+    not_null?: boolean;
+    default?: string;
+}
+
+export interface IndexInfo {
+    name: string;
+    columns: string[];
+    unique: boolean;
+    origin: "c" | "u" | "pk";
+}
+
+// A table as /schema describes it, without its rows
+export interface TableSchema {
+    name: string;
+    columns: ColumnInfo[];
+    primary_key: string[]; // "rowid" when the table declares none
+    indexes: IndexInfo[];
+    row_count: number;
+    sample?: any[];
 }
 
 export interface TableInfo {
     name: string;
     columns: ColumnInfo[]; // Was string[]
+    primary_key: string[]; // "rowid" when the table declares none
     rows: any[];
 }
 
@@ -13,13 +33,88 @@ export interface Relationship {
     source_table: string;
     target_table: string;
     source_column: string;
+    target_column?: string;
+    kind: "declared" | "inferred";
+    on_delete?: string;
+    on_update?: string;
 }
 
 export interface SchemaResponse {
-    tables: TableInfo[];
+    tables: TableSchema[];
     relationships: Relationship[];
 }
 
+// One page of /table-data; next_cursor is set while rows remain
+export interface TablePage {
+    columns: string[];
+    rows: any[];
+    total: number;
+    limit: number;
+    offset: number;
+    next_cursor?: string;
+}
+
+export interface TableDataParams {
+    limit?: number;
+    offset?: number;
+    cursor?: string;
+    sort?: string;   // e.g. "name,-age"
+    filter?: string; // JSON, e.g. {"column": "age", "op": "gt", "value": 30}
+}
+
+export interface QueryColumn {
+    name: string;
+    type: string; // declared type, empty for expressions
+    nullable?: boolean;
+}
+
 export interface QueryResponse {
-    data: any[];
+    query_id: string;
+    columns: QueryColumn[];
+    rows: any[][]; // values ordered like columns
+    row_count: number;
+    truncated: boolean; // the server stopped at its row limit
+    rows_affected?: number; // set for statements that return no rows
+    elapsed_ms: number;
+}
+
+export interface StatementResult extends QueryResponse {
+    index: number;
+    line: number;
+    sql: string;
+    skipped?: boolean; // BEGIN/COMMIT of a script run in a transaction
+}
+
+export interface PlanNode {
+    id: number;
+    detail: string;
+    op: string;
+    name?: string;
+    table?: string;
+    index?: string;
+    covering?: boolean;
+    automatic?: boolean;
+    constraint?: string;
+    purpose?: string;
+    correlated?: boolean;
+    children: PlanNode[];
+}
+
+export interface PlanWarning {
+    kind: 'full_scan' | 'automatic_index' | 'temp_btree' | 'correlated_subquery';
+    node_id: number;
+    table?: string;
+    message: string;
+}
+
+export interface QueryPlan {
+    plan: PlanNode[];
+    warnings: PlanWarning[];
+}
+
+export interface ScriptResponse {
+    query_id: string;
+    transaction: boolean;
+    statements: StatementResult[];
+    elapsed_ms: number;
 }
\ No newline at end of file